@@ -0,0 +1,189 @@
+package minequery
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// defaultBatchConcurrency is the number of workers used by PingMany and QueryMany functions
+// when concurrency is left as zero value.
+const defaultBatchConcurrency = 64
+
+// Target holds address of a server pinged or queried by PingMany and QueryMany functions.
+// Zero port is replaced with the default port of protocol being used.
+type Target struct {
+	Host string
+	Port int
+}
+
+// PingResult holds result of pinging a single target with PingMany.
+type PingResult struct {
+	// Target is the target this result belongs to.
+	Target Target
+
+	// Index is the index of Target in targets slice passed to PingMany.
+	Index int
+
+	// Status is the status returned by target, or nil if Err is set.
+	Status *Status
+
+	// Err is the error ping of target failed with.
+	Err error
+
+	// Started is the time ping of target was started at.
+	Started time.Time
+
+	// Duration is the time ping of target took, including SRV lookup and protocol negotiation.
+	Duration time.Duration
+}
+
+// QueryResult holds result of querying a single target with QueryMany.
+type QueryResult struct {
+	// Target is the target this result belongs to.
+	Target Target
+
+	// Index is the index of Target in targets slice passed to QueryMany.
+	Index int
+
+	// Status is the full query status returned by target, or nil if Err is set.
+	Status *FullQueryStatus
+
+	// Err is the error query of target failed with.
+	Err error
+
+	// Started is the time query of target was started at.
+	Started time.Time
+
+	// Duration is the time query of target took, including handshake.
+	Duration time.Duration
+}
+
+// PingMany pings all targets with Ping using at most concurrency workers at a time, and streams results
+// over the returned channel as they complete. The channel is closed once all targets are processed and
+// must be drained. If ctx is done, remaining targets are reported with the context error.
+// Zero or negative concurrency means 64 workers.
+//
+//goland:noinspection GoUnusedExportedFunction
+func PingMany(ctx context.Context, targets []Target, concurrency int) <-chan PingResult {
+	return defaultPinger.PingMany(ctx, targets, concurrency)
+}
+
+// PingManyFunc pings all targets with Ping using at most concurrency workers at a time, calling fn with
+// each result as it completes, and returns once all targets are processed. fn may be called concurrently.
+// If ctx is done, remaining targets are reported with the context error.
+// Zero or negative concurrency means 64 workers.
+//
+//goland:noinspection GoUnusedExportedFunction
+func PingManyFunc(ctx context.Context, targets []Target, concurrency int, fn func(PingResult)) {
+	defaultPinger.PingManyFunc(ctx, targets, concurrency, fn)
+}
+
+// PingMany pings all targets with Ping using at most concurrency workers at a time, and streams results
+// over the returned channel as they complete. The channel is closed once all targets are processed and
+// must be drained. If ctx is done, remaining targets are reported with the context error.
+// Zero or negative concurrency means 64 workers.
+func (p *Pinger) PingMany(ctx context.Context, targets []Target, concurrency int) <-chan PingResult {
+	results := make(chan PingResult)
+	go func() {
+		defer close(results)
+		p.PingManyFunc(ctx, targets, concurrency, func(result PingResult) { results <- result })
+	}()
+	return results
+}
+
+// PingManyFunc pings all targets with Ping using at most concurrency workers at a time, calling fn with
+// each result as it completes, and returns once all targets are processed. fn may be called concurrently.
+// If ctx is done, remaining targets are reported with the context error.
+// Zero or negative concurrency means 64 workers.
+func (p *Pinger) PingManyFunc(ctx context.Context, targets []Target, concurrency int, fn func(PingResult)) {
+	runBatch(len(targets), concurrency, func(i int) {
+		result := PingResult{Target: targets[i], Index: i, Started: time.Now()}
+		if err := ctx.Err(); err != nil {
+			result.Err = err
+		} else {
+			result.Status, result.Err = p.PingContext(ctx, targets[i].Host, targets[i].Port)
+			result.Duration = time.Since(result.Started)
+		}
+		fn(result)
+	})
+}
+
+// QueryMany queries all targets with QueryFull using at most concurrency workers at a time, and streams
+// results over the returned channel as they complete. The channel is closed once all targets are processed
+// and must be drained. If ctx is done, remaining targets are reported with the context error.
+// Zero or negative concurrency means 64 workers.
+//
+//goland:noinspection GoUnusedExportedFunction
+func QueryMany(ctx context.Context, targets []Target, concurrency int) <-chan QueryResult {
+	return defaultPinger.QueryMany(ctx, targets, concurrency)
+}
+
+// QueryManyFunc queries all targets with QueryFull using at most concurrency workers at a time, calling fn
+// with each result as it completes, and returns once all targets are processed. fn may be called
+// concurrently. If ctx is done, remaining targets are reported with the context error.
+// Zero or negative concurrency means 64 workers.
+//
+//goland:noinspection GoUnusedExportedFunction
+func QueryManyFunc(ctx context.Context, targets []Target, concurrency int, fn func(QueryResult)) {
+	defaultPinger.QueryManyFunc(ctx, targets, concurrency, fn)
+}
+
+// QueryMany queries all targets with QueryFull using at most concurrency workers at a time, and streams
+// results over the returned channel as they complete. The channel is closed once all targets are processed
+// and must be drained. If ctx is done, remaining targets are reported with the context error.
+// Zero or negative concurrency means 64 workers.
+func (p *Pinger) QueryMany(ctx context.Context, targets []Target, concurrency int) <-chan QueryResult {
+	results := make(chan QueryResult)
+	go func() {
+		defer close(results)
+		p.QueryManyFunc(ctx, targets, concurrency, func(result QueryResult) { results <- result })
+	}()
+	return results
+}
+
+// QueryManyFunc queries all targets with QueryFull using at most concurrency workers at a time, calling fn
+// with each result as it completes, and returns once all targets are processed. fn may be called
+// concurrently. If ctx is done, remaining targets are reported with the context error.
+// Zero or negative concurrency means 64 workers.
+func (p *Pinger) QueryManyFunc(ctx context.Context, targets []Target, concurrency int, fn func(QueryResult)) {
+	runBatch(len(targets), concurrency, func(i int) {
+		result := QueryResult{Target: targets[i], Index: i, Started: time.Now()}
+		if err := ctx.Err(); err != nil {
+			result.Err = err
+		} else {
+			result.Status, result.Err = p.QueryFullContext(ctx, targets[i].Host, targets[i].Port)
+			result.Duration = time.Since(result.Started)
+		}
+		fn(result)
+	})
+}
+
+// runBatch calls work for each index in [0, n) using at most concurrency goroutines at a time,
+// and returns once all calls return.
+func runBatch(n int, concurrency int, work func(i int)) {
+	if concurrency <= 0 {
+		concurrency = defaultBatchConcurrency
+	}
+	if concurrency > n {
+		concurrency = n
+	}
+
+	indices := make(chan int)
+	var wg sync.WaitGroup
+	wg.Add(concurrency)
+	for w := 0; w < concurrency; w++ {
+		go func() {
+			defer wg.Done()
+			for i := range indices {
+				work(i)
+			}
+		}()
+	}
+
+	for i := 0; i < n; i++ {
+		indices <- i
+	}
+	close(indices)
+	wg.Wait()
+}
@@ -0,0 +1,610 @@
+package minequery
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// ChatComponentType identifies content type of ChatComponent.
+type ChatComponentType string
+
+//goland:noinspection GoUnusedConst
+const (
+	// ChatComponentText is a plain text component, see ChatComponent Text.
+	ChatComponentText ChatComponentType = "text"
+
+	// ChatComponentTranslatable is a translated text component, see ChatComponent Translate.
+	ChatComponentTranslatable ChatComponentType = "translatable"
+
+	// ChatComponentScore is a scoreboard value component, see ChatComponent Score.
+	ChatComponentScore ChatComponentType = "score"
+
+	// ChatComponentSelector is an entity selector component, see ChatComponent Selector.
+	ChatComponentSelector ChatComponentType = "selector"
+
+	// ChatComponentKeybind is a keybind component, see ChatComponent Keybind.
+	ChatComponentKeybind ChatComponentType = "keybind"
+)
+
+// ChatColor holds color of chat component, which is either one of named colors (see ChatColor* constants)
+// or a hex color string in #RRGGBB format.
+type ChatColor string
+
+//goland:noinspection GoUnusedConst
+const (
+	ChatColorBlack       ChatColor = "black"
+	ChatColorDarkBlue    ChatColor = "dark_blue"
+	ChatColorDarkGreen   ChatColor = "dark_green"
+	ChatColorDarkAqua    ChatColor = "dark_aqua"
+	ChatColorDarkRed     ChatColor = "dark_red"
+	ChatColorDarkPurple  ChatColor = "dark_purple"
+	ChatColorGold        ChatColor = "gold"
+	ChatColorGray        ChatColor = "gray"
+	ChatColorDarkGray    ChatColor = "dark_gray"
+	ChatColorBlue        ChatColor = "blue"
+	ChatColorGreen       ChatColor = "green"
+	ChatColorAqua        ChatColor = "aqua"
+	ChatColorRed         ChatColor = "red"
+	ChatColorLightPurple ChatColor = "light_purple"
+	ChatColorYellow      ChatColor = "yellow"
+	ChatColorWhite       ChatColor = "white"
+)
+
+// chatColorRGB maps named chat colors to their RGB values as defined by Notchian client.
+var chatColorRGB = map[ChatColor][3]uint8{
+	ChatColorBlack:       {0x00, 0x00, 0x00},
+	ChatColorDarkBlue:    {0x00, 0x00, 0xaa},
+	ChatColorDarkGreen:   {0x00, 0xaa, 0x00},
+	ChatColorDarkAqua:    {0x00, 0xaa, 0xaa},
+	ChatColorDarkRed:     {0xaa, 0x00, 0x00},
+	ChatColorDarkPurple:  {0xaa, 0x00, 0xaa},
+	ChatColorGold:        {0xff, 0xaa, 0x00},
+	ChatColorGray:        {0xaa, 0xaa, 0xaa},
+	ChatColorDarkGray:    {0x55, 0x55, 0x55},
+	ChatColorBlue:        {0x55, 0x55, 0xff},
+	ChatColorGreen:       {0x55, 0xff, 0x55},
+	ChatColorAqua:        {0x55, 0xff, 0xff},
+	ChatColorRed:         {0xff, 0x55, 0x55},
+	ChatColorLightPurple: {0xff, 0x55, 0xff},
+	ChatColorYellow:      {0xff, 0xff, 0x55},
+	ChatColorWhite:       {0xff, 0xff, 0xff},
+}
+
+// IsHex checks if color is a hex color string rather than one of named colors.
+func (c ChatColor) IsHex() bool { return strings.HasPrefix(string(c), "#") }
+
+// RGB returns red, green and blue components of the color, returning false as
+// the last return value if color is empty, unknown or malformed.
+func (c ChatColor) RGB() (r, g, b uint8, ok bool) {
+	if rgb, ok := chatColorRGB[ChatColor(strings.ToLower(string(c)))]; ok {
+		return rgb[0], rgb[1], rgb[2], true
+	}
+	if !c.IsHex() || len(c) != 7 {
+		return 0, 0, 0, false
+	}
+	value, err := strconv.ParseUint(string(c[1:]), 16, 32)
+	if err != nil {
+		return 0, 0, 0, false
+	}
+	return uint8(value >> 16), uint8(value >> 8), uint8(value), true
+}
+
+// ChatClickEvent holds action performed when chat component is clicked.
+type ChatClickEvent struct {
+	Action string
+	Value  string
+}
+
+// ChatHoverEvent holds tooltip shown when chat component is hovered.
+type ChatHoverEvent struct {
+	Action string
+
+	// Text is the tooltip component for show_text action.
+	Text *ChatComponent
+
+	// Contents holds raw decoded JSON contents for other actions (such as show_item or show_entity.)
+	Contents interface{}
+}
+
+// ChatStyle holds formatting of chat component. Nil formatting flags and empty values
+// are not set and are inherited from the parent component.
+type ChatStyle struct {
+	Color         ChatColor
+	Bold          *bool
+	Italic        *bool
+	Underlined    *bool
+	Strikethrough *bool
+	Obfuscated    *bool
+	Font          string
+	Insertion     string
+	ClickEvent    *ChatClickEvent
+	HoverEvent    *ChatHoverEvent
+}
+
+// IsBold reports if Bold is set to true.
+func (s ChatStyle) IsBold() bool { return s.Bold != nil && *s.Bold }
+
+// IsItalic reports if Italic is set to true.
+func (s ChatStyle) IsItalic() bool { return s.Italic != nil && *s.Italic }
+
+// IsUnderlined reports if Underlined is set to true.
+func (s ChatStyle) IsUnderlined() bool { return s.Underlined != nil && *s.Underlined }
+
+// IsStrikethrough reports if Strikethrough is set to true.
+func (s ChatStyle) IsStrikethrough() bool { return s.Strikethrough != nil && *s.Strikethrough }
+
+// IsObfuscated reports if Obfuscated is set to true.
+func (s ChatStyle) IsObfuscated() bool { return s.Obfuscated != nil && *s.Obfuscated }
+
+// Inherit returns a copy of the style with all values that are not set taken from parent style.
+func (s ChatStyle) Inherit(parent ChatStyle) ChatStyle {
+	if s.Color == "" {
+		s.Color = parent.Color
+	}
+	if s.Bold == nil {
+		s.Bold = parent.Bold
+	}
+	if s.Italic == nil {
+		s.Italic = parent.Italic
+	}
+	if s.Underlined == nil {
+		s.Underlined = parent.Underlined
+	}
+	if s.Strikethrough == nil {
+		s.Strikethrough = parent.Strikethrough
+	}
+	if s.Obfuscated == nil {
+		s.Obfuscated = parent.Obfuscated
+	}
+	if s.Font == "" {
+		s.Font = parent.Font
+	}
+	if s.Insertion == "" {
+		s.Insertion = parent.Insertion
+	}
+	if s.ClickEvent == nil {
+		s.ClickEvent = parent.ClickEvent
+	}
+	if s.HoverEvent == nil {
+		s.HoverEvent = parent.HoverEvent
+	}
+	return s
+}
+
+// ChatScore holds scoreboard value reference of score component.
+type ChatScore struct {
+	Name      string
+	Objective string
+
+	// Value is the resolved score value; servers usually resolve it before sending.
+	Value string
+}
+
+// ChatComponent holds chat component tree decoded from JSON (such as 1.7+ server description).
+// Content fields (Text, Translate, Score, Selector, Keybind) are set according to component Type.
+type ChatComponent struct {
+	Type  ChatComponentType
+	Style ChatStyle
+
+	Text string
+
+	Translate         string
+	TranslateFallback string
+	With              []*ChatComponent
+
+	Score *ChatScore
+
+	Selector  string
+	Separator *ChatComponent
+
+	Keybind string
+
+	Extra []*ChatComponent
+}
+
+// String returns text of the component and its children without formatting.
+// Translatable components are not translated: their With arguments are substituted into %s and %1$s
+// placeholders of fallback string (if present) or translation key instead. Selector components are not
+// resolved and are written as selectors, so Separator (joining resolved entities) is not written;
+// keybind components are written as keybind identifiers.
+func (c *ChatComponent) String() string {
+	buffer := bytes.NewBuffer(make([]byte, 0, 128))
+	c.walk(func(component *ChatComponent, _ ChatStyle) {
+		buffer.WriteString(component.content())
+	})
+	return buffer.String()
+}
+
+// Component returns the component itself, so that ChatComponent set as Status17 Description
+// is returned by DescriptionComponent as is.
+func (c *ChatComponent) Component() *ChatComponent { return c }
+
+// content returns component own text without children.
+func (c *ChatComponent) content() string {
+	switch c.Type {
+	case ChatComponentTranslatable:
+		if c.TranslateFallback != "" {
+			return chatTranslate(c.TranslateFallback, c.With)
+		}
+		return chatTranslate(c.Translate, c.With)
+	case ChatComponentScore:
+		if c.Score != nil {
+			return c.Score.Value
+		}
+		return ""
+	case ChatComponentSelector:
+		return c.Selector
+	case ChatComponentKeybind:
+		return c.Keybind
+	default:
+		return c.Text
+	}
+}
+
+// chatTranslate substitutes text of args into %s (sequential) and %1$s (positional) placeholders
+// of format and replaces %% with %. Format is returned as is if there are no args.
+func chatTranslate(format string, args []*ChatComponent) string {
+	if len(args) == 0 {
+		return format
+	}
+
+	buffer := bytes.NewBuffer(make([]byte, 0, len(format)))
+	next := 0
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			buffer.WriteByte(format[i])
+			continue
+		}
+
+		rest := format[i+1:]
+		switch {
+		case strings.HasPrefix(rest, "%"):
+			// Escaped percent sign
+			buffer.WriteByte('%')
+			i++
+
+		case strings.HasPrefix(rest, "s"):
+			// Sequential argument
+			buffer.WriteString(chatTranslateArg(args, next))
+			next++
+			i++
+
+		default:
+			// Positional argument (1-based), or a lone percent sign that is written as is
+			digits := 0
+			for digits < len(rest) && rest[digits] >= '0' && rest[digits] <= '9' {
+				digits++
+			}
+			if digits > 0 && strings.HasPrefix(rest[digits:], "$s") {
+				n, _ := strconv.Atoi(rest[:digits])
+				buffer.WriteString(chatTranslateArg(args, n-1))
+				i += digits + 2
+			} else {
+				buffer.WriteByte('%')
+			}
+		}
+	}
+	return buffer.String()
+}
+
+// chatTranslateArg returns text of args item at index, or empty string if there is no such item.
+func chatTranslateArg(args []*ChatComponent, index int) string {
+	if index < 0 || index >= len(args) || args[index] == nil {
+		return ""
+	}
+	return args[index].String()
+}
+
+// walk visits component and all its children depth-first in display order,
+// passing the resolved (inherited) style of each visited component.
+func (c *ChatComponent) walk(visit func(*ChatComponent, ChatStyle)) {
+	type frame struct {
+		component *ChatComponent
+		parent    ChatStyle
+	}
+
+	componentStack := make(stack, 0, 8)
+	componentStack.Push(frame{c, ChatStyle{}})
+
+	for len(componentStack) > 0 {
+		// Remove topmost element from stack and get it for processing
+		current, _ := componentStack.Pop()
+		f := current.(frame)
+		if f.component == nil {
+			continue
+		}
+
+		style := f.component.Style.Inherit(f.parent)
+		visit(f.component, style)
+
+		// Push children in reverse order (so that they are processed in natural order because stack is LIFO)
+		for i := len(f.component.Extra) - 1; i >= 0; i-- {
+			componentStack.Push(frame{f.component.Extra[i], style})
+		}
+	}
+}
+
+// newChatComponent decodes component tree from arbitrary JSON value decoded by UnmarshalFunc.
+func newChatComponent(value interface{}) *ChatComponent {
+	switch value := value.(type) {
+	case nil:
+		return &ChatComponent{Type: ChatComponentText}
+
+	case string:
+		return &ChatComponent{Type: ChatComponentText, Text: value}
+
+	case bool:
+		return &ChatComponent{Type: ChatComponentText, Text: strconv.FormatBool(value)}
+
+	case float64:
+		return &ChatComponent{Type: ChatComponentText, Text: strconv.FormatFloat(value, 'f', -1, 64)}
+
+	case json.Number:
+		return &ChatComponent{Type: ChatComponentText, Text: value.String()}
+
+	case []interface{}:
+		// Array is treated as the first component with the rest appended to its children,
+		// which is the way Notchian client does it.
+		if len(value) == 0 {
+			return &ChatComponent{Type: ChatComponentText}
+		}
+		component := newChatComponent(value[0])
+		for _, child := range value[1:] {
+			component.Extra = append(component.Extra, newChatComponent(child))
+		}
+		return component
+
+	case map[string]interface{}:
+		return newChatComponentFromObject(value)
+
+	default:
+		return &ChatComponent{Type: ChatComponentText}
+	}
+}
+
+func newChatComponentFromObject(object map[string]interface{}) *ChatComponent {
+	component := &ChatComponent{Style: newChatStyle(object)}
+
+	// Determine content type; explicit type field is only present since 1.20.3,
+	// otherwise it is inferred from present content fields.
+	componentType, _ := object["type"].(string)
+	switch {
+	case componentType == string(ChatComponentText) || (componentType == "" && object["text"] != nil):
+		component.Type = ChatComponentText
+	case componentType == string(ChatComponentTranslatable) || (componentType == "" && object["translate"] != nil):
+		component.Type = ChatComponentTranslatable
+	case componentType == string(ChatComponentScore) || (componentType == "" && object["score"] != nil):
+		component.Type = ChatComponentScore
+	case componentType == string(ChatComponentSelector) || (componentType == "" && object["selector"] != nil):
+		component.Type = ChatComponentSelector
+	case componentType == string(ChatComponentKeybind) || (componentType == "" && object["keybind"] != nil):
+		component.Type = ChatComponentKeybind
+	default:
+		component.Type = ChatComponentText
+	}
+
+	switch component.Type {
+	case ChatComponentText:
+		component.Text = chatComponentString(object["text"])
+	case ChatComponentTranslatable:
+		component.Translate = chatComponentString(object["translate"])
+		component.TranslateFallback = chatComponentString(object["fallback"])
+		if with, ok := object["with"].([]interface{}); ok {
+			component.With = make([]*ChatComponent, len(with))
+			for i, arg := range with {
+				component.With[i] = newChatComponent(arg)
+			}
+		}
+	case ChatComponentScore:
+		if score, ok := object["score"].(map[string]interface{}); ok {
+			component.Score = &ChatScore{
+				Name:      chatComponentString(score["name"]),
+				Objective: chatComponentString(score["objective"]),
+				Value:     chatComponentString(score["value"]),
+			}
+		}
+	case ChatComponentSelector:
+		component.Selector = chatComponentString(object["selector"])
+		if separator, ok := object["separator"]; ok {
+			component.Separator = newChatComponent(separator)
+		}
+	case ChatComponentKeybind:
+		component.Keybind = chatComponentString(object["keybind"])
+	}
+
+	if extra, ok := object["extra"].([]interface{}); ok {
+		component.Extra = make([]*ChatComponent, len(extra))
+		for i, child := range extra {
+			component.Extra[i] = newChatComponent(child)
+		}
+	}
+
+	return component
+}
+
+func newChatStyle(object map[string]interface{}) ChatStyle {
+	style := ChatStyle{
+		Color:         ChatColor(chatComponentString(object["color"])),
+		Bold:          chatComponentBool(object["bold"]),
+		Italic:        chatComponentBool(object["italic"]),
+		Underlined:    chatComponentBool(object["underlined"]),
+		Strikethrough: chatComponentBool(object["strikethrough"]),
+		Obfuscated:    chatComponentBool(object["obfuscated"]),
+		Font:          chatComponentString(object["font"]),
+		Insertion:     chatComponentString(object["insertion"]),
+	}
+
+	// Click event (clickEvent before 1.21.5, click_event since)
+	clickEvent, ok := object["clickEvent"].(map[string]interface{})
+	if !ok {
+		clickEvent, ok = object["click_event"].(map[string]interface{})
+	}
+	if ok {
+		style.ClickEvent = &ChatClickEvent{Action: chatComponentString(clickEvent["action"])}
+		for _, key := range []string{"value", "url", "command", "path", "page"} {
+			if value, ok := clickEvent[key]; ok {
+				style.ClickEvent.Value = chatComponentString(value)
+				break
+			}
+		}
+	}
+
+	// Hover event (hoverEvent before 1.21.5, hover_event since)
+	hoverEvent, ok := object["hoverEvent"].(map[string]interface{})
+	if !ok {
+		hoverEvent, ok = object["hover_event"].(map[string]interface{})
+	}
+	if ok {
+		style.HoverEvent = &ChatHoverEvent{Action: chatComponentString(hoverEvent["action"])}
+
+		// Contents is present since 1.16, value is legacy representation, text is used since 1.21.5
+		contents, ok := hoverEvent["contents"]
+		if !ok {
+			contents, ok = hoverEvent["value"]
+		}
+		if !ok {
+			contents, ok = hoverEvent["text"]
+		}
+		if ok {
+			if style.HoverEvent.Action == "show_text" {
+				style.HoverEvent.Text = newChatComponent(contents)
+			} else {
+				style.HoverEvent.Contents = contents
+			}
+		}
+	}
+
+	return style
+}
+
+func chatComponentString(value interface{}) string {
+	switch value := value.(type) {
+	case string:
+		return value
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	case json.Number:
+		return value.String()
+	case bool:
+		return strconv.FormatBool(value)
+	default:
+		return ""
+	}
+}
+
+func chatComponentBool(value interface{}) *bool {
+	switch value := value.(type) {
+	case bool:
+		return &value
+	case string:
+		// Some server software sends formatting flags as strings
+		if parsed, err := strconv.ParseBool(value); err == nil {
+			return &parsed
+		}
+	}
+	return nil
+}
+
+// UnmarshalJSON decodes component tree from JSON chat component representation.
+func (c *ChatComponent) UnmarshalJSON(data []byte) error {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	*c = *newChatComponent(value)
+	return nil
+}
+
+// MarshalJSON encodes component tree to JSON chat component representation.
+func (c *ChatComponent) MarshalJSON() ([]byte, error) {
+	return json.Marshal(c.jsonValue())
+}
+
+// jsonValue returns JSON object representation of the component.
+func (c *ChatComponent) jsonValue() map[string]interface{} {
+	object := make(map[string]interface{})
+
+	switch c.Type {
+	case ChatComponentTranslatable:
+		object["translate"] = c.Translate
+		if c.TranslateFallback != "" {
+			object["fallback"] = c.TranslateFallback
+		}
+		if len(c.With) > 0 {
+			object["with"] = chatComponentsJSONValue(c.With)
+		}
+	case ChatComponentScore:
+		score := make(map[string]interface{})
+		if c.Score != nil {
+			score["name"] = c.Score.Name
+			score["objective"] = c.Score.Objective
+			if c.Score.Value != "" {
+				score["value"] = c.Score.Value
+			}
+		}
+		object["score"] = score
+	case ChatComponentSelector:
+		object["selector"] = c.Selector
+		if c.Separator != nil {
+			object["separator"] = c.Separator.jsonValue()
+		}
+	case ChatComponentKeybind:
+		object["keybind"] = c.Keybind
+	default:
+		object["text"] = c.Text
+	}
+
+	if c.Style.Color != "" {
+		object["color"] = string(c.Style.Color)
+	}
+	for key, flag := range map[string]*bool{
+		"bold":          c.Style.Bold,
+		"italic":        c.Style.Italic,
+		"underlined":    c.Style.Underlined,
+		"strikethrough": c.Style.Strikethrough,
+		"obfuscated":    c.Style.Obfuscated,
+	} {
+		if flag != nil {
+			object[key] = *flag
+		}
+	}
+	if c.Style.Font != "" {
+		object["font"] = c.Style.Font
+	}
+	if c.Style.Insertion != "" {
+		object["insertion"] = c.Style.Insertion
+	}
+	if c.Style.ClickEvent != nil {
+		object["clickEvent"] = map[string]interface{}{
+			"action": c.Style.ClickEvent.Action,
+			"value":  c.Style.ClickEvent.Value,
+		}
+	}
+	if c.Style.HoverEvent != nil {
+		hoverEvent := map[string]interface{}{"action": c.Style.HoverEvent.Action}
+		if c.Style.HoverEvent.Text != nil {
+			hoverEvent["contents"] = c.Style.HoverEvent.Text.jsonValue()
+		} else if c.Style.HoverEvent.Contents != nil {
+			hoverEvent["contents"] = c.Style.HoverEvent.Contents
+		}
+		object["hoverEvent"] = hoverEvent
+	}
+
+	if len(c.Extra) > 0 {
+		object["extra"] = chatComponentsJSONValue(c.Extra)
+	}
+
+	return object
+}
+
+func chatComponentsJSONValue(components []*ChatComponent) []interface{} {
+	values := make([]interface{}, 0, len(components))
+	for _, component := range components {
+		if component != nil {
+			values = append(values, component.jsonValue())
+		}
+	}
+	return values
+}
@@ -0,0 +1,64 @@
+package minequery_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/dreamscached/minequery/v2"
+	"github.com/dreamscached/minequery/v2/minequerytest"
+)
+
+func TestChatComponentString(t *testing.T) {
+	cases := []struct {
+		name      string
+		component *minequery.ChatComponent
+		expected  string
+	}{
+		{"Text", &minequery.ChatComponent{Type: minequery.ChatComponentText, Text: "A Minecraft Server"}, "A Minecraft Server"},
+		{"Extra", &minequery.ChatComponent{
+			Type: minequery.ChatComponentText, Text: "A ",
+			Extra: []*minequery.ChatComponent{{Type: minequery.ChatComponentText, Text: "Server"}},
+		}, "A Server"},
+		{"TranslateWith", &minequery.ChatComponent{
+			Type: minequery.ChatComponentTranslatable, Translate: "chat.type.text", TranslateFallback: "<%s> %2$s 100%%",
+			With: []*minequery.ChatComponent{
+				{Type: minequery.ChatComponentText, Text: "Notch"},
+				{Type: minequery.ChatComponentText, Text: "hi"},
+			},
+		}, "<Notch> hi 100%"},
+		{"TranslateWithoutArgs", &minequery.ChatComponent{Type: minequery.ChatComponentTranslatable, Translate: "%s"}, "%s"},
+	}
+
+	for _, c := range cases {
+		if actual := c.component.String(); actual != c.expected {
+			t.Errorf("%s: expected %q, got %q", c.name, c.expected, actual)
+		}
+	}
+}
+
+func TestStatus17DescriptionJSON(t *testing.T) {
+	payload := []byte(`{"version":{"name":"1.20.1","protocol":763},"players":{"max":20,"online":0},` +
+		`"description":{"translate":"%s!","with":[{"text":"Hello"}]}}`)
+	server, err := minequerytest.NewPing17RawServer(payload, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = server.Close() }()
+
+	status, err := minequery.NewPinger(minequery.WithTimeout(time.Second)).Ping17(server.Host, server.Port)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual := status.Description.String(); actual != "Hello!" {
+		t.Errorf("expected description %q, got %q", "Hello!", actual)
+	}
+
+	b, err := json.Marshal(status.Description)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := `{"translate":"%s!","with":[{"text":"Hello"}]}`; string(b) != expected {
+		t.Errorf("expected description JSON %s, got %s", expected, b)
+	}
+}
@@ -0,0 +1,385 @@
+// Command minequery pings and queries Minecraft servers.
+//
+// Usage:
+//
+//	minequery ping [flags] host[:port]
+//	minequery query [basic|full] [flags] host[:port]
+//	minequery srv [flags] host
+//
+// Run minequery <command> -h for the list of flags of each command.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dreamscached/minequery/v2"
+)
+
+const (
+	exitOK    = 0
+	exitError = 1
+	exitUsage = 2
+)
+
+const (
+	outputText = "text"
+	outputJSON = "json"
+)
+
+var errUsage = errors.New("invalid usage")
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		printUsage(stderr)
+		return exitUsage
+	}
+
+	var err error
+	switch args[0] {
+	case "ping":
+		err = runPing(args[1:], stdout, stderr)
+	case "query":
+		err = runQuery(args[1:], stdout, stderr)
+	case "srv":
+		err = runSRV(args[1:], stdout, stderr)
+	case "help", "-h", "-help", "--help":
+		printUsage(stdout)
+		return exitOK
+	default:
+		_, _ = fmt.Fprintf(stderr, "minequery: unknown command %q\n", args[0])
+		printUsage(stderr)
+		return exitUsage
+	}
+
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.Is(err, flag.ErrHelp):
+		return exitOK
+	case errors.Is(err, errUsage):
+		return exitUsage
+	default:
+		_, _ = fmt.Fprintf(stderr, "minequery: %s\n", err)
+		return exitError
+	}
+}
+
+func printUsage(w io.Writer) {
+	_, _ = fmt.Fprint(w, `Usage:
+  minequery ping [flags] host[:port]           ping server (any version by default)
+  minequery query [basic|full] [flags] host[:port]  query server via Query protocol (full by default)
+  minequery srv [flags] host                   look up Minecraft SRV records of host
+
+Run minequery <command> -h for the list of flags of each command.
+`)
+}
+
+// Common flags
+
+// commonFlags holds flags mapped to PingerOption functions and output format shared by all commands.
+type commonFlags struct {
+	timeout           time.Duration
+	strict            bool
+	preferSRV         bool
+	protocolVersion16 int
+	protocolVersion17 int
+	output            string
+}
+
+// register registers common flags in fs; ping-specific flags are only registered if ping is set.
+func (f *commonFlags) register(fs *flag.FlagSet, ping bool) {
+	f.protocolVersion16 = int(minequery.Ping16ProtocolVersion162)
+	f.protocolVersion17 = int(minequery.Ping17ProtocolVersionUndefined)
+
+	fs.DurationVar(&f.timeout, "timeout", 15*time.Second, "connection timeout")
+	fs.BoolVar(&f.strict, "strict", false, "return tolerable response errors instead of ignoring them")
+	fs.StringVar(&f.output, "o", outputText, "output format: text or json")
+	if ping {
+		fs.BoolVar(&f.preferSRV, "srv", true, "prefer SRV record of hostname")
+		fs.IntVar(&f.protocolVersion16, "protocol16", f.protocolVersion16, "protocol version sent in 1.6 ping")
+		fs.IntVar(&f.protocolVersion17, "protocol17", f.protocolVersion17, "protocol version sent in 1.7+ ping")
+	}
+}
+
+func (f *commonFlags) pinger(extra ...minequery.PingerOption) *minequery.Pinger {
+	options := []minequery.PingerOption{
+		minequery.WithTimeout(f.timeout),
+		minequery.WithUseStrict(f.strict),
+		minequery.WithPreferSRVRecord(f.preferSRV),
+		minequery.WithProtocolVersion16(byte(f.protocolVersion16)),
+		minequery.WithProtocolVersion17(int32(f.protocolVersion17)),
+	}
+	return minequery.NewPinger(append(options, extra...)...)
+}
+
+func (f *commonFlags) validate() error {
+	if f.output != outputText && f.output != outputJSON {
+		return fmt.Errorf("%w: unknown output format %q", errUsage, f.output)
+	}
+	if f.protocolVersion16 < 0 || f.protocolVersion16 > 255 {
+		return fmt.Errorf("%w: 1.6 protocol version must fit in a byte", errUsage)
+	}
+	return nil
+}
+
+// parseFlags parses args with fs and returns the single positional argument.
+func parseFlags(fs *flag.FlagSet, args []string, common *commonFlags) (string, error) {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return "", err
+		}
+		return "", fmt.Errorf("%w: %s", errUsage, err)
+	}
+	if err := common.validate(); err != nil {
+		return "", err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return "", fmt.Errorf("%w: expected exactly one address", errUsage)
+	}
+	return fs.Arg(0), nil
+}
+
+// splitAddr splits address into host and port; port is zero if address has none.
+func splitAddr(addr string) (string, int, error) {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		// Address has no port (or is a bare IPv6 address)
+		return strings.Trim(addr, "[]"), 0, nil
+	}
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil {
+		return "", 0, fmt.Errorf("%w: invalid port %q", errUsage, portStr)
+	}
+	return host, int(port), nil
+}
+
+func newFlagSet(name string, stderr io.Writer, usage string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		_, _ = fmt.Fprintf(stderr, "Usage: minequery %s\n\nFlags:\n", usage)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+func writeJSON(w io.Writer, v interface{}) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+// ping command
+
+func runPing(args []string, stdout, stderr io.Writer) error {
+	var common commonFlags
+	var protocol, favicon string
+	var latency bool
+
+	fs := newFlagSet("ping", stderr, "ping [flags] host[:port]")
+	common.register(fs, true)
+	fs.StringVar(&protocol, "protocol", "auto", "ping protocol: auto, 1.7, 1.6, 1.4, beta1.8 or bedrock")
+	fs.StringVar(&favicon, "favicon", "", "write 1.7+ server favicon to PNG file")
+	fs.BoolVar(&latency, "latency", true, "measure 1.7+ ping/pong latency")
+	addr, err := parseFlags(fs, args, &common)
+	if err != nil {
+		return err
+	}
+	host, port, err := splitAddr(addr)
+	if err != nil {
+		return err
+	}
+
+	pinger := common.pinger(minequery.WithMeasureLatency(latency), minequery.WithProtocolCacheDisabled())
+	ctx := context.Background()
+
+	var res interface{}
+	var status17 *minequery.Status17
+	switch strings.ToLower(protocol) {
+	case "auto":
+		var status *minequery.Status
+		if status, err = pinger.PingContext(ctx, host, port); err == nil {
+			res, status17 = status, status.Status17
+		}
+	case "1.7", "1.7+":
+		if status17, err = pinger.Ping17Context(ctx, host, port); err == nil {
+			res = status17
+		}
+	case "1.6":
+		res, err = pinger.Ping16Context(ctx, host, port)
+	case "1.4":
+		res, err = pinger.Ping14Context(ctx, host, port)
+	case "beta1.8", "beta18":
+		res, err = pinger.PingBeta18Context(ctx, host, port)
+	case "bedrock":
+		res, err = pinger.PingBedrockContext(ctx, host, port)
+	default:
+		return fmt.Errorf("%w: unknown protocol %q", errUsage, protocol)
+	}
+	if err != nil {
+		return err
+	}
+
+	// Missing favicon doesn't fail ping that succeeded, since only 1.7+ servers send one
+	if favicon != "" {
+		if status17 == nil || status17.Icon == nil {
+			_, _ = fmt.Fprintln(stderr, "minequery: server did not send favicon, not writing", favicon)
+		} else if err = writeFavicon(favicon, status17.Icon); err != nil {
+			return err
+		}
+	}
+
+	if common.output == outputJSON {
+		return writeJSON(stdout, pingJSON(res))
+	}
+	_, err = fmt.Fprintln(stdout, res)
+	return err
+}
+
+func writeFavicon(path string, icon image.Image) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err = png.Encode(file, icon); err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// pingJSON returns JSON-friendly representation of ping response: 1.7+ status is represented by
+// its original JSON payload (which includes favicon and description components), since Status17
+// itself holds decoded image and chat component tree.
+func pingJSON(res interface{}) interface{} {
+	switch status := res.(type) {
+	case *minequery.Status:
+		result := map[string]interface{}{
+			"protocol":        status.Protocol.String(),
+			"versionName":     status.VersionName,
+			"protocolVersion": status.ProtocolVersion,
+			"motd":            status.MOTD,
+			"onlinePlayers":   status.OnlinePlayers,
+			"maxPlayers":      status.MaxPlayers,
+			"host":            status.Host,
+			"port":            status.Port,
+		}
+		if status.SRV != nil {
+			result["srv"] = status.SRV
+		}
+		if status.Status17 != nil {
+			result["status"] = status17JSON(status.Status17)
+		}
+		return result
+	case *minequery.Status17:
+		return status17JSON(status)
+	default:
+		return res
+	}
+}
+
+func status17JSON(status *minequery.Status17) interface{} {
+	result := map[string]interface{}{
+		"response":    json.RawMessage(status.Raw),
+		"connectTime": status.ConnectTime.Seconds(),
+	}
+	if status.Latency != 0 {
+		result["latency"] = status.Latency.Seconds()
+	}
+	if status.LatencyErr != nil {
+		result["latencyError"] = status.LatencyErr.Error()
+	}
+	if status.SRV != nil {
+		result["srv"] = status.SRV
+	}
+	return result
+}
+
+// query command
+
+func runQuery(args []string, stdout, stderr io.Writer) error {
+	kind := "full"
+	if len(args) > 0 && (args[0] == "basic" || args[0] == "full") {
+		kind, args = args[0], args[1:]
+	}
+
+	var common commonFlags
+	fs := newFlagSet("query", stderr, "query [basic|full] [flags] host[:port]")
+	common.register(fs, false)
+	addr, err := parseFlags(fs, args, &common)
+	if err != nil {
+		return err
+	}
+	host, port, err := splitAddr(addr)
+	if err != nil {
+		return err
+	}
+
+	pinger := common.pinger()
+	ctx := context.Background()
+
+	var res interface{}
+	if kind == "basic" {
+		res, err = pinger.QueryBasicContext(ctx, host, port)
+	} else {
+		res, err = pinger.QueryFullContext(ctx, host, port)
+	}
+	if err != nil {
+		return err
+	}
+
+	if common.output == outputJSON {
+		return writeJSON(stdout, res)
+	}
+	_, err = fmt.Fprintln(stdout, res)
+	return err
+}
+
+// srv command
+
+func runSRV(args []string, stdout, stderr io.Writer) error {
+	var common commonFlags
+	fs := newFlagSet("srv", stderr, "srv [flags] host")
+	common.register(fs, false)
+	host, err := parseFlags(fs, args, &common)
+	if err != nil {
+		return err
+	}
+
+	records, err := common.pinger().LookupSRVContext(context.Background(), host)
+	if err != nil {
+		return err
+	}
+
+	if common.output == outputJSON {
+		return writeJSON(stdout, records)
+	}
+	if len(records) == 0 {
+		_, err = fmt.Fprintf(stdout, "%s has no Minecraft SRV records\n", host)
+		return err
+	}
+	for _, record := range records {
+		if _, err = fmt.Fprintf(stdout, "%s:%d (priority %d, weight %d)\n",
+			record.Target, record.Port, record.Priority, record.Weight); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dreamscached/minequery/v2"
+	"github.com/dreamscached/minequery/v2/minequerytest"
+)
+
+func TestRunUsage(t *testing.T) {
+	cases := []struct {
+		name string
+		args []string
+		code int
+	}{
+		{"no command", nil, exitUsage},
+		{"unknown command", []string{"status", "localhost"}, exitUsage},
+		{"help", []string{"help"}, exitOK},
+		{"command help", []string{"ping", "-h"}, exitOK},
+		{"no address", []string{"ping"}, exitUsage},
+		{"several addresses", []string{"query", "localhost", "localhost"}, exitUsage},
+		{"unknown flag", []string{"srv", "-protocol", "1.7", "localhost"}, exitUsage},
+		{"unknown output", []string{"ping", "-o", "yaml", "localhost"}, exitUsage},
+		{"unknown protocol", []string{"ping", "-protocol", "1.2", "localhost"}, exitUsage},
+		{"invalid port", []string{"ping", "localhost:65536"}, exitUsage},
+		{"invalid 1.6 protocol version", []string{"ping", "-protocol16", "256", "localhost"}, exitUsage},
+	}
+
+	for _, c := range cases {
+		var stdout, stderr bytes.Buffer
+		if code := run(c.args, &stdout, &stderr); code != c.code {
+			t.Errorf("%s: expected exit code %d, got %d (stderr: %s)", c.name, c.code, code, stderr.String())
+		}
+	}
+}
+
+func TestRunPing(t *testing.T) {
+	icon := image.NewNRGBA(image.Rect(0, 0, 64, 64))
+	icon.Set(1, 2, color.NRGBA{R: 0xff, A: 0xff})
+	server17, err := minequerytest.NewPing17Server(&minequery.Status17{
+		VersionName:     "1.20.1",
+		ProtocolVersion: 763,
+		OnlinePlayers:   3,
+		MaxPlayers:      20,
+		Description:     &minequery.ChatComponent{Text: "A Minecraft Server"},
+		Icon:            icon,
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = server17.Close() }()
+
+	// Ping with JSON output reports address server answered at
+	var stdout, stderr bytes.Buffer
+	args := []string{"ping", "-srv=false", "-o", "json", server17.Addr()}
+	if code := run(args, &stdout, &stderr); code != exitOK {
+		t.Fatalf("expected exit code %d, got %d (stderr: %s)", exitOK, code, stderr.String())
+	}
+	var res struct {
+		Protocol      string `json:"protocol"`
+		OnlinePlayers int    `json:"onlinePlayers"`
+		Host          string `json:"host"`
+		Port          int    `json:"port"`
+		Status        struct {
+			Response struct {
+				Version struct {
+					Name string `json:"name"`
+				} `json:"version"`
+			} `json:"response"`
+		} `json:"status"`
+	}
+	if err = json.Unmarshal(stdout.Bytes(), &res); err != nil {
+		t.Fatalf("could not decode JSON output: %s", err)
+	}
+	if res.Protocol != "1.7+" || res.OnlinePlayers != 3 || res.Host != server17.Host || res.Port != server17.Port ||
+		res.Status.Response.Version.Name != "1.20.1" {
+		t.Errorf("unexpected JSON output %s", stdout.String())
+	}
+
+	// Favicon of 1.7+ server is written to file
+	dir, err := ioutil.TempDir("", "minequery")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	path := filepath.Join(dir, "icon.png")
+
+	stdout.Reset()
+	stderr.Reset()
+	args = []string{"ping", "-srv=false", "-protocol", "1.7", "-favicon", path, server17.Addr()}
+	if code := run(args, &stdout, &stderr); code != exitOK {
+		t.Fatalf("expected exit code %d, got %d (stderr: %s)", exitOK, code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "1.20.1") {
+		t.Errorf("expected text output to contain version name, got %q", stdout.String())
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	written, err := png.Decode(file)
+	_ = file.Close()
+	if err != nil {
+		t.Fatalf("could not decode favicon: %s", err)
+	}
+	if written.Bounds() != icon.Bounds() || written.At(1, 2) != icon.At(1, 2) {
+		t.Errorf("written favicon doesn't match server favicon")
+	}
+}
+
+func TestRunPingFaviconLegacy(t *testing.T) {
+	server16, err := minequerytest.NewPing16Server(&minequery.Status16{
+		ProtocolVersion: 78,
+		ServerVersion:   "1.6.4",
+		MOTD:            "A Minecraft Server",
+		OnlinePlayers:   3,
+		MaxPlayers:      20,
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = server16.Close() }()
+
+	dir, err := ioutil.TempDir("", "minequery")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	path := filepath.Join(dir, "icon.png")
+
+	// Server without favicon is reported, but ping doesn't fail
+	var stdout, stderr bytes.Buffer
+	args := []string{"ping", "-srv=false", "-favicon", path, server16.Addr()}
+	if code := run(args, &stdout, &stderr); code != exitOK {
+		t.Fatalf("expected exit code %d, got %d (stderr: %s)", exitOK, code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "1.6.4") {
+		t.Errorf("expected text output to contain version name, got %q", stdout.String())
+	}
+	if !strings.Contains(stderr.String(), "did not send favicon") {
+		t.Errorf("expected missing favicon warning, got %q", stderr.String())
+	}
+	if _, err = os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("expected favicon file not to be written, got %v", err)
+	}
+}
+
+func TestRunQuery(t *testing.T) {
+	server, err := minequerytest.NewQueryServer(&minequery.FullQueryStatus{
+		MOTD:          "A Minecraft Server",
+		GameType:      "SMP",
+		GameID:        "MINECRAFT",
+		Version:       "1.20.1",
+		Map:           "world",
+		OnlinePlayers: 1,
+		MaxPlayers:    20,
+		SamplePlayers: []string{"alice"},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = server.Close() }()
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"query", "-o", "json", server.Addr()}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("expected exit code %d, got %d (stderr: %s)", exitOK, code, stderr.String())
+	}
+	var res minequery.FullQueryStatus
+	if err = json.Unmarshal(stdout.Bytes(), &res); err != nil {
+		t.Fatalf("could not decode JSON output: %s", err)
+	}
+	if res.Version != "1.20.1" || len(res.SamplePlayers) != 1 || res.SamplePlayers[0] != "alice" {
+		t.Errorf("unexpected JSON output %s", stdout.String())
+	}
+}
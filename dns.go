@@ -0,0 +1,487 @@
+package minequery
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	dnsTypeA     uint16 = 1
+	dnsTypeCNAME uint16 = 5
+	dnsTypeAAAA  uint16 = 28
+	dnsTypeSRV   uint16 = 33
+	dnsClassINET uint16 = 1
+
+	dnsFlagResponse           = 1 << 15
+	dnsFlagTruncated          = 1 << 9
+	dnsFlagRecursionDesired   = 1 << 8
+	dnsRCodeMask              = 0xf
+	dnsRCodeSuccess           = 0
+	dnsRCodeNameError         = 3
+	dnsHeaderLength           = 12
+	dnsMaxUDPMessageLength    = 512
+	dnsMaxNameLength          = 255
+	dnsMaxLabelLength         = 63
+	dnsCompressionPointerMask = 0xc0
+	dnsMaxCompressionPointers = 16
+
+	defaultDNSTimeout = 5 * time.Second
+	dnsResolvConfPath = "/etc/resolv.conf"
+)
+
+// errDNSMalformed is returned when DNS response can't be parsed.
+var errDNSMalformed = errors.New("malformed DNS response")
+
+// dnsDefaultServers are DNS servers queried if none are configured in resolv.conf.
+var dnsDefaultServers = []string{"127.0.0.1:53", "[::1]:53"}
+
+// DNSResolver is a minimal stub resolver looking up SRV, A and AAAA records directly with DNS servers
+// over UDP (retrying over TCP if response is truncated), which reports record TTLs. It implements
+// TTLResolver, so CachingResolver caches its results for as long as records are valid:
+//
+//	resolver := minequery.NewCachingResolver(&minequery.DNSResolver{}, time.Minute)
+//
+// Unlike *net.Resolver, it doesn't consult hosts file and doesn't apply search domains, so names are
+// always looked up as fully qualified ones ("localhost" is the only exception, which always resolves
+// to loopback addresses.) Zero value is ready to use, and it is safe for concurrent use.
+type DNSResolver struct {
+	// Servers are addresses (host:port) of DNS servers queried in order until one of them answers.
+	// By default (if empty), nameservers listed in /etc/resolv.conf are used (or local DNS server,
+	// if there are none.)
+	Servers []string
+
+	// Timeout is the maximum time a single DNS server is waited for.
+	// By default, it is 5 seconds.
+	Timeout time.Duration
+
+	once    sync.Once
+	servers []string
+}
+
+// LookupSRV looks up SRV records of _service._proto.name, see net.Resolver LookupSRV.
+func (r *DNSResolver) LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+	cname, records, _, err := r.LookupSRVTTL(ctx, service, proto, name)
+	return cname, records, err
+}
+
+// LookupSRVTTL is the same as LookupSRV, but also returns the smallest TTL of the records.
+func (r *DNSResolver) LookupSRVTTL(ctx context.Context, service, proto, name string) (string, []*net.SRV, time.Duration, error) {
+	target := "_" + service + "._" + proto + "." + name
+	answer, err := r.lookup(ctx, target, dnsTypeSRV)
+	if err != nil {
+		return "", nil, 0, err
+	}
+
+	records := make([]*net.SRV, 0, len(answer.records))
+	for _, data := range answer.records {
+		records = append(records, data.(*net.SRV))
+	}
+	if len(records) == 0 {
+		return "", nil, 0, &net.DNSError{Err: "no such host", Name: target, IsNotFound: true}
+	}
+	return answer.cname, records, answer.ttl, nil
+}
+
+// LookupIPAddr looks up IPv4 and IPv6 addresses of host, see net.Resolver LookupIPAddr.
+func (r *DNSResolver) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	addrs, _, err := r.LookupIPAddrTTL(ctx, host)
+	return addrs, err
+}
+
+// LookupIPAddrTTL is the same as LookupIPAddr, but also returns the smallest TTL of the records.
+// A and AAAA records are looked up concurrently; IPv4 addresses are listed first.
+func (r *DNSResolver) LookupIPAddrTTL(ctx context.Context, host string) ([]net.IPAddr, time.Duration, error) {
+	// IP addresses and localhost are not looked up, so they are cached for CachingResolver TTL
+	if ip := net.ParseIP(host); ip != nil {
+		return []net.IPAddr{{IP: ip}}, -1, nil
+	}
+	if strings.EqualFold(strings.TrimSuffix(host, "."), "localhost") {
+		return []net.IPAddr{{IP: net.IPv4(127, 0, 0, 1)}, {IP: net.IPv6loopback}}, -1, nil
+	}
+
+	// Look up A and AAAA records concurrently
+	types := []uint16{dnsTypeA, dnsTypeAAAA}
+	answers := make([]*dnsAnswer, len(types))
+	errs := make([]error, len(types))
+	var wg sync.WaitGroup
+	for i, recordType := range types {
+		wg.Add(1)
+		go func(i int, recordType uint16) {
+			defer wg.Done()
+			answers[i], errs[i] = r.lookup(ctx, host, recordType)
+		}(i, recordType)
+	}
+	wg.Wait()
+
+	// Collect addresses of both lookups; lookup error is only returned if neither has addresses
+	var addrs []net.IPAddr
+	ttl := time.Duration(-1)
+	for i, answer := range answers {
+		if errs[i] != nil {
+			continue
+		}
+		for _, data := range answer.records {
+			addrs = append(addrs, net.IPAddr{IP: data.(net.IP)})
+		}
+		if len(answer.records) > 0 && (ttl < 0 || answer.ttl < ttl) {
+			ttl = answer.ttl
+		}
+	}
+	if len(addrs) == 0 {
+		for _, err := range errs {
+			if err != nil {
+				return nil, 0, err
+			}
+		}
+		return nil, 0, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	return addrs, ttl, nil
+}
+
+// dnsAnswer holds records of the requested type parsed from DNS response.
+type dnsAnswer struct {
+	// cname is the canonical name of the looked up name (with trailing dot.)
+	cname string
+
+	// records hold net.IP for A and AAAA records and *net.SRV for SRV records.
+	records []interface{}
+
+	// ttl is the smallest TTL of records and CNAME records leading to them.
+	ttl time.Duration
+}
+
+// lookup queries DNS servers for records of recordType of name until one of them answers.
+func (r *DNSResolver) lookup(ctx context.Context, name string, recordType uint16) (*dnsAnswer, error) {
+	query, id, err := dnsEncodeQuery(name, recordType)
+	if err != nil {
+		return nil, &net.DNSError{Err: err.Error(), Name: name}
+	}
+
+	var lastErr error
+	for _, server := range r.resolvConfServers() {
+		answer, err := r.exchange(ctx, server, query, id, recordType)
+		if err == nil {
+			return answer, nil
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+
+		// Name error is an authoritative answer, there's no need to ask other servers
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+			dnsErr.Name, dnsErr.Server = name, server
+			return nil, dnsErr
+		}
+		lastErr = err
+	}
+	return nil, &net.DNSError{Err: lastErr.Error(), Name: name, IsTemporary: true}
+}
+
+// exchange sends query to server over UDP, retrying over TCP if response is truncated, and parses response.
+func (r *DNSResolver) exchange(ctx context.Context, server string, query []byte, id uint16, recordType uint16) (*dnsAnswer, error) {
+	timeout := r.Timeout
+	if timeout <= 0 {
+		timeout = defaultDNSTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	response, err := dnsExchangeUDP(ctx, server, query, id)
+	if err != nil {
+		return nil, err
+	}
+	if binary.BigEndian.Uint16(response[2:4])&dnsFlagTruncated != 0 {
+		if response, err = dnsExchangeTCP(ctx, server, query, id); err != nil {
+			return nil, err
+		}
+	}
+	return dnsParseResponse(response, recordType)
+}
+
+// resolvConfServers returns DNSResolver Servers, or nameservers of resolv.conf if there are none.
+func (r *DNSResolver) resolvConfServers() []string {
+	if len(r.Servers) > 0 {
+		return r.Servers
+	}
+	r.once.Do(func() {
+		r.servers = dnsReadResolvConf(dnsResolvConfPath)
+		if len(r.servers) == 0 {
+			r.servers = dnsDefaultServers
+		}
+	})
+	return r.servers
+}
+
+// dnsReadResolvConf returns addresses of nameservers listed in resolv.conf at path.
+func dnsReadResolvConf(path string) []string {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer func() { _ = file.Close() }()
+
+	var servers []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "nameserver" {
+			if ip := net.ParseIP(fields[1]); ip != nil {
+				servers = append(servers, net.JoinHostPort(ip.String(), "53"))
+			}
+		}
+	}
+	return servers
+}
+
+// Communication
+
+func dnsExchangeUDP(ctx context.Context, server string, query []byte, id uint16) ([]byte, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "udp", server)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = conn.Close() }()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	if _, err = conn.Write(query); err != nil {
+		return nil, err
+	}
+
+	// Skip datagrams that are not responses to this query (such as late responses to earlier ones)
+	b := make([]byte, dnsMaxUDPMessageLength)
+	for {
+		n, err := conn.Read(b)
+		if err != nil {
+			return nil, err
+		}
+		if n >= dnsHeaderLength && binary.BigEndian.Uint16(b[0:2]) == id {
+			return b[:n], nil
+		}
+	}
+}
+
+func dnsExchangeTCP(ctx context.Context, server string, query []byte, id uint16) ([]byte, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", server)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = conn.Close() }()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	// Messages over TCP are prefixed with 2-byte length
+	packet := make([]byte, 2, 2+len(query))
+	binary.BigEndian.PutUint16(packet, uint16(len(query)))
+	if _, err = conn.Write(append(packet, query...)); err != nil {
+		return nil, err
+	}
+
+	length := make([]byte, 2)
+	if _, err = io.ReadFull(conn, length); err != nil {
+		return nil, err
+	}
+	response := make([]byte, binary.BigEndian.Uint16(length))
+	if _, err = io.ReadFull(conn, response); err != nil {
+		return nil, err
+	}
+	if len(response) < dnsHeaderLength || binary.BigEndian.Uint16(response[0:2]) != id {
+		return nil, errDNSMalformed
+	}
+	return response, nil
+}
+
+// Query encoding
+
+// dnsEncodeQuery encodes recursive query for records of recordType of name with random ID.
+func dnsEncodeQuery(name string, recordType uint16) ([]byte, uint16, error) {
+	idBytes := make([]byte, 2)
+	_, _ = rand.Read(idBytes)
+	id := binary.BigEndian.Uint16(idBytes)
+
+	// Write header: ID, flags and section counts (a single question)
+	query := make([]byte, dnsHeaderLength, dnsHeaderLength+len(name)+6)
+	binary.BigEndian.PutUint16(query[0:2], id)
+	binary.BigEndian.PutUint16(query[2:4], dnsFlagRecursionDesired)
+	binary.BigEndian.PutUint16(query[4:6], 1)
+
+	// Write question: name, type and class
+	query, err := dnsAppendName(query, name)
+	if err != nil {
+		return nil, 0, err
+	}
+	query = append(query, byte(recordType>>8), byte(recordType), byte(dnsClassINET>>8), byte(dnsClassINET))
+	return query, id, nil
+}
+
+// dnsAppendName appends name encoded as a sequence of length-prefixed labels to b.
+func dnsAppendName(b []byte, name string) ([]byte, error) {
+	name = strings.TrimSuffix(name, ".")
+	if len(name)+2 > dnsMaxNameLength {
+		return nil, fmt.Errorf("name %q is too long", name)
+	}
+	if name != "" {
+		for _, label := range strings.Split(name, ".") {
+			if label == "" || len(label) > dnsMaxLabelLength {
+				return nil, fmt.Errorf("name %q has invalid label", name)
+			}
+			b = append(append(b, byte(len(label))), label...)
+		}
+	}
+	return append(b, 0), nil
+}
+
+// Response processing
+
+// dnsParseResponse parses records of recordType from answer section of response.
+func dnsParseResponse(response []byte, recordType uint16) (*dnsAnswer, error) {
+	if len(response) < dnsHeaderLength {
+		return nil, errDNSMalformed
+	}
+	flags := binary.BigEndian.Uint16(response[2:4])
+	if flags&dnsFlagResponse == 0 {
+		return nil, errDNSMalformed
+	}
+	switch flags & dnsRCodeMask {
+	case dnsRCodeSuccess:
+	case dnsRCodeNameError:
+		return nil, &net.DNSError{Err: "no such host", IsNotFound: true}
+	default:
+		return nil, fmt.Errorf("DNS server responded with error code %d", flags&dnsRCodeMask)
+	}
+	questions := int(binary.BigEndian.Uint16(response[4:6]))
+	answers := int(binary.BigEndian.Uint16(response[6:8]))
+
+	// Skip question section, remembering the name that was asked about
+	offset := dnsHeaderLength
+	var cname string
+	for i := 0; i < questions; i++ {
+		name, next, err := dnsReadName(response, offset)
+		if err != nil {
+			return nil, err
+		}
+		if next+4 > len(response) {
+			return nil, errDNSMalformed
+		}
+		cname, offset = name, next+4
+	}
+
+	// Read answer section, collecting records of requested type and following CNAME records
+	answer := &dnsAnswer{cname: cname, ttl: -1}
+	for i := 0; i < answers; i++ {
+		_, next, err := dnsReadName(response, offset)
+		if err != nil {
+			return nil, err
+		}
+		if next+10 > len(response) {
+			return nil, errDNSMalformed
+		}
+		rrType := binary.BigEndian.Uint16(response[next : next+2])
+		ttl := time.Duration(binary.BigEndian.Uint32(response[next+4:next+8])) * time.Second
+		length := int(binary.BigEndian.Uint16(response[next+8 : next+10]))
+		data := next + 10
+		if data+length > len(response) {
+			return nil, errDNSMalformed
+		}
+		offset = data + length
+
+		var record interface{}
+		switch {
+		case rrType == dnsTypeCNAME:
+			if cname, _, err = dnsReadName(response, data); err != nil {
+				return nil, err
+			}
+			answer.cname = cname
+		case rrType != recordType:
+			continue
+		case rrType == dnsTypeA && length == net.IPv4len:
+			record = net.IP(append([]byte(nil), response[data:data+length]...))
+		case rrType == dnsTypeAAAA && length == net.IPv6len:
+			record = net.IP(append([]byte(nil), response[data:data+length]...))
+		case rrType == dnsTypeSRV && length > 6:
+			target, _, err := dnsReadName(response, data+6)
+			if err != nil {
+				return nil, err
+			}
+			record = &net.SRV{
+				Priority: binary.BigEndian.Uint16(response[data : data+2]),
+				Weight:   binary.BigEndian.Uint16(response[data+2 : data+4]),
+				Port:     binary.BigEndian.Uint16(response[data+4 : data+6]),
+				Target:   target,
+			}
+		default:
+			return nil, errDNSMalformed
+		}
+
+		if record != nil {
+			answer.records = append(answer.records, record)
+		}
+		if answer.ttl < 0 || ttl < answer.ttl {
+			answer.ttl = ttl
+		}
+	}
+	if len(answer.records) == 0 {
+		return nil, &net.DNSError{Err: "no such host", IsNotFound: true}
+	}
+	return answer, nil
+}
+
+// dnsReadName reads (possibly compressed) name at offset of message, returning it with trailing dot
+// and offset following it.
+func dnsReadName(message []byte, offset int) (string, int, error) {
+	var name strings.Builder
+	next, pointers := -1, 0
+	for {
+		if offset >= len(message) {
+			return "", 0, errDNSMalformed
+		}
+		length := int(message[offset])
+		switch {
+		case length == 0:
+			if next < 0 {
+				next = offset + 1
+			}
+			if name.Len() == 0 {
+				name.WriteByte('.')
+			}
+			return name.String(), next, nil
+
+		case length&dnsCompressionPointerMask == dnsCompressionPointerMask:
+			// Pointer to name elsewhere in message; limit the number of pointers to break loops
+			if offset+1 >= len(message) || pointers >= dnsMaxCompressionPointers {
+				return "", 0, errDNSMalformed
+			}
+			if next < 0 {
+				next = offset + 2
+			}
+			offset = int(binary.BigEndian.Uint16(message[offset:offset+2]) &^ (dnsCompressionPointerMask << 8))
+			pointers++
+
+		case length&dnsCompressionPointerMask != 0:
+			return "", 0, errDNSMalformed
+
+		default:
+			if offset+1+length > len(message) || name.Len()+length+1 > dnsMaxNameLength {
+				return "", 0, errDNSMalformed
+			}
+			name.Write(message[offset+1 : offset+1+length])
+			name.WriteByte('.')
+			offset += 1 + length
+		}
+	}
+}
@@ -0,0 +1,247 @@
+package minequery
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testDNSRecord is a resource record fake DNS server answers with.
+type testDNSRecord struct {
+	rrType uint16
+	ttl    uint32
+	data   []byte
+}
+
+// testDNSServer is a fake DNS server answering over UDP and TCP on the same port.
+type testDNSServer struct {
+	addr    string
+	udp     net.PacketConn
+	tcp     net.Listener
+	mu      sync.Mutex
+	queries int
+}
+
+func (s *testDNSServer) Close() {
+	_ = s.udp.Close()
+	_ = s.tcp.Close()
+}
+
+func (s *testDNSServer) Queries() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.queries
+}
+
+// startTestDNSServer starts fake DNS server answering queries with records returned by answer.
+// If truncate is set, UDP responses are truncated, so that client has to retry over TCP.
+func startTestDNSServer(
+	t *testing.T, truncate bool, answer func(name string, rrType uint16) (int, []testDNSRecord),
+) *testDNSServer {
+	t.Helper()
+	udp, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := udp.LocalAddr().(*net.UDPAddr).Port
+	tcp, err := net.Listen("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(port)))
+	if err != nil {
+		_ = udp.Close()
+		t.Skipf("could not listen on TCP port of UDP listener: %s", err)
+	}
+
+	s := &testDNSServer{addr: udp.LocalAddr().String(), udp: udp, tcp: tcp}
+	respond := func(query []byte, truncated bool) []byte {
+		s.mu.Lock()
+		s.queries++
+		s.mu.Unlock()
+
+		name, next, err := dnsReadName(query, dnsHeaderLength)
+		if err != nil {
+			return nil
+		}
+		rrType := binary.BigEndian.Uint16(query[next : next+2])
+		rcode, records := answer(name, rrType)
+		return testDNSResponse(query[:next+4], rcode, records, truncated)
+	}
+
+	go func() {
+		b := make([]byte, dnsMaxUDPMessageLength)
+		for {
+			n, addr, err := udp.ReadFrom(b)
+			if err != nil {
+				return
+			}
+			if response := respond(b[:n], truncate); response != nil {
+				_, _ = udp.WriteTo(response, addr)
+			}
+		}
+	}()
+	go func() {
+		for {
+			conn, err := tcp.Accept()
+			if err != nil {
+				return
+			}
+			length := make([]byte, 2)
+			if _, err = io.ReadFull(conn, length); err == nil {
+				query := make([]byte, binary.BigEndian.Uint16(length))
+				if _, err = io.ReadFull(conn, query); err == nil {
+					response := respond(query, false)
+					binary.BigEndian.PutUint16(length, uint16(len(response)))
+					_, _ = conn.Write(append(length, response...))
+				}
+			}
+			_ = conn.Close()
+		}
+	}()
+	return s
+}
+
+// testDNSResponse builds response to question (header and question section of query) with records,
+// owner names of which point to the question name.
+func testDNSResponse(question []byte, rcode int, records []testDNSRecord, truncated bool) []byte {
+	response := append([]byte(nil), question...)
+	flags := uint16(dnsFlagResponse|dnsFlagRecursionDesired) | uint16(rcode)
+	if truncated {
+		flags |= dnsFlagTruncated
+		records = nil
+	}
+	binary.BigEndian.PutUint16(response[2:4], flags)
+	binary.BigEndian.PutUint16(response[6:8], uint16(len(records)))
+
+	for _, record := range records {
+		rr := make([]byte, 12)
+		binary.BigEndian.PutUint16(rr[0:2], 0xc000|dnsHeaderLength)
+		binary.BigEndian.PutUint16(rr[2:4], record.rrType)
+		binary.BigEndian.PutUint16(rr[4:6], dnsClassINET)
+		binary.BigEndian.PutUint32(rr[6:10], record.ttl)
+		binary.BigEndian.PutUint16(rr[10:12], uint16(len(record.data)))
+		response = append(append(response, rr...), record.data...)
+	}
+	return response
+}
+
+func testDNSSRVData(port uint16, target string) []byte {
+	data := make([]byte, 6)
+	binary.BigEndian.PutUint16(data[0:2], 10)
+	binary.BigEndian.PutUint16(data[2:4], 5)
+	binary.BigEndian.PutUint16(data[4:6], port)
+	data, _ = dnsAppendName(data, target)
+	return data
+}
+
+func testDNSName(name string) []byte {
+	data, _ := dnsAppendName(nil, name)
+	return data
+}
+
+func TestDNSResolverLookupIPAddr(t *testing.T) {
+	server := startTestDNSServer(t, false, func(name string, rrType uint16) (int, []testDNSRecord) {
+		switch rrType {
+		case dnsTypeA:
+			return dnsRCodeSuccess, []testDNSRecord{{rrType: dnsTypeA, ttl: 300, data: []byte{203, 0, 113, 7}}}
+		case dnsTypeAAAA:
+			return dnsRCodeSuccess, []testDNSRecord{{rrType: dnsTypeAAAA, ttl: 60, data: net.ParseIP("2001:db8::7")}}
+		}
+		return dnsRCodeSuccess, nil
+	})
+	defer server.Close()
+
+	resolver := &DNSResolver{Servers: []string{server.addr}, Timeout: time.Second}
+	addrs, ttl, err := resolver.LookupIPAddrTTL(context.Background(), "play.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(addrs) != 2 || !addrs[0].IP.Equal(net.IPv4(203, 0, 113, 7)) || !addrs[1].IP.Equal(net.ParseIP("2001:db8::7")) {
+		t.Errorf("unexpected addresses %v", addrs)
+	}
+	if ttl != time.Minute {
+		t.Errorf("expected TTL %s, got %s", time.Minute, ttl)
+	}
+}
+
+func TestDNSResolverLookupSRV(t *testing.T) {
+	for _, truncate := range []bool{false, true} {
+		server := startTestDNSServer(t, truncate, func(name string, rrType uint16) (int, []testDNSRecord) {
+			if name != "_minecraft._tcp.example.com." || rrType != dnsTypeSRV {
+				return dnsRCodeNameError, nil
+			}
+			return dnsRCodeSuccess, []testDNSRecord{
+				{rrType: dnsTypeCNAME, ttl: 30, data: testDNSName("srv.example.net")},
+				{rrType: dnsTypeSRV, ttl: 300, data: testDNSSRVData(25566, "mc.example.net")},
+			}
+		})
+		defer server.Close()
+
+		resolver := &DNSResolver{Servers: []string{server.addr}, Timeout: time.Second}
+		cname, records, ttl, err := resolver.LookupSRVTTL(context.Background(), "minecraft", "tcp", "example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cname != "srv.example.net." {
+			t.Errorf("expected CNAME srv.example.net., got %s", cname)
+		}
+		if len(records) != 1 || records[0].Target != "mc.example.net." || records[0].Port != 25566 ||
+			records[0].Priority != 10 || records[0].Weight != 5 {
+			t.Errorf("unexpected records %+v", records)
+		}
+		if ttl != 30*time.Second {
+			t.Errorf("expected TTL of CNAME record, got %s", ttl)
+		}
+	}
+}
+
+func TestDNSResolverNotFound(t *testing.T) {
+	server := startTestDNSServer(t, false, func(string, uint16) (int, []testDNSRecord) {
+		return dnsRCodeNameError, nil
+	})
+	defer server.Close()
+
+	resolver := &DNSResolver{Servers: []string{server.addr}, Timeout: time.Second}
+	_, err := resolver.LookupIPAddr(context.Background(), "missing.example.com")
+	var dnsErr *net.DNSError
+	if !errors.As(err, &dnsErr) || !dnsErr.IsNotFound {
+		t.Errorf("expected not found error, got %v", err)
+	}
+}
+
+func TestCachingResolverRecordTTL(t *testing.T) {
+	var ttl uint32
+	server := startTestDNSServer(t, false, func(name string, rrType uint16) (int, []testDNSRecord) {
+		if rrType != dnsTypeA {
+			return dnsRCodeSuccess, nil
+		}
+		return dnsRCodeSuccess, []testDNSRecord{{rrType: dnsTypeA, ttl: atomic.LoadUint32(&ttl), data: []byte{203, 0, 113, 7}}}
+	})
+	defer server.Close()
+	resolver := NewCachingResolver(&DNSResolver{Servers: []string{server.addr}, Timeout: time.Second}, time.Hour)
+
+	// Zero TTL records are not cached, despite CachingResolver TTL
+	for i := 0; i < 2; i++ {
+		if _, err := resolver.LookupIPAddr(context.Background(), "zero.example.com"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if queries := server.Queries(); queries != 4 {
+		t.Errorf("expected zero TTL records to be looked up every time, got %d queries", queries)
+	}
+
+	// Records with TTL are cached
+	atomic.StoreUint32(&ttl, 300)
+	for i := 0; i < 2; i++ {
+		if _, err := resolver.LookupIPAddr(context.Background(), "cached.example.com"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if queries := server.Queries(); queries != 6 {
+		t.Errorf("expected records to be looked up once, got %d queries", queries-4)
+	}
+}
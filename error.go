@@ -2,8 +2,33 @@ package minequery
 
 import (
 	"errors"
+	"fmt"
 )
 
 // ErrInvalidStatus wraps errors occurred during ping status deserialization.
 // Some errors may be ignored if UseStrict is not set to true.
 var ErrInvalidStatus = errors.New("invalid status")
+
+// ErrProtocolNotDetected is returned by Ping when server did not respond to any of the known
+// ping protocols. Its message lists errors returned by each attempted protocol.
+var ErrProtocolNotDetected = errors.New("server did not respond to any ping protocol")
+
+// ErrSRVTargetsFailed is returned by ping functions when UseStrict is set and none of the SRV record
+// targets of hostname responded. Its message lists errors returned by each attempted target.
+var ErrSRVTargetsFailed = errors.New("none of SRV record targets responded")
+
+// invalidStatus wraps error returned by protocol package decoders with ErrInvalidStatus,
+// keeping the error chain of err, so that both can be matched with errors.Is and errors.As.
+func invalidStatus(err error) error {
+	return &invalidStatusError{err}
+}
+
+// invalidStatusError is ErrInvalidStatus caused by err.
+type invalidStatusError struct{ err error }
+
+func (e *invalidStatusError) Error() string { return fmt.Sprintf("%s: %s", ErrInvalidStatus, e.err) }
+
+// Is reports whether target is ErrInvalidStatus; causes are matched through Unwrap.
+func (e *invalidStatusError) Is(target error) bool { return target == ErrInvalidStatus }
+
+func (e *invalidStatusError) Unwrap() error { return e.err }
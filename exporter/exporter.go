@@ -0,0 +1,297 @@
+// Package exporter provides an http.Handler exposing status of Minecraft servers as Prometheus metrics.
+//
+// Metrics are written in Prometheus text exposition format with the standard library only,
+// so that using the exporter doesn't pull Prometheus client library in.
+package exporter
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dreamscached/minequery/v2"
+)
+
+const contentType = "text/plain; version=0.0.4; charset=utf-8"
+
+const defaultScrapeTimeout = 10 * time.Second
+
+// Handler is an http.Handler that pings (and optionally queries) configured targets and responds
+// with their status as Prometheus metrics.
+//
+// By default, targets are pinged on each scrape. If Run is running, scrapes are served from results
+// of its last refresh instead, which keeps scrapes fast and server load independent of scrape rate.
+type Handler struct {
+	// Pinger is used to ping and query targets.
+	Pinger *minequery.Pinger
+
+	// Targets are the servers to be monitored.
+	Targets []minequery.Target
+
+	// Query defines if targets are also queried with QueryFull for Query-derived metrics.
+	Query bool
+
+	// Concurrency is the number of targets pinged at a time.
+	// By default (if zero), it is the default of Pinger PingMany.
+	Concurrency int
+
+	// ScrapeTimeout limits the time targets are pinged for on scrape.
+	// By default, it is 10 seconds.
+	ScrapeTimeout time.Duration
+
+	mu       sync.Mutex
+	snapshot []targetResult
+	cached   bool
+}
+
+// targetResult holds results of pinging and querying a single target.
+type targetResult struct {
+	Target minequery.Target
+	Ping   minequery.PingResult
+	Query  *minequery.QueryResult
+}
+
+// NewHandler constructs new Handler instance monitoring targets. If pinger is nil, a Pinger with
+// default options and latency measurement enabled is used.
+//
+//goland:noinspection GoUnusedExportedFunction
+func NewHandler(pinger *minequery.Pinger, targets ...minequery.Target) *Handler {
+	if pinger == nil {
+		pinger = minequery.NewPinger(minequery.WithMeasureLatency(true))
+	}
+	return &Handler{Pinger: pinger, Targets: targets, ScrapeTimeout: defaultScrapeTimeout}
+}
+
+// Run refreshes status of targets every interval until ctx is done and returns ctx error.
+// While Run is running, scrapes are served from results of the last refresh.
+func (h *Handler) Run(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		results := h.collect(ctx)
+		if ctx.Err() == nil {
+			h.mu.Lock()
+			h.snapshot, h.cached = results, true
+			h.mu.Unlock()
+		}
+
+		select {
+		case <-ctx.Done():
+			h.mu.Lock()
+			h.snapshot, h.cached = nil, false
+			h.mu.Unlock()
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// ServeHTTP writes metrics of targets in Prometheus text exposition format.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mu.Lock()
+	results, cached := h.snapshot, h.cached
+	h.mu.Unlock()
+
+	if !cached {
+		timeout := h.ScrapeTimeout
+		if timeout <= 0 {
+			timeout = defaultScrapeTimeout
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+		results = h.collect(ctx)
+	}
+
+	var b bytes.Buffer
+	writeMetrics(&b, results, h.Query)
+	w.Header().Set("Content-Type", contentType)
+	_, _ = b.WriteTo(w)
+}
+
+// collect pings (and queries, if enabled) all targets.
+func (h *Handler) collect(ctx context.Context) []targetResult {
+	pinger := h.Pinger
+	if pinger == nil {
+		pinger = minequery.NewPinger()
+	}
+
+	results := make([]targetResult, len(h.Targets))
+	for i, target := range h.Targets {
+		results[i].Target = target
+	}
+
+	pinger.PingManyFunc(ctx, h.Targets, h.Concurrency, func(result minequery.PingResult) {
+		results[result.Index].Ping = result
+	})
+	if h.Query {
+		pinger.QueryManyFunc(ctx, h.Targets, h.Concurrency, func(result minequery.QueryResult) {
+			results[result.Index].Query = &result
+		})
+	}
+	return results
+}
+
+// Exposition format
+
+// metric describes a single metric family and how its value is obtained from target result.
+// Every sample is labeled with target, and with Labels (if set) in addition to it.
+type metric struct {
+	Name   string
+	Help   string
+	Query  bool
+	Labels func(result targetResult) []label
+	Value  func(result targetResult) (float64, bool)
+}
+
+// label is a name-value pair of a sample label.
+type label struct {
+	Name  string
+	Value string
+}
+
+var metrics = []metric{
+	{
+		Name: "minecraft_up",
+		Help: "Whether the server responded to ping (1) or not (0).",
+		Value: func(result targetResult) (float64, bool) {
+			return boolValue(result.Ping.Err == nil), true
+		},
+	},
+	{
+		Name: "minecraft_info",
+		Help: "Ping protocol the server responded to as protocol label, value is always 1.",
+		Labels: func(result targetResult) []label {
+			return []label{{Name: "protocol", Value: result.Ping.Status.Protocol.String()}}
+		},
+		Value: func(result targetResult) (float64, bool) {
+			return 1, result.Ping.Status != nil
+		},
+	},
+	{
+		Name: "minecraft_players_online",
+		Help: "Number of players online.",
+		Value: func(result targetResult) (float64, bool) {
+			if result.Ping.Status == nil {
+				return 0, false
+			}
+			return float64(result.Ping.Status.OnlinePlayers), true
+		},
+	},
+	{
+		Name: "minecraft_players_max",
+		Help: "Maximum number of players.",
+		Value: func(result targetResult) (float64, bool) {
+			if result.Ping.Status == nil {
+				return 0, false
+			}
+			return float64(result.Ping.Status.MaxPlayers), true
+		},
+	},
+	{
+		Name: "minecraft_protocol_version",
+		Help: "Protocol version of the server, -1 for servers older than 1.6.",
+		Value: func(result targetResult) (float64, bool) {
+			if result.Ping.Status == nil {
+				return 0, false
+			}
+			return float64(result.Ping.Status.ProtocolVersion), true
+		},
+	},
+	{
+		Name: "minecraft_latency_seconds",
+		Help: "Round-trip latency of 1.7+ ping/pong exchange, if measured.",
+		Value: func(result targetResult) (float64, bool) {
+			status := result.Ping.Status
+			if status == nil || status.Status17 == nil || status.Status17.Latency == 0 {
+				return 0, false
+			}
+			return status.Status17.Latency.Seconds(), true
+		},
+	},
+	{
+		Name: "minecraft_ping_duration_seconds",
+		Help: "Time the whole ping took, including SRV lookup and protocol negotiation.",
+		Value: func(result targetResult) (float64, bool) {
+			if result.Ping.Started.IsZero() || result.Ping.Duration == 0 {
+				return 0, false
+			}
+			return result.Ping.Duration.Seconds(), true
+		},
+	},
+	{
+		Name:  "minecraft_query_up",
+		Help:  "Whether the server responded to full query (1) or not (0).",
+		Query: true,
+		Value: func(result targetResult) (float64, bool) {
+			if result.Query == nil {
+				return 0, false
+			}
+			return boolValue(result.Query.Err == nil), true
+		},
+	},
+	{
+		Name:  "minecraft_query_plugins",
+		Help:  "Number of plugins reported by full query.",
+		Query: true,
+		Value: func(result targetResult) (float64, bool) {
+			if result.Query == nil || result.Query.Status == nil {
+				return 0, false
+			}
+			return float64(len(result.Query.Status.Plugins)), true
+		},
+	},
+}
+
+// writeMetrics writes metric families of all results in text exposition format.
+func writeMetrics(b *bytes.Buffer, results []targetResult, query bool) {
+	for _, m := range metrics {
+		if m.Query && !query {
+			continue
+		}
+
+		b.WriteString("# HELP " + m.Name + " " + m.Help + "\n")
+		b.WriteString("# TYPE " + m.Name + " gauge\n")
+		for _, result := range results {
+			value, ok := m.Value(result)
+			if !ok {
+				continue
+			}
+			b.WriteString(m.Name)
+			b.WriteString(`{target="` + escapeLabelValue(targetLabel(result.Target)) + `"`)
+			if m.Labels != nil {
+				for _, l := range m.Labels(result) {
+					b.WriteString("," + l.Name + `="` + escapeLabelValue(l.Value) + `"`)
+				}
+			}
+			b.WriteString("} ")
+			b.WriteString(strconv.FormatFloat(value, 'g', -1, 64))
+			b.WriteByte('\n')
+		}
+	}
+}
+
+func targetLabel(target minequery.Target) string {
+	if target.Port == 0 {
+		return target.Host
+	}
+	return net.JoinHostPort(target.Host, strconv.Itoa(target.Port))
+}
+
+var labelValueReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabelValue(s string) string {
+	return labelValueReplacer.Replace(s)
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,273 @@
+package minequery
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"unicode/utf16"
+)
+
+// ModVersionIgnoreServerOnly is a special mod version marker Forge servers send for server-only mods,
+// meaning that the mod does not need to be present on client.
+const ModVersionIgnoreServerOnly = "OHNOES\U0001F631\U0001F631\U0001F631\U0001F631"
+
+const (
+	// modLoaderFML is the mod loader name for FML1 (1.7 to 1.12) servers reporting mods in modinfo.
+	modLoaderFML = "FML"
+
+	// forgeOptimizedDataChannelSeparator separates mod ID and channel name in channel resource location.
+	forgeOptimizedDataChannelSeparator = ":"
+)
+
+var errForgeDataTruncated = errors.New("forge data is truncated")
+
+// ModEntry17 holds mod entry (ID and version) of modded server.
+type ModEntry17 struct {
+	ID      string
+	Version string
+}
+
+// ModChannel17 holds network channel entry of modded server.
+type ModChannel17 struct {
+	// Name is the channel resource location (namespace:path).
+	Name    string
+	Version string
+
+	// Required reports if channel must be present on client.
+	Required bool
+}
+
+// ModInfo17 holds mod loader information returned by modded (Forge and NeoForge) 1.7+ servers.
+type ModInfo17 struct {
+	// Loader is the mod loader type: FML (modinfo field, 1.7 to 1.12), or FML2, FML3 and so on
+	// (forgeData field, 1.13+, named by FML network version).
+	Loader string
+
+	// NetworkVersion is FML network protocol version (1 for FML).
+	NetworkVersion int
+
+	Mods     []ModEntry17
+	Channels []ModChannel17
+
+	// Truncated reports if server omitted some of the mods or channels to fit response size limit.
+	Truncated bool
+}
+
+// modInfo17JsonMapping is the modinfo field of status response (FML1).
+type modInfo17JsonMapping struct {
+	Type    string `json:"type"`
+	ModList []struct {
+		ModID   string `json:"modid"`
+		Version string `json:"version"`
+	} `json:"modList"`
+}
+
+// forgeData17JsonMapping is the forgeData field of status response (FML2+).
+type forgeData17JsonMapping struct {
+	Channels []struct {
+		Res      string `json:"res"`
+		Version  string `json:"version"`
+		Required bool   `json:"required"`
+	} `json:"channels"`
+	Mods []struct {
+		ModID     string `json:"modId"`
+		ModMarker string `json:"modmarker"`
+	} `json:"mods"`
+	FMLNetworkVersion int    `json:"fmlNetworkVersion"`
+	Truncated         bool   `json:"truncated,omitempty"`
+	D                 string `json:"d,omitempty"`
+}
+
+// ping17ParseModInfo maps modinfo or forgeData (whichever is present) to ModInfo17. If optimized
+// forgeData could not be decoded, mod info decoded so far is returned along with the error.
+func (p *Pinger) ping17ParseModInfo(modInfo *modInfo17JsonMapping, forgeData *forgeData17JsonMapping) (*ModInfo17, error) {
+	if forgeData != nil {
+		res := &ModInfo17{
+			Loader:         fmt.Sprintf("%s%d", modLoaderFML, forgeData.FMLNetworkVersion),
+			NetworkVersion: forgeData.FMLNetworkVersion,
+			Mods:           make([]ModEntry17, 0, len(forgeData.Mods)),
+			Channels:       make([]ModChannel17, 0, len(forgeData.Channels)),
+			Truncated:      forgeData.Truncated,
+		}
+		for _, mod := range forgeData.Mods {
+			res.Mods = append(res.Mods, ModEntry17{mod.ModID, mod.ModMarker})
+		}
+		for _, channel := range forgeData.Channels {
+			res.Channels = append(res.Channels, ModChannel17{channel.Res, channel.Version, channel.Required})
+		}
+
+		// Since 1.18, mods and channels are packed in binary form into d field
+		if forgeData.D != "" {
+			if err := decodeForgeOptimizedData(forgeData.D, res); err != nil {
+				return res, fmt.Errorf("%w: could not decode forge data: %s", ErrInvalidStatus, err)
+			}
+		}
+
+		return res, nil
+	}
+
+	if modInfo != nil {
+		res := &ModInfo17{
+			Loader:         modInfo.Type,
+			NetworkVersion: 1,
+			Mods:           make([]ModEntry17, 0, len(modInfo.ModList)),
+			Channels:       make([]ModChannel17, 0),
+		}
+		for _, mod := range modInfo.ModList {
+			res.Mods = append(res.Mods, ModEntry17{mod.ModID, mod.Version})
+		}
+		return res, nil
+	}
+
+	return nil, nil
+}
+
+// decodeForgeOptimizedData decodes mods and channels from optimized forgeData representation
+// (binary data packed into UTF-16 string 15 bits per character) and appends them to res.
+func decodeForgeOptimizedData(s string, res *ModInfo17) error {
+	data, err := unpackForgeOptimizedData(s)
+	if err != nil {
+		return err
+	}
+	reader := bytes.NewReader(data)
+
+	// Read truncated flag
+	truncated, err := readForgeBool(reader)
+	if err != nil {
+		return err
+	}
+	res.Truncated = res.Truncated || truncated
+
+	// Read mod count as unsigned short
+	var modCount uint16
+	if err = binary.Read(reader, binary.BigEndian, &modCount); err != nil {
+		return err
+	}
+
+	for i := 0; i < int(modCount); i++ {
+		// Read channel count and server-only flag packed into single VarInt
+		flags, err := binary.ReadUvarint(reader)
+		if err != nil {
+			return err
+		}
+		channelCount, ignoreServerOnly := flags>>1, flags&1 != 0
+
+		// Read mod ID and version (omitted for server-only mods)
+		modID, err := readForgeString(reader)
+		if err != nil {
+			return err
+		}
+		modVersion := ModVersionIgnoreServerOnly
+		if !ignoreServerOnly {
+			if modVersion, err = readForgeString(reader); err != nil {
+				return err
+			}
+		}
+
+		// Read mod channels, their names are relative to mod ID namespace
+		for j := uint64(0); j < channelCount; j++ {
+			channel, err := readForgeChannel(reader)
+			if err != nil {
+				return err
+			}
+			channel.Name = modID + forgeOptimizedDataChannelSeparator + channel.Name
+			res.Channels = append(res.Channels, channel)
+		}
+
+		res.Mods = append(res.Mods, ModEntry17{modID, modVersion})
+	}
+
+	// Read non-mod channels, their names are full resource locations
+	nonModChannelCount, err := binary.ReadUvarint(reader)
+	if err != nil {
+		return err
+	}
+	for i := uint64(0); i < nonModChannelCount; i++ {
+		channel, err := readForgeChannel(reader)
+		if err != nil {
+			return err
+		}
+		res.Channels = append(res.Channels, channel)
+	}
+
+	return nil
+}
+
+// unpackForgeOptimizedData unpacks binary data from UTF-16 string: the first two characters hold
+// data length (15 bits each, low bits first), and every next character holds 15 more bits of data.
+func unpackForgeOptimizedData(s string) ([]byte, error) {
+	chars := utf16.Encode([]rune(s))
+	if len(chars) < 2 {
+		return nil, errForgeDataTruncated
+	}
+	size := int(chars[0]) | int(chars[1])<<15
+	if size > ((len(chars)-2)*15+7)/8 {
+		// Announced length can't be held by the rest of string; reject it before allocating
+		return nil, errForgeDataTruncated
+	}
+
+	data := make([]byte, 0, size)
+	buffer, bits := 0, 0
+	for _, c := range chars[2:] {
+		for bits >= 8 && len(data) < size {
+			data = append(data, byte(buffer))
+			buffer >>= 8
+			bits -= 8
+		}
+		buffer |= (int(c) & 0x7fff) << bits
+		bits += 15
+	}
+
+	// Write leftover bits
+	for bits > 0 && len(data) < size {
+		data = append(data, byte(buffer))
+		buffer >>= 8
+		bits -= 8
+	}
+	if len(data) < size {
+		return nil, errForgeDataTruncated
+	}
+
+	return data, nil
+}
+
+func readForgeChannel(reader *bytes.Reader) (ModChannel17, error) {
+	name, err := readForgeString(reader)
+	if err != nil {
+		return ModChannel17{}, err
+	}
+	version, err := readForgeString(reader)
+	if err != nil {
+		return ModChannel17{}, err
+	}
+	required, err := readForgeBool(reader)
+	if err != nil {
+		return ModChannel17{}, err
+	}
+	return ModChannel17{name, version, required}, nil
+}
+
+func readForgeString(reader *bytes.Reader) (string, error) {
+	// Read string length as unsigned VarInt and the string itself as UTF-8
+	length, err := binary.ReadUvarint(reader)
+	if err != nil {
+		return "", err
+	} else if length > uint64(reader.Len()) {
+		return "", errForgeDataTruncated
+	}
+	b := make([]byte, length)
+	if _, err = io.ReadFull(reader, b); err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+func readForgeBool(reader *bytes.Reader) (bool, error) {
+	b, err := reader.ReadByte()
+	if err != nil {
+		return false, err
+	}
+	return b != 0, nil
+}
@@ -0,0 +1,117 @@
+package minequery
+
+import (
+	"io"
+	"sync"
+
+	"github.com/dreamscached/minequery/v2/protocol"
+)
+
+// ErrLimitExceeded is returned (wrapped in LimitError) when server sends more data than Pinger Limits allow.
+var ErrLimitExceeded = protocol.ErrLimitExceeded
+
+const (
+	defaultMaxPacketSize = protocol.DefaultMaxPacketSize
+
+	defaultMaxJSONSize         = 1 << 20
+	defaultMaxFaviconSize      = 1 << 20
+	defaultMaxFaviconDimension = 1024
+
+	// defaultMaxDatagramSize is the maximum size of UDP datagram payload.
+	defaultMaxDatagramSize = 65535
+)
+
+// Limits holds limits of data Pinger accepts from servers, which protect from hostile or broken
+// servers sending huge packets (or claiming they are going to.) Data exceeding limits is never
+// allocated, and LimitError is returned instead. Zero fields are replaced with defaults.
+type Limits struct {
+	// MaxPacketSize is the maximum length of TCP packet (1.7+ packet or pre-1.7 response.)
+	// By default, it is 2097151 bytes, the maximum packet length of vanilla servers.
+	MaxPacketSize int
+
+	// MaxJSONSize is the maximum size of 1.7+ status JSON.
+	// By default, it is 1 MiB.
+	MaxJSONSize int
+
+	// MaxFaviconSize is the maximum size of 1.7+ status favicon PNG data. Favicons exceeding
+	// favicon limits are dropped (leaving Status17 Icon nil) unless UseStrict is set.
+	// By default, it is 1 MiB.
+	MaxFaviconSize int
+
+	// MaxFaviconDimension is the maximum width and height of 1.7+ status favicon, which is checked
+	// before image is decoded. Vanilla favicons are 64x64. By default, it is 1024 pixels.
+	MaxFaviconDimension int
+
+	// MaxDatagramSize is the maximum size of UDP datagram (Query and Bedrock Edition responses.)
+	// By default, it is 65535 bytes, so that any datagram is accepted.
+	MaxDatagramSize int
+}
+
+// DefaultLimits returns Limits with default values.
+//
+//goland:noinspection GoUnusedExportedFunction
+func DefaultLimits() Limits {
+	return Limits{
+		MaxPacketSize:       defaultMaxPacketSize,
+		MaxJSONSize:         defaultMaxJSONSize,
+		MaxFaviconSize:      defaultMaxFaviconSize,
+		MaxFaviconDimension: defaultMaxFaviconDimension,
+		MaxDatagramSize:     defaultMaxDatagramSize,
+	}
+}
+
+// withDefaults returns limits with zero (or negative) fields replaced with defaults.
+func (l Limits) withDefaults() Limits {
+	defaults := DefaultLimits()
+	if l.MaxPacketSize <= 0 {
+		l.MaxPacketSize = defaults.MaxPacketSize
+	}
+	if l.MaxJSONSize <= 0 {
+		l.MaxJSONSize = defaults.MaxJSONSize
+	}
+	if l.MaxFaviconSize <= 0 {
+		l.MaxFaviconSize = defaults.MaxFaviconSize
+	}
+	if l.MaxFaviconDimension <= 0 {
+		l.MaxFaviconDimension = defaults.MaxFaviconDimension
+	}
+	if l.MaxDatagramSize <= 0 {
+		l.MaxDatagramSize = defaults.MaxDatagramSize
+	}
+	return l
+}
+
+// LimitError is returned when server sends more data than Pinger Limits allow.
+type LimitError = protocol.LimitError
+
+// checkLimit returns LimitError if size exceeds max.
+func checkLimit(limit string, size int64, max int) error {
+	return protocol.CheckLimit(limit, size, max)
+}
+
+// datagramBuffers holds buffers datagrams are read into, so that each read doesn't allocate
+// MaxDatagramSize bytes (64 KiB by default) for a response that is usually much smaller.
+var datagramBuffers = sync.Pool{New: func() interface{} { return new([]byte) }}
+
+// readDatagram reads a single datagram from conn, returning LimitError if it exceeds MaxDatagramSize.
+func (p *Pinger) readDatagram(conn io.Reader) ([]byte, error) {
+	// Buffer is one byte larger than limit, so that exceeding datagrams are not silently truncated
+	max := p.limits().MaxDatagramSize
+	buf := datagramBuffers.Get().(*[]byte)
+	defer datagramBuffers.Put(buf)
+	if cap(*buf) < max+1 {
+		*buf = make([]byte, max+1)
+	}
+	b := (*buf)[:max+1]
+
+	n, err := conn.Read(b)
+	if err != nil {
+		return nil, err
+	}
+	if err = checkLimit("MaxDatagramSize", int64(n), max); err != nil {
+		return nil, err
+	}
+
+	// Pooled buffer is reused by other reads, so datagram is copied out of it
+	return append([]byte(nil), b[:n]...), nil
+}
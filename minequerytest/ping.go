@@ -0,0 +1,238 @@
+package minequerytest
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/dreamscached/minequery/v2"
+	"github.com/dreamscached/minequery/v2/protocol"
+)
+
+const (
+	ping17WrongPacketID   int32 = 0x7f
+	ping17MaxPacketLength       = 1 << 16
+)
+
+const (
+	legacyWrongPacketID   byte = 0xfe
+	legacyOversizedLength      = 0xffff
+
+	// beta18TrailingDataWait is the time Beta 1.8 server waits for data following FE ping packet.
+	beta18TrailingDataWait = 50 * time.Millisecond
+)
+
+// oversizedVarInt is a VarInt encoding of 2147483647, the largest length 1.7+ packet can declare.
+var oversizedVarInt = []byte{0xff, 0xff, 0xff, 0xff, 0x07}
+
+// NewPing17Server starts a fake 1.7+ server responding to status requests with status
+// encoded by minequery.MarshalStatus17 and answering ping packets with pongs.
+func NewPing17Server(status *minequery.Status17, options *Options) (*Server, error) {
+	payload, err := minequery.MarshalStatus17(status)
+	if err != nil {
+		return nil, err
+	}
+	return NewPing17RawServer(payload, options)
+}
+
+// NewPing17RawServer starts a fake 1.7+ server responding to status requests with payload as JSON
+// string as is and answering ping packets with pongs.
+func NewPing17RawServer(payload []byte, options *Options) (*Server, error) {
+	return serveTCP(options, func(s *Server, conn net.Conn) error {
+		reader := protocol.NewPacketReader(conn)
+		reader.MaxPacketSize = ping17MaxPacketLength
+
+		// Read handshake packet (its contents don't matter)
+		if _, err := reader.ReadPacket(); err != nil {
+			return err
+		}
+
+		// Read status request packet
+		if packet, err := reader.ReadPacket(); err != nil {
+			return err
+		} else if packet.ID != protocol.StatusRequestPacketID {
+			return fmt.Errorf("expected status request packet, got %#x", packet.ID)
+		}
+		s.countRequest()
+		if s.options.has(FaultNoResponse) {
+			return discard(conn)
+		}
+
+		// Write status response packet
+		data := payload
+		if s.options.has(FaultMalformedJSON) {
+			// Cut JSON in half, so that it isn't terminated
+			data = data[:len(data)/2]
+		}
+		if err := s.write(conn, encodePing17Packet(s.options, protocol.EncodeStatusResponse(data))); err != nil {
+			return err
+		}
+
+		// Read ping packet and echo its payload back in pong packet
+		packet, err := reader.ReadPacket()
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		ping, err := protocol.DecodePing(packet)
+		if err != nil {
+			return err
+		}
+		if s.options.has(FaultNoPong) {
+			return nil
+		}
+		return s.write(conn, encodePing17Packet(s.options, protocol.EncodePong(ping)))
+	})
+}
+
+// NewPing16Server starts a fake 1.6 server responding to MC|PingHost pings with status.
+func NewPing16Server(status *minequery.Status16, options *Options) (*Server, error) {
+	return serveTCP(options, func(s *Server, conn net.Conn) error {
+		reader := bufio.NewReader(conn)
+
+		// Read FE 01 header and MC|PingHost plugin message (its contents don't matter)
+		header := make([]byte, 2)
+		if _, err := io.ReadFull(reader, header); err != nil {
+			return err
+		} else if !bytes.Equal(header, protocol.Encode14Ping()) {
+			return fmt.Errorf("unexpected ping header %#v", header)
+		}
+		if _, err := protocol.ReadPingHost(reader); err != nil {
+			return err
+		}
+		s.countRequest()
+		if s.options.has(FaultNoResponse) {
+			return discard(conn)
+		}
+
+		return s.write(conn, encodeLegacyPacket(s.options, encodePing16Payload(s.options, status)))
+	})
+}
+
+// NewPing14Server starts a fake 1.4 server responding to FE 01 pings with status.
+func NewPing14Server(status *minequery.Status14, options *Options) (*Server, error) {
+	return serveTCP(options, func(s *Server, conn net.Conn) error {
+		// Read FE 01 ping packet
+		header := make([]byte, 2)
+		if _, err := io.ReadFull(conn, header); err != nil {
+			return err
+		} else if !bytes.Equal(header, protocol.Encode14Ping()) {
+			return fmt.Errorf("unexpected ping header %#v", header)
+		}
+		s.countRequest()
+		if s.options.has(FaultNoResponse) {
+			return discard(conn)
+		}
+
+		var payload string
+		if s.options.has(FaultPing16Response) {
+			payload = encodePing16Payload(s.options, &minequery.Status16{
+				ProtocolVersion: int(minequery.Ping16ProtocolVersion147),
+				ServerVersion:   "1.4.7",
+				MOTD:            status.MOTD,
+				OnlinePlayers:   status.OnlinePlayers,
+				MaxPlayers:      status.MaxPlayers,
+			})
+		} else {
+			payload = encodeLegacyPayload(status.MOTD, status.OnlinePlayers, status.MaxPlayers)
+		}
+		return s.write(conn, encodeLegacyPacket(s.options, payload))
+	})
+}
+
+// NewPingBeta18Server starts a fake Beta 1.8 server responding to bare FE pings with status.
+// Pings of newer protocols (FE followed by other data) are left without response, so that
+// Ping can tell the server from 1.4 one.
+func NewPingBeta18Server(status *minequery.StatusBeta18, options *Options) (*Server, error) {
+	return serveTCP(options, func(s *Server, conn net.Conn) error {
+		// Read FE ping packet
+		header := make([]byte, 1)
+		if _, err := io.ReadFull(conn, header); err != nil {
+			return err
+		} else if !bytes.Equal(header, protocol.EncodeBeta18Ping()) {
+			return fmt.Errorf("unexpected ping header %#v", header)
+		}
+
+		// Close connection if anything follows FE within a short wait
+		_ = conn.SetReadDeadline(time.Now().Add(beta18TrailingDataWait))
+		if n, _ := conn.Read(make([]byte, 1)); n > 0 {
+			return fmt.Errorf("unexpected data following ping header")
+		}
+		_ = conn.SetReadDeadline(time.Now().Add(connTimeout))
+		s.countRequest()
+		if s.options.has(FaultNoResponse) {
+			return discard(conn)
+		}
+
+		payload := encodeLegacyPayload(status.MOTD, status.OnlinePlayers, status.MaxPlayers)
+		return s.write(conn, encodeLegacyPacket(s.options, payload))
+	})
+}
+
+// 1.7+ packets
+
+// encodePing17Packet frames packet, applying FaultWrongPacketID and FaultOversizedLength faults.
+func encodePing17Packet(options *Options, packet protocol.Packet) []byte {
+	if options.has(FaultWrongPacketID) {
+		packet.ID = ping17WrongPacketID
+	}
+	if options.has(FaultOversizedLength) {
+		data := protocol.AppendVarInt(append([]byte(nil), oversizedVarInt...), packet.ID)
+		return append(data, packet.Data...)
+	}
+
+	var buf bytes.Buffer
+	_ = protocol.NewPacketWriter(&buf).WritePacket(packet)
+	return buf.Bytes()
+}
+
+// Legacy packets
+
+func encodePing16Payload(options *Options, status *minequery.Status16) string {
+	payload := protocol.EncodePing16Response(&protocol.Ping16Response{
+		ProtocolVersion: status.ProtocolVersion,
+		ServerVersion:   status.ServerVersion,
+		MOTD:            status.MOTD,
+		OnlinePlayers:   status.OnlinePlayers,
+		MaxPlayers:      status.MaxPlayers,
+	})
+	if options.has(FaultMissingPrefix) {
+		return strings.TrimPrefix(payload, protocol.Ping16ResponsePrefix)
+	}
+	return payload
+}
+
+func encodeLegacyPayload(motd string, online, max int) string {
+	return protocol.EncodeLegacyResponse(&protocol.LegacyResponse{MOTD: motd, OnlinePlayers: online, MaxPlayers: max})
+}
+
+// encodeLegacyPacket encodes FF kick packet with UTF-16BE payload, applying FaultWrongPacketID
+// and FaultOversizedLength faults.
+func encodeLegacyPacket(options *Options, payload string) []byte {
+	packet := protocol.EncodeKick(payload)
+	if options.has(FaultWrongPacketID) {
+		packet[0] = legacyWrongPacketID
+	}
+	if options.has(FaultOversizedLength) {
+		binary.BigEndian.PutUint16(packet[1:3], legacyOversizedLength)
+	}
+	return packet
+}
+
+// discard reads and discards everything client sends until connection is closed.
+func discard(conn net.Conn) error {
+	_, err := io.Copy(ioutil.Discard, conn)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package minequerytest_test
+
+import (
+	"image"
+	"io"
+	"testing"
+
+	"github.com/dreamscached/minequery/v2"
+	"github.com/dreamscached/minequery/v2/minequerytest"
+	"github.com/dreamscached/minequery/v2/protocol"
+)
+
+func TestPing17Faults(t *testing.T) {
+	status := &minequery.Status17{VersionName: "1.20.1", ProtocolVersion: 763, MaxPlayers: 20}
+	runFaultCases(t, []faultCase{
+		{name: "None"},
+		{name: "SlowWrite", faults: minequerytest.FaultSlowWrite},
+		{name: "TruncatedPacket", faults: minequerytest.FaultTruncatedPacket, lax: io.ErrUnexpectedEOF, strict: io.ErrUnexpectedEOF},
+		{name: "WrongPacketID", faults: minequerytest.FaultWrongPacketID, lax: protocol.ErrUnexpectedPacket, strict: protocol.ErrUnexpectedPacket},
+		{name: "OversizedLength", faults: minequerytest.FaultOversizedLength, lax: minequery.ErrLimitExceeded, strict: minequery.ErrLimitExceeded},
+		{name: "MalformedJSON", faults: minequerytest.FaultMalformedJSON, lax: errAny, strict: errAny},
+		{name: "NoPong", faults: minequerytest.FaultNoPong, strict: io.EOF},
+		{name: "NoResponse", faults: minequerytest.FaultNoResponse, lax: errAny, strict: errAny},
+	}, func(options *minequerytest.Options) (*minequerytest.Server, error) {
+		return minequerytest.NewPing17Server(status, options)
+	}, func(p *minequery.Pinger, host string, port int) error {
+		_, err := p.Ping17(host, port)
+		return err
+	}, minequery.WithMeasureLatency(true))
+}
+
+func TestPing17FaviconLimits(t *testing.T) {
+	status := &minequery.Status17{VersionName: "1.20.1", ProtocolVersion: 763, Icon: image.NewRGBA(image.Rect(0, 0, 64, 64))}
+	runFaultCases(t, []faultCase{
+		{name: "OversizedFavicon", strict: minequery.ErrLimitExceeded},
+	}, func(options *minequerytest.Options) (*minequerytest.Server, error) {
+		return minequerytest.NewPing17Server(status, options)
+	}, func(p *minequery.Pinger, host string, port int) error {
+		res, err := p.Ping17(host, port)
+		if err == nil && res.Icon != nil {
+			t.Error("expected oversized favicon to be dropped")
+		}
+		return err
+	}, minequery.WithLimits(minequery.Limits{MaxFaviconDimension: 16}))
+}
+
+func TestPing17LatencyError(t *testing.T) {
+	status := &minequery.Status17{VersionName: "1.20.1", ProtocolVersion: 763, MaxPlayers: 20}
+	runFaultCases(t, []faultCase{
+		{name: "None"},
+		{name: "NoPong", faults: minequerytest.FaultNoPong, lax: io.EOF, strict: io.EOF},
+	}, func(options *minequerytest.Options) (*minequerytest.Server, error) {
+		return minequerytest.NewPing17Server(status, options)
+	}, func(p *minequery.Pinger, host string, port int) error {
+		res, err := p.Ping17(host, port)
+		if err == nil && (res.LatencyErr != nil) == (res.Latency != 0) {
+			t.Errorf("expected either latency or its error, got %s and %v", res.Latency, res.LatencyErr)
+		}
+		if err == nil && res.LatencyErr != nil {
+			// Lax ping records latency error instead of returning it
+			return res.LatencyErr
+		}
+		return err
+	}, minequery.WithMeasureLatency(true))
+}
+
+func TestPing16Faults(t *testing.T) {
+	status := &minequery.Status16{ProtocolVersion: 78, ServerVersion: "1.6.4", MOTD: "A Minecraft Server", MaxPlayers: 20}
+	runFaultCases(t, []faultCase{
+		{name: "None"},
+		{name: "SlowWrite", faults: minequerytest.FaultSlowWrite},
+		{name: "TruncatedPacket", faults: minequerytest.FaultTruncatedPacket, lax: io.ErrUnexpectedEOF, strict: io.ErrUnexpectedEOF},
+		{name: "WrongPacketID", faults: minequerytest.FaultWrongPacketID, lax: protocol.ErrUnexpectedPacket, strict: protocol.ErrUnexpectedPacket},
+		{name: "OversizedLength", faults: minequerytest.FaultOversizedLength, lax: io.ErrUnexpectedEOF, strict: io.ErrUnexpectedEOF},
+		{name: "MissingPrefix", faults: minequerytest.FaultMissingPrefix, strict: minequery.ErrInvalidStatus},
+		{name: "NoResponse", faults: minequerytest.FaultNoResponse, lax: errAny, strict: errAny},
+	}, func(options *minequerytest.Options) (*minequerytest.Server, error) {
+		return minequerytest.NewPing16Server(status, options)
+	}, func(p *minequery.Pinger, host string, port int) error {
+		_, err := p.Ping16(host, port)
+		return err
+	})
+}
+
+func TestPing14Faults(t *testing.T) {
+	status := &minequery.Status14{MOTD: "A Minecraft Server", MaxPlayers: 20}
+	runFaultCases(t, []faultCase{
+		{name: "None"},
+		{name: "SlowWrite", faults: minequerytest.FaultSlowWrite},
+		{name: "TruncatedPacket", faults: minequerytest.FaultTruncatedPacket, lax: io.ErrUnexpectedEOF, strict: io.ErrUnexpectedEOF},
+		{name: "WrongPacketID", faults: minequerytest.FaultWrongPacketID, lax: protocol.ErrUnexpectedPacket, strict: protocol.ErrUnexpectedPacket},
+		{name: "OversizedLength", faults: minequerytest.FaultOversizedLength, lax: io.ErrUnexpectedEOF, strict: io.ErrUnexpectedEOF},
+		{name: "Ping16Response", faults: minequerytest.FaultPing16Response, strict: minequery.ErrInvalidStatus},
+		{name: "NoResponse", faults: minequerytest.FaultNoResponse, lax: errAny, strict: errAny},
+	}, func(options *minequerytest.Options) (*minequerytest.Server, error) {
+		return minequerytest.NewPing14Server(status, options)
+	}, func(p *minequery.Pinger, host string, port int) error {
+		_, err := p.Ping14(host, port)
+		return err
+	})
+}
+
+func TestPingBeta18Faults(t *testing.T) {
+	status := &minequery.StatusBeta18{MOTD: "A Minecraft Server", MaxPlayers: 20}
+	runFaultCases(t, []faultCase{
+		{name: "None"},
+		{name: "SlowWrite", faults: minequerytest.FaultSlowWrite},
+		{name: "TruncatedPacket", faults: minequerytest.FaultTruncatedPacket, lax: io.ErrUnexpectedEOF, strict: io.ErrUnexpectedEOF},
+		{name: "WrongPacketID", faults: minequerytest.FaultWrongPacketID, lax: protocol.ErrUnexpectedPacket, strict: protocol.ErrUnexpectedPacket},
+		{name: "OversizedLength", faults: minequerytest.FaultOversizedLength, lax: io.ErrUnexpectedEOF, strict: io.ErrUnexpectedEOF},
+		{name: "NoResponse", faults: minequerytest.FaultNoResponse, lax: errAny, strict: errAny},
+	}, func(options *minequerytest.Options) (*minequerytest.Server, error) {
+		return minequerytest.NewPingBeta18Server(status, options)
+	}, func(p *minequery.Pinger, host string, port int) error {
+		_, err := p.PingBeta18(host, port)
+		return err
+	})
+}
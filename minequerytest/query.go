@@ -0,0 +1,91 @@
+package minequerytest
+
+import (
+	"bytes"
+	"net"
+
+	"github.com/dreamscached/minequery/v2"
+	"github.com/dreamscached/minequery/v2/protocol"
+)
+
+const (
+	queryWrongPacketType    byte = 0x7f
+	queryStringTerminator        = "\x00"
+	queryBadKeyValuePadding      = "\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00"
+	queryBadPlayersPadding       = "\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00"
+)
+
+// NewQueryServer starts a fake Query server answering handshakes and basic and full stat requests
+// with status by means of minequery.QueryResponder, so responses are encoded exactly as QueryResponder
+// encodes them: empty GameType, GameID, Host and Port fields of status are sent as SMP, MINECRAFT and
+// server loopback address, Data entries named as standard fields are not sent twice.
+func NewQueryServer(status *minequery.FullQueryStatus, options *Options) (*Server, error) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	addr := conn.LocalAddr().(*net.UDPAddr)
+
+	responder := minequery.NewQueryResponder(minequery.StaticQueryHandler(status))
+	s := &Server{Host: addr.IP.String(), Port: addr.Port, options: options, closer: responder}
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		_ = responder.Serve(&queryFaultConn{PacketConn: conn, server: s})
+	}()
+	return s, nil
+}
+
+// queryFaultConn is net.PacketConn that counts Query requests read from it and applies server faults
+// to responses written to it.
+type queryFaultConn struct {
+	net.PacketConn
+	server *Server
+}
+
+func (c *queryFaultConn) ReadFrom(b []byte) (int, net.Addr, error) {
+	n, addr, err := c.PacketConn.ReadFrom(b)
+	if err == nil {
+		if _, decodeErr := protocol.DecodeQueryRequest(b[:n]); decodeErr == nil {
+			c.server.countRequest()
+		}
+	}
+	return n, addr, err
+}
+
+func (c *queryFaultConn) WriteTo(b []byte, addr net.Addr) (int, error) {
+	options := c.server.options
+	if options.has(FaultNoResponse) {
+		return len(b), nil
+	}
+
+	response, err := protocol.DecodeQueryResponse(b)
+	if err != nil {
+		return c.PacketConn.WriteTo(b, addr)
+	}
+
+	// Apply faults to response fields
+	if options.has(FaultBadSessionID) {
+		response.SessionID = ^response.SessionID
+	}
+	if options.has(FaultWrongPacketID) {
+		response.Type = queryWrongPacketType
+	}
+	if options.has(FaultMissingNUL) {
+		response.Body = bytes.TrimRight(response.Body, queryStringTerminator)
+	}
+	if options.has(FaultBadPadding) {
+		response.Body = bytes.Replace(response.Body, []byte(protocol.QueryKeyValuePadding), []byte(queryBadKeyValuePadding), 1)
+		response.Body = bytes.Replace(response.Body, []byte(protocol.QueryPlayersPadding), []byte(queryBadPlayersPadding), 1)
+	}
+
+	// Encode response back, truncating it if needed
+	packet := protocol.EncodeQueryResponse(response)
+	if options.has(FaultTruncatedPacket) {
+		packet = packet[:len(packet)/2]
+	}
+	if _, err := c.PacketConn.WriteTo(packet, addr); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
@@ -0,0 +1,69 @@
+package minequerytest_test
+
+import (
+	"testing"
+
+	"github.com/dreamscached/minequery/v2"
+	"github.com/dreamscached/minequery/v2/minequerytest"
+	"github.com/dreamscached/minequery/v2/protocol"
+)
+
+var queryFaultCases = []faultCase{
+	{name: "None"},
+	{name: "TruncatedPacket", faults: minequerytest.FaultTruncatedPacket, lax: errAny, strict: minequery.ErrInvalidStatus},
+	{name: "WrongPacketID", faults: minequerytest.FaultWrongPacketID, lax: errAny, strict: errAny},
+	{name: "BadSessionID", faults: minequerytest.FaultBadSessionID, lax: errAny, strict: errAny},
+	{name: "MissingNUL", faults: minequerytest.FaultMissingNUL, strict: protocol.ErrMalformedPacket},
+	{name: "NoResponse", faults: minequerytest.FaultNoResponse, lax: errAny, strict: errAny},
+}
+
+func startQueryServer(options *minequerytest.Options) (*minequerytest.Server, error) {
+	return minequerytest.NewQueryServer(&minequery.FullQueryStatus{
+		MOTD:          "A Minecraft Server",
+		Version:       "1.20.1",
+		MaxPlayers:    20,
+		OnlinePlayers: 1,
+		SamplePlayers: []string{"Notch"},
+		Data:          map[string]string{"hostname": "Duplicate", "custom": "value"},
+	}, options)
+}
+
+func TestQueryBasicFaults(t *testing.T) {
+	cases := append([]faultCase{
+		{name: "BadPadding", faults: minequerytest.FaultBadPadding},
+	}, queryFaultCases...)
+	runFaultCases(t, cases, startQueryServer, func(p *minequery.Pinger, host string, port int) error {
+		_, err := p.QueryBasic(host, port)
+		return err
+	})
+}
+
+func TestQueryFullFaults(t *testing.T) {
+	cases := append([]faultCase{
+		{name: "BadPadding", faults: minequerytest.FaultBadPadding, strict: minequery.ErrInvalidStatus},
+	}, queryFaultCases...)
+	runFaultCases(t, cases, startQueryServer, func(p *minequery.Pinger, host string, port int) error {
+		_, err := p.QueryFull(host, port)
+		return err
+	})
+}
+
+func TestQueryFullStandardKeys(t *testing.T) {
+	server, err := startQueryServer(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = server.Close() }()
+
+	status, err := minequery.NewPinger(minequery.WithTimeout(testTimeout), minequery.WithUseStrict(true)).
+		QueryFull(server.Host, server.Port)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.MOTD != "A Minecraft Server" {
+		t.Errorf("expected MOTD to be sent once, got %q", status.MOTD)
+	}
+	if status.Data["custom"] != "value" {
+		t.Errorf("expected custom key to be sent, got %q", status.Data["custom"])
+	}
+}
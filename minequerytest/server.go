@@ -0,0 +1,234 @@
+// Package minequerytest provides fake loopback Minecraft servers for testing code built on minequery.
+//
+// Servers answer requests of a single protocol with responses built from minequery status types,
+// and can be scripted to misbehave (truncate packets, send wrong packet IDs, malformed payloads and so on)
+// with Fault flags, so that error handling and UseStrict validation can be tested without live servers.
+package minequerytest
+
+import (
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Fault is a set of misbehaviours fake server is scripted with. Faults can be combined with bitwise OR;
+// faults not applicable to server protocol are ignored.
+type Fault uint
+
+const (
+	// FaultTruncatedPacket makes server send only the first half of response packet and close connection.
+	FaultTruncatedPacket Fault = 1 << iota
+
+	// FaultWrongPacketID makes server send response packet with unexpected packet ID.
+	FaultWrongPacketID
+
+	// FaultOversizedLength makes server send response packet with length prefix (VarInt for 1.7+,
+	// unsigned short for older protocols) much larger than the actual data.
+	FaultOversizedLength
+
+	// FaultMalformedJSON makes 1.7+ server send status response with malformed JSON payload.
+	FaultMalformedJSON
+
+	// FaultSlowWrite makes server write responses byte-by-byte, waiting Options.SlowWriteDelay
+	// between bytes, so that client receives them in many small TCP segments.
+	FaultSlowWrite
+
+	// FaultBadSessionID makes Query server respond with session ID other than the one in request.
+	FaultBadSessionID
+
+	// FaultMissingNUL makes Query server omit NUL terminator of challenge token and stat response bodies.
+	FaultMissingNUL
+
+	// FaultBadPadding makes Query server send full stat response with invalid section paddings.
+	FaultBadPadding
+
+	// FaultMissingPrefix makes 1.6 server send response without §1 prefix.
+	FaultMissingPrefix
+
+	// FaultPing16Response makes 1.4 server respond with 1.6 response format, as Spigot servers do.
+	FaultPing16Response
+
+	// FaultNoPong makes 1.7+ server close connection instead of answering ping packet.
+	FaultNoPong
+
+	// FaultNoResponse makes server read requests and never respond to them.
+	FaultNoResponse
+)
+
+const (
+	defaultSlowWriteDelay = time.Millisecond
+
+	// connTimeout limits the time a single connection is served for.
+	connTimeout = 10 * time.Second
+)
+
+// Options holds configuration of fake server.
+type Options struct {
+	// Faults is the set of misbehaviours server is scripted with.
+	Faults Fault
+
+	// SlowWriteDelay is the delay between bytes written with FaultSlowWrite.
+	// By default, it is 1 millisecond.
+	SlowWriteDelay time.Duration
+}
+
+func (o *Options) has(fault Fault) bool {
+	return o != nil && o.Faults&fault != 0
+}
+
+func (o *Options) slowWriteDelay() time.Duration {
+	if o == nil || o.SlowWriteDelay <= 0 {
+		return defaultSlowWriteDelay
+	}
+	return o.SlowWriteDelay
+}
+
+// Server is a fake server listening on loopback interface.
+type Server struct {
+	// Host is the loopback IP address server listens on.
+	Host string
+
+	// Port is the port server listens on.
+	Port int
+
+	options  *Options
+	closer   io.Closer
+	wg       sync.WaitGroup
+	mu       sync.Mutex
+	conns    map[net.Conn]struct{}
+	requests int
+	closed   bool
+}
+
+// Addr returns network address of server in host:port form.
+func (s *Server) Addr() string {
+	return net.JoinHostPort(s.Host, strconv.Itoa(s.Port))
+}
+
+// Requests returns the number of requests server has received so far.
+func (s *Server) Requests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+}
+
+// Close stops server and waits for requests being served to finish.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	for conn := range s.conns {
+		_ = conn.Close()
+	}
+	s.mu.Unlock()
+
+	err := s.closer.Close()
+	s.wg.Wait()
+	return err
+}
+
+func (s *Server) countRequest() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests++
+}
+
+// trackConn registers conn to be closed on Close; it returns false if server is already closed.
+func (s *Server) trackConn(conn net.Conn) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return false
+	}
+	if s.conns == nil {
+		s.conns = make(map[net.Conn]struct{})
+	}
+	s.conns[conn] = struct{}{}
+	return true
+}
+
+func (s *Server) untrackConn(conn net.Conn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.conns, conn)
+}
+
+func (s *Server) isClosed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closed
+}
+
+// serveTCP starts a TCP server on loopback interface that serves each connection with serve.
+func serveTCP(options *Options, serve func(s *Server, conn net.Conn) error) (*Server, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	addr := listener.Addr().(*net.TCPAddr)
+
+	s := &Server{Host: addr.IP.String(), Port: addr.Port, options: options, closer: listener}
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				if s.isClosed() {
+					return
+				}
+				var netErr net.Error
+				if errors.As(err, &netErr) && netErr.Timeout() {
+					continue
+				}
+				return
+			}
+
+			if !s.trackConn(conn) {
+				_ = conn.Close()
+				return
+			}
+
+			s.wg.Add(1)
+			go func() {
+				defer s.wg.Done()
+				defer s.untrackConn(conn)
+				defer func() { _ = conn.Close() }()
+				_ = conn.SetDeadline(time.Now().Add(connTimeout))
+				_ = serve(s, conn)
+			}()
+		}
+	}()
+	return s, nil
+}
+
+// write writes response data to conn, applying FaultTruncatedPacket and FaultSlowWrite faults.
+func (s *Server) write(conn net.Conn, data []byte) error {
+	if s.options.has(FaultTruncatedPacket) {
+		data = data[:len(data)/2]
+	}
+
+	if s.options.has(FaultSlowWrite) {
+		delay := s.options.slowWriteDelay()
+		for i := range data {
+			if _, err := conn.Write(data[i : i+1]); err != nil {
+				return err
+			}
+			time.Sleep(delay)
+		}
+	} else if _, err := conn.Write(data); err != nil {
+		return err
+	}
+
+	if s.options.has(FaultTruncatedPacket) {
+		return io.ErrShortWrite
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package minequerytest_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dreamscached/minequery/v2"
+	"github.com/dreamscached/minequery/v2/minequerytest"
+)
+
+const testTimeout = 300 * time.Millisecond
+
+// errAny is expected error of fault cases where request must fail, but the error isn't of any specific kind.
+var errAny = errors.New("any error")
+
+// faultCase describes the expected outcome of request to fake server scripted with faults,
+// in non-strict and strict mode. Nil error means request must succeed.
+type faultCase struct {
+	name   string
+	faults minequerytest.Fault
+	lax    error
+	strict error
+}
+
+// runFaultCases starts a server with start for each case and both UseStrict modes, sends request to it
+// with request and checks its outcome.
+func runFaultCases(
+	t *testing.T,
+	cases []faultCase,
+	start func(options *minequerytest.Options) (*minequerytest.Server, error),
+	request func(p *minequery.Pinger, host string, port int) error,
+	options ...minequery.PingerOption,
+) {
+	t.Helper()
+	for _, c := range cases {
+		for _, useStrict := range []bool{false, true} {
+			c, useStrict := c, useStrict
+			name := c.name + "/lax"
+			expected := c.lax
+			if useStrict {
+				name = c.name + "/strict"
+				expected = c.strict
+			}
+
+			t.Run(name, func(t *testing.T) {
+				server, err := start(&minequerytest.Options{Faults: c.faults})
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer func() { _ = server.Close() }()
+
+				pingerOptions := append([]minequery.PingerOption{
+					minequery.WithTimeout(testTimeout),
+					minequery.WithUseStrict(useStrict),
+				}, options...)
+				err = request(minequery.NewPinger(pingerOptions...), server.Host, server.Port)
+				checkFaultError(t, err, expected)
+			})
+		}
+	}
+}
+
+func checkFaultError(t *testing.T, err, expected error) {
+	t.Helper()
+	switch {
+	case expected == nil && err != nil:
+		t.Errorf("expected no error, got %v", err)
+	case expected != nil && err == nil:
+		t.Errorf("expected error, got nil")
+	case expected != nil && expected != errAny && !errors.Is(err, expected):
+		t.Errorf("expected error %v, got %v", expected, err)
+	}
+}
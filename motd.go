@@ -0,0 +1,190 @@
+package minequery
+
+import "strings"
+
+const (
+	legacyFormattingPrefix = '§'
+	legacyHexColorCode     = 'x'
+	legacyResetCode        = 'r'
+)
+
+// legacyColorCodes maps legacy §-formatting color codes to named chat colors.
+var legacyColorCodes = map[rune]ChatColor{
+	'0': ChatColorBlack,
+	'1': ChatColorDarkBlue,
+	'2': ChatColorDarkGreen,
+	'3': ChatColorDarkAqua,
+	'4': ChatColorDarkRed,
+	'5': ChatColorDarkPurple,
+	'6': ChatColorGold,
+	'7': ChatColorGray,
+	'8': ChatColorDarkGray,
+	'9': ChatColorBlue,
+	'a': ChatColorGreen,
+	'b': ChatColorAqua,
+	'c': ChatColorRed,
+	'd': ChatColorLightPurple,
+	'e': ChatColorYellow,
+	'f': ChatColorWhite,
+}
+
+// ChatSegment holds a run of text sharing the same resolved style. Segments are produced
+// both from legacy §-formatted MOTD strings (see ParseLegacyMOTD) and from 1.7+ chat
+// components (see ChatComponent Segments), so that all server generations can be rendered the same way.
+type ChatSegment struct {
+	Text  string
+	Style ChatStyle
+}
+
+// ParseLegacyMOTD parses MOTD string with legacy §-formatting codes into styled text segments.
+//
+// Color codes (§0 to §f) reset formatting set before them, formatting codes (§k to §o) add to
+// current formatting and §r resets both. Hex colors in §x§R§R§G§G§B§B notation (used by Spigot
+// and BungeeCord) are supported too. Unknown codes are omitted, the way Notchian client does it.
+// Newlines are preserved as is.
+func ParseLegacyMOTD(s string) []ChatSegment {
+	return parseLegacyText(s, ChatStyle{}, nil)
+}
+
+// Segments flattens component tree into styled text segments in display order, resolving
+// style inheritance. Legacy §-formatting inside component text is applied on top of component style.
+func (c *ChatComponent) Segments() []ChatSegment {
+	segments := make([]ChatSegment, 0, 8)
+	c.walk(func(component *ChatComponent, style ChatStyle) {
+		segments = parseLegacyText(component.content(), style, segments)
+	})
+	return segments
+}
+
+// parseLegacyText parses s with legacy §-formatting, using base as initial style and
+// style §r resets to, appending resulting segments to segments.
+func parseLegacyText(s string, base ChatStyle, segments []ChatSegment) []ChatSegment {
+	runes := []rune(s)
+	style := base
+	var text strings.Builder
+
+	flush := func() {
+		if text.Len() == 0 {
+			return
+		}
+		segments = appendChatSegment(segments, ChatSegment{text.String(), style})
+		text.Reset()
+	}
+
+	for i := 0; i < len(runes); i++ {
+		if runes[i] != legacyFormattingPrefix {
+			text.WriteRune(runes[i])
+			continue
+		}
+
+		// Dangling prefix in the end of string is omitted
+		if i+1 >= len(runes) {
+			break
+		}
+		code := toLowerASCII(runes[i+1])
+		i++
+
+		flush()
+		switch {
+		case code == legacyResetCode:
+			style = base
+
+		case code == legacyHexColorCode:
+			// Try reading six §-prefixed hex digits that follow; treat as unknown code if they don't
+			if color, ok := parseLegacyHexColor(runes[i+1:]); ok {
+				style = withLegacyColor(style, color)
+				i += 12
+			}
+
+		default:
+			if color, ok := legacyColorCodes[code]; ok {
+				style = withLegacyColor(style, color)
+			} else {
+				style = withLegacyFormatting(style, code)
+			}
+		}
+	}
+	flush()
+
+	return segments
+}
+
+// parseLegacyHexColor parses hex color digits of §x§R§R§G§G§B§B notation (runes following §x).
+func parseLegacyHexColor(runes []rune) (ChatColor, bool) {
+	if len(runes) < 12 {
+		return "", false
+	}
+
+	digits := make([]rune, 0, 6)
+	for i := 0; i < 12; i += 2 {
+		digit := toLowerASCII(runes[i+1])
+		if runes[i] != legacyFormattingPrefix || !((digit >= '0' && digit <= '9') || (digit >= 'a' && digit <= 'f')) {
+			return "", false
+		}
+		digits = append(digits, digit)
+	}
+	return ChatColor("#" + string(digits)), true
+}
+
+// withLegacyColor returns style with color applied; color codes disable all formatting flags.
+func withLegacyColor(style ChatStyle, color ChatColor) ChatStyle {
+	disabled := false
+	style.Color = color
+	style.Bold = &disabled
+	style.Italic = &disabled
+	style.Underlined = &disabled
+	style.Strikethrough = &disabled
+	style.Obfuscated = &disabled
+	return style
+}
+
+// withLegacyFormatting returns style with formatting flag of code enabled; unknown codes are ignored.
+func withLegacyFormatting(style ChatStyle, code rune) ChatStyle {
+	enabled := true
+	switch code {
+	case 'k':
+		style.Obfuscated = &enabled
+	case 'l':
+		style.Bold = &enabled
+	case 'm':
+		style.Strikethrough = &enabled
+	case 'n':
+		style.Underlined = &enabled
+	case 'o':
+		style.Italic = &enabled
+	}
+	return style
+}
+
+// appendChatSegment appends segment to segments, merging it with the last one if their styles are equal.
+func appendChatSegment(segments []ChatSegment, segment ChatSegment) []ChatSegment {
+	if segment.Text == "" {
+		return segments
+	}
+	if n := len(segments); n > 0 && equalChatStyles(segments[n-1].Style, segment.Style) {
+		segments[n-1].Text += segment.Text
+		return segments
+	}
+	return append(segments, segment)
+}
+
+// equalChatStyles checks if styles render the same way; unset and disabled flags are considered equal.
+func equalChatStyles(a, b ChatStyle) bool {
+	return a.Color == b.Color &&
+		a.IsBold() == b.IsBold() &&
+		a.IsItalic() == b.IsItalic() &&
+		a.IsUnderlined() == b.IsUnderlined() &&
+		a.IsStrikethrough() == b.IsStrikethrough() &&
+		a.IsObfuscated() == b.IsObfuscated() &&
+		a.Font == b.Font &&
+		a.Insertion == b.Insertion &&
+		a.ClickEvent == b.ClickEvent &&
+		a.HoverEvent == b.HoverEvent
+}
+
+func toLowerASCII(r rune) rune {
+	if r >= 'A' && r <= 'Z' {
+		return r + ('a' - 'A')
+	}
+	return r
+}
@@ -1,86 +1,315 @@
 package minequery
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"math/rand"
 	"net"
+	"reflect"
+	"sort"
 	"strings"
 	"time"
 )
 
-func (p *Pinger) openTCPConn(host string, port int) (net.Conn, error) {
-	conn, err := p.Dialer.Dial("tcp", toAddrString(host, port))
-	if err != nil {
-		return nil, err
-	}
-	if p.Timeout != 0 {
-		if err = conn.SetDeadline(time.Now().Add(p.Timeout)); err != nil {
-			return nil, err
-		}
-	}
-	return conn, nil
+// contextConn is a net.Conn wrapper that stops context watching goroutine (see watchContext)
+// when connection is closed.
+type contextConn struct {
+	net.Conn
+	stop func()
+}
+
+// Close stops watching connection context and closes underlying connection.
+func (c *contextConn) Close() error {
+	c.stop()
+	return c.Conn.Close()
 }
 
-func (p *Pinger) openUDPConn(host string, port int) (*net.UDPConn, error) {
-	addr, err := net.ResolveUDPAddr("udp", toAddrString(host, port))
+// openTCPConn connects to host and port, returning connection and the time it took to establish it
+// (excluding DNS lookups and PROXY protocol header.)
+func (p *Pinger) openTCPConn(ctx context.Context, host string, port int) (net.Conn, time.Duration, error) {
+	var dialer ContextDialer = p.Dialer
+	if p.ContextDialer != nil {
+		dialer = p.ContextDialer
+	}
+	conn, target, connectTime, err := p.dial(ctx, dialer, "tcp", host, port)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
-	conn, err := net.DialUDP("udp", nil, addr)
+	conn, err = p.prepareConn(ctx, conn)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
-	if p.Timeout != 0 {
-		if err = conn.SetDeadline(time.Now().Add(p.Timeout)); err != nil {
-			return nil, err
-		}
+
+	// PROXY protocol header must precede any packets
+	if err = p.writeProxyHeader(conn, proxyHeaderDestination(dialer, conn, target)); err != nil {
+		_ = conn.Close()
+		return nil, 0, err
 	}
-	return conn, nil
+	return conn, connectTime, nil
 }
 
-func (p *Pinger) openUDPConnWithLocalAddr(host string, remotePort int, localAddr string) (*net.UDPConn, error) {
+func (p *Pinger) openUDPConn(ctx context.Context, host string, port int) (net.Conn, error) {
+	if p.UDPDialer != nil {
+		return p.dialUDP(ctx, p.UDPDialer, host, port)
+	}
+	return p.dialUDP(ctx, &net.Dialer{}, host, port)
+}
+
+func (p *Pinger) openUDPConnWithLocalAddr(ctx context.Context, host string, remotePort int, localAddr string) (net.Conn, error) {
 	lAddrObj, err := net.ResolveUDPAddr("udp", localAddr)
 	if err != nil {
 		return nil, err
 	}
-	addr, err := net.ResolveUDPAddr("udp", toAddrString(host, remotePort))
+	return p.dialUDP(ctx, &net.Dialer{LocalAddr: lAddrObj}, host, remotePort)
+}
+
+func (p *Pinger) dialUDP(ctx context.Context, dialer ContextDialer, host string, port int) (net.Conn, error) {
+	conn, _, _, err := p.dial(ctx, dialer, "udp", host, port)
 	if err != nil {
 		return nil, err
 	}
-	conn, err := net.DialUDP("udp", lAddrObj, addr)
+	return p.prepareConn(ctx, conn)
+}
+
+// dial connects to host and port with dialer. If Pinger Resolver is set, host is resolved with it
+// and its addresses are dialed one by one until connection succeeds; otherwise host is resolved by dialer.
+// It returns connection along with the address (host:port) it was dialed to and the duration
+// of dialer call that established it.
+func (p *Pinger) dial(ctx context.Context, dialer ContextDialer, network string, host string, port int) (net.Conn, string, time.Duration, error) {
+	if _, ok := dialer.(*net.Dialer); !ok && p.Timeout > 0 {
+		// Custom dialers don't have Dialer timeout applied, so connection establishment is limited with context
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.Timeout)
+		defer cancel()
+	}
+
+	if p.Resolver == nil || net.ParseIP(host) != nil {
+		target := toAddrString(host, port)
+		dialStart := time.Now()
+		conn, err := dialer.DialContext(ctx, network, target)
+		if err != nil {
+			return nil, "", 0, err
+		}
+		return conn, target, time.Since(dialStart), nil
+	}
+
+	addrs, err := p.lookupIPAddr(ctx, host)
 	if err != nil {
-		return nil, err
+		return nil, "", 0, err
+	}
+	if len(addrs) == 0 {
+		return nil, "", 0, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+
+	var firstErr error
+	for _, addr := range addrs {
+		target := toAddrString(addr.String(), port)
+		dialStart := time.Now()
+		conn, err := dialer.DialContext(ctx, network, target)
+		if err == nil {
+			return conn, target, time.Since(dialStart), nil
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, "", 0, ctxErr
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
 	}
+	return nil, "", 0, firstErr
+}
+
+// prepareConn sets connection deadline to the earliest of Pinger Timeout and context deadline,
+// and wraps connection so that pending reads and writes are interrupted once context is done.
+func (p *Pinger) prepareConn(ctx context.Context, conn net.Conn) (net.Conn, error) {
+	var deadline time.Time
 	if p.Timeout != 0 {
-		if err = conn.SetDeadline(time.Now().Add(p.Timeout)); err != nil {
+		deadline = time.Now().Add(p.Timeout)
+	}
+	if ctxDeadline, ok := ctx.Deadline(); ok && (deadline.IsZero() || ctxDeadline.Before(deadline)) {
+		deadline = ctxDeadline
+	}
+	if !deadline.IsZero() {
+		if err := conn.SetDeadline(deadline); err != nil {
+			_ = conn.Close()
 			return nil, err
 		}
 	}
-	return conn, nil
+	return &contextConn{conn, watchContext(ctx, conn)}, nil
 }
 
-// resolveSRV performs SRV lookup of a Minecraft server hostname.
-//
-// In case there are no records found, an empty string, a zero port and nil error are returned.
+// watchContext interrupts any pending I/O on conn as soon as ctx is done. The returned function
+// must be called once conn is no longer used in order to release the watching goroutine.
+func watchContext(ctx context.Context, conn net.Conn) func() {
+	if ctx.Done() == nil {
+		// Context can never be cancelled, no need to watch it
+		return func() {}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			// Setting deadline in the past immediately unblocks pending reads and writes
+			_ = conn.SetDeadline(time.Unix(1, 0))
+		case <-done:
+		}
+	}()
+	return func() { close(done) }
+}
+
+// resolveSRV performs SRV lookup of a Minecraft server hostname and returns its records in the order
+// they should be tried, as defined by RFC 2782: records are sorted by priority, and records of the same
+// priority are ordered by weighted random selection. Records with "." target (meaning that service
+// is decidedly not available at the target) are skipped, and trailing dots of targets are trimmed.
 //
-// In case when there is more than one record, the hostname and port of the first record with
-// the least weight is returned.
-func (p *Pinger) resolveSRV(host string) (string, uint16, error) {
-	_, records, err := net.LookupSRV("minecraft", "tcp", host)
+// In case there are no records found, an empty slice and nil error are returned.
+func (p *Pinger) resolveSRV(ctx context.Context, host string) ([]SRVRecord, error) {
+	records, err := p.lookupSRV(ctx, host)
 	if err != nil {
 		var dnsError *net.DNSError
 		if errors.As(err, &dnsError) && dnsError.IsNotFound {
-			return "", 0, nil
+			return []SRVRecord{}, nil
 		}
 
-		return "", 0, err
+		return nil, err
 	}
 
-	if len(records) == 0 {
-		return "", 0, nil
+	// Records are shared with concurrent lookups of the same host, so they are copied before reordering
+	result := make([]SRVRecord, 0, len(records))
+	for _, record := range records {
+		target := strings.TrimSuffix(record.Target, ".")
+		if target == "" {
+			continue
+		}
+		result = append(result, SRVRecord{
+			Target:   target,
+			Port:     int(record.Port),
+			Priority: record.Priority,
+			Weight:   record.Weight,
+		})
+	}
+	orderSRVRecords(result)
+	return result, nil
+}
+
+// orderSRVRecords sorts records by priority and shuffles records of the same priority
+// with weighted random selection as described in RFC 2782.
+func orderSRVRecords(records []SRVRecord) {
+	sort.SliceStable(records, func(i, j int) bool { return records[i].Priority < records[j].Priority })
+
+	for start := 0; start < len(records); {
+		// Find the end of group of records with the same priority
+		end := start + 1
+		for end < len(records) && records[end].Priority == records[start].Priority {
+			end++
+		}
+		shuffleSRVRecordsByWeight(records[start:end])
+		start = end
+	}
+}
+
+// shuffleSRVRecordsByWeight orders records of the same priority by repeatedly picking a record
+// with probability proportional to its weight from the remaining ones. Zero-weight records are
+// placed first before selection, so that they have a small chance of being picked.
+func shuffleSRVRecordsByWeight(records []SRVRecord) {
+	sort.SliceStable(records, func(i, j int) bool { return records[i].Weight == 0 && records[j].Weight != 0 })
+
+	sum := 0
+	for _, record := range records {
+		sum += int(record.Weight)
+	}
+
+	for i := range records {
+		// Pick a random number in [0, sum] and select the first record whose running sum
+		// of weights is greater or equal to it
+		n := rand.Intn(sum + 1)
+		selected, running := i, 0
+		for j := i; j < len(records); j++ {
+			running += int(records[j].Weight)
+			if running >= n {
+				selected = j
+				break
+			}
+		}
+
+		sum -= int(records[selected].Weight)
+
+		// Move selected record to position i, keeping order of the remaining ones
+		record := records[selected]
+		copy(records[i+1:selected+1], records[i:selected])
+		records[i] = record
+	}
+}
+
+// srvLookups and ipLookups deduplicate concurrent lookups of the same hostname with the same
+// resolver and timeout (for example, when many targets behind the same domain are pinged with PingMany.)
+var srvLookups, ipLookups flightGroup
+
+// defaultSharedLookupTimeout limits shared lookups of Pinger without Timeout, so that lookup
+// detached from contexts of its callers can't hang forever.
+const defaultSharedLookupTimeout = 10 * time.Second
+
+// lookupSRV looks up Minecraft SRV records of host, sharing the lookup with concurrent calls
+// for the same host. Shared lookup is limited by Pinger timeout rather than ctx, which only
+// limits the time this call waits for it.
+func (p *Pinger) lookupSRV(ctx context.Context, host string) ([]*net.SRV, error) {
+	resolver := p.resolver()
+	records, err := p.lookupShared(ctx, &srvLookups, resolver, host, func(ctx context.Context) (interface{}, error) {
+		_, records, err := resolver.LookupSRV(ctx, "minecraft", "tcp", host)
+		return records, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return records.([]*net.SRV), nil
+}
+
+// lookupIPAddr looks up addresses of host with Pinger Resolver, sharing the lookup with concurrent
+// calls for the same host the same way lookupSRV does.
+func (p *Pinger) lookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	resolver := p.resolver()
+	addrs, err := p.lookupShared(ctx, &ipLookups, resolver, host, func(ctx context.Context) (interface{}, error) {
+		return resolver.LookupIPAddr(ctx, host)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return addrs.([]net.IPAddr), nil
+}
+
+// lookupShared calls lookup in group, keyed by resolver, Pinger timeout and host, so that lookups
+// are only shared by Pingers that would limit them the same way. Shared lookup is always limited by
+// Pinger timeout (or defaultSharedLookupTimeout if there is none.) Lookups of resolvers that are
+// not pointers are not shared, since such resolvers can't be told apart reliably.
+func (p *Pinger) lookupShared(
+	ctx context.Context, group *flightGroup, resolver Resolver, host string,
+	lookup func(ctx context.Context) (interface{}, error),
+) (interface{}, error) {
+	timeout := p.Timeout
+	if timeout <= 0 {
+		timeout = defaultSharedLookupTimeout
+	}
+	detached := func() (interface{}, error) {
+		lookupCtx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		return lookup(lookupCtx)
+	}
+
+	value := reflect.ValueOf(resolver)
+	if value.Kind() != reflect.Ptr {
+		return lookup(ctx)
+	}
+	return group.Do(ctx, fmt.Sprintf("%x/%d/%s", value.Pointer(), timeout, host), detached)
+}
+
+// resolver returns Pinger Resolver, or net.DefaultResolver if it is not set.
+func (p *Pinger) resolver() Resolver {
+	if p.Resolver == nil {
+		return net.DefaultResolver
 	}
-	target := records[0]
-	return target.Target, target.Port, nil
+	return p.Resolver
 }
 
 func shouldWrapIPv6(host string) bool {
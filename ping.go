@@ -0,0 +1,309 @@
+package minequery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"syscall"
+
+	"github.com/dreamscached/minequery/v2/protocol"
+)
+
+// PingProtocol identifies a generation of Server List Ping protocol.
+type PingProtocol int
+
+//goland:noinspection GoUnusedConst
+const (
+	// PingProtocolUnknown is a zero value of PingProtocol meaning protocol is not known.
+	PingProtocolUnknown PingProtocol = iota
+
+	// PingProtocol17 identifies 1.7+ (Netty) ping protocol, see Ping17.
+	PingProtocol17
+
+	// PingProtocol16 identifies 1.6 ping protocol, see Ping16.
+	PingProtocol16
+
+	// PingProtocol14 identifies 1.4 to 1.5 ping protocol, see Ping14.
+	PingProtocol14
+
+	// PingProtocolBeta18 identifies Beta 1.8 to 1.3 ping protocol, see PingBeta18.
+	PingProtocolBeta18
+)
+
+// pingNegotiationOrder defines the order Ping attempts protocols in, newest to oldest,
+// the same way Notchian client falls back to legacy ping. Response to each ping is parsed
+// the same way its version-specific ping function does, so Beta 1.8 is only detected if server
+// doesn't answer 1.4 ping, but does answer bare FE ping.
+var pingNegotiationOrder = []PingProtocol{PingProtocol17, PingProtocol16, PingProtocol14, PingProtocolBeta18}
+
+// String returns a user-friendly name of ping protocol.
+func (p PingProtocol) String() string {
+	switch p {
+	case PingProtocol17:
+		return "1.7+"
+	case PingProtocol16:
+		return "1.6"
+	case PingProtocol14:
+		return "1.4"
+	case PingProtocolBeta18:
+		return "Beta 1.8"
+	default:
+		return "unknown"
+	}
+}
+
+// Status holds version-agnostic status response returned by Ping. Common fields are filled
+// regardless of protocol that answered, and exactly one of protocol-specific fields
+// (the one matching Protocol) is set to the original response.
+type Status struct {
+	// Protocol is the ping protocol server responded to.
+	Protocol PingProtocol
+
+	// VersionName is server version name; it is empty for servers older than 1.6.
+	VersionName string
+
+	// ProtocolVersion is server protocol version; it is -1 for servers older than 1.6.
+	ProtocolVersion int
+
+	// MOTD is server MOTD (or description since 1.7+) as plain string, possibly with legacy §-formatting.
+	MOTD          string
+	OnlinePlayers int
+	MaxPlayers    int
+
+	// Host and Port are the address server answered at, which is the SRV record target and port
+	// if server was reached through SRV record.
+	Host string
+	Port int
+
+	// SRV is the SRV record server was reached through, or nil if it answered at the address
+	// ping was called with.
+	SRV *SRVRecord
+
+	Status17     *Status17
+	Status16     *Status16
+	Status14     *Status14
+	StatusBeta18 *StatusBeta18
+}
+
+// String returns a user-friendly representation of a server status response, which
+// is the one of protocol-specific response.
+func (s *Status) String() string {
+	switch {
+	case s.Status17 != nil:
+		return s.Status17.String()
+	case s.Status16 != nil:
+		return s.Status16.String()
+	case s.Status14 != nil:
+		return s.Status14.String()
+	case s.StatusBeta18 != nil:
+		return s.StatusBeta18.String()
+	default:
+		return fmt.Sprintf("Minecraft Server (%s), %d/%d players online, MOTD: %s",
+			s.Protocol, s.OnlinePlayers, s.MaxPlayers, naturalizeMOTD(s.MOTD))
+	}
+}
+
+// newStatus maps protocol-specific status response to version-agnostic Status.
+func newStatus(status interface{}) *Status {
+	switch status := status.(type) {
+	case *Status17:
+		return &Status{
+			Protocol:        PingProtocol17,
+			VersionName:     status.VersionName,
+			ProtocolVersion: status.ProtocolVersion,
+			MOTD:            status.Description.String(),
+			OnlinePlayers:   status.OnlinePlayers,
+			MaxPlayers:      status.MaxPlayers,
+			Status17:        status,
+		}
+	case *Status16:
+		return &Status{
+			Protocol:        PingProtocol16,
+			VersionName:     status.ServerVersion,
+			ProtocolVersion: status.ProtocolVersion,
+			MOTD:            status.MOTD,
+			OnlinePlayers:   status.OnlinePlayers,
+			MaxPlayers:      status.MaxPlayers,
+			Status16:        status,
+		}
+	case *Status14:
+		return &Status{
+			Protocol:        PingProtocol14,
+			ProtocolVersion: -1,
+			MOTD:            status.MOTD,
+			OnlinePlayers:   status.OnlinePlayers,
+			MaxPlayers:      status.MaxPlayers,
+			Status14:        status,
+		}
+	case *StatusBeta18:
+		return &Status{
+			Protocol:        PingProtocolBeta18,
+			ProtocolVersion: -1,
+			MOTD:            status.MOTD,
+			OnlinePlayers:   status.OnlinePlayers,
+			MaxPlayers:      status.MaxPlayers,
+			StatusBeta18:    status,
+		}
+	default:
+		panic(fmt.Sprintf("unexpected status type %T", status))
+	}
+}
+
+// withAddress sets address status was received from and returns status.
+func (s *Status) withAddress(addr pingAddress) *Status {
+	s.Host, s.Port, s.SRV = addr.Host, addr.Port, addr.SRV
+	return s
+}
+
+// Ping pings Minecraft servers of any version, detecting protocol server responds to.
+// See Pinger.Ping for details.
+//
+//goland:noinspection GoUnusedExportedFunction
+func Ping(host string, port int) (*Status, error) {
+	return defaultPinger.Ping(host, port)
+}
+
+// PingContext pings Minecraft servers of any version, detecting protocol server responds to.
+// See Pinger.PingContext for details.
+//
+//goland:noinspection GoUnusedExportedFunction
+func PingContext(ctx context.Context, host string, port int) (*Status, error) {
+	return defaultPinger.PingContext(ctx, host, port)
+}
+
+// Ping pings Minecraft servers of any version. It attempts 1.7+ ping first and falls back to
+// 1.6, 1.4 and Beta 1.8 pings (in this order) until server responds to one of them. Protocols are only fallen back from if server responded
+// in a way protocol doesn't expect, transport errors (such as refused connection or timeout) are
+// returned right away.
+//
+// Protocol server responded to is remembered in ProtocolCache (if enabled), so that
+// subsequent pings to the same host and port try it first.
+func (p *Pinger) Ping(host string, port int) (*Status, error) {
+	return p.PingContext(context.Background(), host, port)
+}
+
+// PingContext is the same as Ping, but uses the provided context for cancellation and deadlines.
+// Negotiation stops as soon as context is done.
+func (p *Pinger) PingContext(ctx context.Context, host string, port int) (*Status, error) {
+	// Use default Minecraft port if port is 0 so that cache key is consistent
+	if port == 0 {
+		port = defaultMinecraftPort
+	}
+
+	// Try previously detected protocol first, if there is one.
+	cached, hit := p.getCachedProtocol(host, port)
+	if hit {
+		status, addr, err := p.pingGeneric(ctx, p.pingFuncOf(cached), host, port)
+		if err == nil {
+			return newStatus(status).withAddress(addr), nil
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+		if !pingIsProtocolMismatch(cached, err) {
+			return nil, err
+		}
+		// On protocol mismatch, fall back to negotiation skipping the protocol that just failed.
+	}
+
+	negotiate := func(ctx context.Context, host string, port int) (interface{}, error) {
+		return p.pingNegotiate(ctx, host, port, cached)
+	}
+	status, addr, err := p.pingGeneric(ctx, negotiate, host, port)
+	if err != nil {
+		return nil, err
+	}
+
+	// Protocol older than the cached one is not cached, so that a single failure of cached
+	// protocol doesn't downgrade server for the whole cache entry lifetime.
+	res := newStatus(status).withAddress(addr)
+	if p.ProtocolCache != nil && (!hit || res.Protocol < cached) {
+		p.ProtocolCache.SetDefault(getProtocolCacheKey(host, port), res.Protocol)
+	}
+	return res, nil
+}
+
+// pingNegotiate attempts every protocol in pingNegotiationOrder (except skip) until one of them succeeds,
+// falling back to the next one only on protocol mismatch.
+func (p *Pinger) pingNegotiate(ctx context.Context, host string, port int, skip PingProtocol) (interface{}, error) {
+	errs := make([]string, 0, len(pingNegotiationOrder))
+	for _, candidate := range pingNegotiationOrder {
+		if candidate == skip {
+			continue
+		}
+
+		status, err := p.pingFuncOf(candidate)(ctx, host, port)
+		if err == nil {
+			return status, nil
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+		if !pingIsProtocolMismatch(candidate, err) {
+			return nil, err
+		}
+		errs = append(errs, fmt.Sprintf("%s: %s", candidate, err))
+	}
+	return nil, fmt.Errorf("%w: %s", ErrProtocolNotDetected, strings.Join(errs, "; "))
+}
+
+// pingIsProtocolMismatch reports whether err of ping with pingProtocol means server responded, but not
+// the way protocol expects (so that older protocol is worth trying), as opposed to transport errors like
+// refused connection or timeout, which older protocols would run into as well.
+func pingIsProtocolMismatch(pingProtocol PingProtocol, err error) bool {
+	// Pre-Netty servers reject 1.7+ handshake as a bad packet and close connection
+	// (possibly with kick packet, which isn't a valid Netty frame.)
+	mismatches := []error{
+		protocol.ErrUnexpectedPacket, protocol.ErrMalformedPacket, protocol.ErrVarIntTooLong,
+		io.EOF, io.ErrUnexpectedEOF, syscall.ECONNRESET,
+	}
+
+	// Legacy responses of other generations don't parse (and may be of any length), while
+	// invalid 1.7+ status is a problem of server that does speak 1.7+ protocol.
+	if pingProtocol != PingProtocol17 {
+		mismatches = append(mismatches, ErrInvalidStatus, ErrLimitExceeded)
+	}
+
+	for _, target := range mismatches {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// pingFuncOf returns version-specific ping function for protocol.
+func (p *Pinger) pingFuncOf(protocol PingProtocol) pingFunc {
+	switch protocol {
+	case PingProtocol17:
+		return p.ping17
+	case PingProtocol16:
+		return p.ping16
+	case PingProtocol14:
+		return p.ping14
+	case PingProtocolBeta18:
+		return p.pingBeta18
+	default:
+		panic(fmt.Sprintf("unexpected ping protocol %d", protocol))
+	}
+}
+
+// Protocol cache management
+
+func getProtocolCacheKey(host string, port int) string { return fmt.Sprintf("%s:%d", host, port) }
+
+func (p *Pinger) getCachedProtocol(host string, port int) (PingProtocol, bool) {
+	if p.ProtocolCache == nil {
+		return PingProtocolUnknown, false
+	}
+
+	data, hit := p.ProtocolCache.Get(getProtocolCacheKey(host, port))
+	if !hit {
+		return PingProtocolUnknown, false
+	}
+
+	protocol, ok := data.(PingProtocol)
+	return protocol, ok && protocol != PingProtocolUnknown
+}
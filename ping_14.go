@@ -1,17 +1,12 @@
 package minequery
 
 import (
-	"bytes"
+	"context"
 	"fmt"
 	"io"
-	"strconv"
 	"strings"
-)
-
-var ping14PingPacket = []byte{0xfe, 0x01}
 
-const (
-	ping14ResponsePayloadFieldSeparator = "§"
+	"github.com/dreamscached/minequery/v2/protocol"
 )
 
 // Status14 holds status response returned by 1.4 to 1.6 (exclusively) Minecraft servers.
@@ -19,6 +14,10 @@ type Status14 struct {
 	MOTD          string
 	OnlinePlayers int
 	MaxPlayers    int
+
+	// SRV is the SRV record server was reached through, or nil if it answered at the address
+	// ping was called with.
+	SRV *SRVRecord
 }
 
 // String returns a user-friendly representation of a server status response.
@@ -28,6 +27,9 @@ func (s *Status14) String() string {
 		s.OnlinePlayers, s.MaxPlayers, naturalizeMOTD(s.MOTD))
 }
 
+// MOTDSegments parses MOTD legacy §-formatting into styled text segments, see ParseLegacyMOTD.
+func (s *Status14) MOTDSegments() []ChatSegment { return ParseLegacyMOTD(s.MOTD) }
+
 // Ping14 pings 1.4 to 1.6 (exclusively) Minecraft servers (Notchian servers of more late versions also respond to
 // this ping packet.)
 //
@@ -36,18 +38,32 @@ func Ping14(host string, port int) (*Status14, error) {
 	return defaultPinger.Ping14(host, port)
 }
 
+// Ping14Context pings the same servers Ping14 does, using the provided context for
+// cancellation and deadlines of SRV lookup, connection establishment and packet exchange.
+//
+//goland:noinspection GoUnusedExportedFunction
+func Ping14Context(ctx context.Context, host string, port int) (*Status14, error) {
+	return defaultPinger.Ping14Context(ctx, host, port)
+}
+
 // Ping14 pings 1.4 to 1.6 (exclusively) Minecraft servers (Notchian servers of more late versions also respond to
 // this ping packet.)
 func (p *Pinger) Ping14(host string, port int) (*Status14, error) {
-	status, err := p.pingGeneric(p.ping14, host, port)
+	return p.Ping14Context(context.Background(), host, port)
+}
+
+// Ping14Context pings the same servers Ping14 does, using the provided context for
+// cancellation and deadlines of SRV lookup, connection establishment and packet exchange.
+func (p *Pinger) Ping14Context(ctx context.Context, host string, port int) (*Status14, error) {
+	status, _, err := p.pingGeneric(ctx, p.ping14, host, port)
 	if err != nil {
 		return nil, err
 	}
 	return status.(*Status14), nil
 }
 
-func (p *Pinger) ping14(host string, port int) (interface{}, error) {
-	conn, err := p.openTCPConn(host, port)
+func (p *Pinger) ping14(ctx context.Context, host string, port int) (interface{}, error) {
+	conn, _, err := p.openTCPConn(ctx, host, port)
 	if err != nil {
 		return nil, err
 	}
@@ -59,7 +75,7 @@ func (p *Pinger) ping14(host string, port int) (interface{}, error) {
 	}
 
 	// Read status response (note: uses the same packet reading approach as 1.4)
-	payload, err := p.pingBeta18ReadResponsePacket(conn)
+	payload, err := protocol.ReadKick(conn, p.limits().MaxPacketSize)
 	if err != nil {
 		return nil, fmt.Errorf("could not read response packet: %w", err)
 	}
@@ -77,17 +93,17 @@ func (p *Pinger) ping14(host string, port int) (interface{}, error) {
 
 func (p *Pinger) ping14WritePingPacket(writer io.Writer) error {
 	// Write 2-byte FE 01 ping packet
-	_, err := writer.Write(ping14PingPacket)
+	_, err := writer.Write(protocol.Encode14Ping())
 	return err
 }
 
 // Response processing
 
-func (p *Pinger) ping14ParseResponsePayload(payload []byte) (*Status14, error) {
+func (p *Pinger) ping14ParseResponsePayload(payload string) (*Status14, error) {
 	// NOTE: Spigot 1.4 servers reply with 1.6 response format.
 	// See https://github.com/dreamscached/minequery/issues/31 for details.
 	// Check if data string begins with '§1\x00' (00 a7 00 31 00 00) and pass processing to 1.6 logic in this case.
-	if bytes.HasPrefix(payload, ping16ResponsePrefix) {
+	if strings.HasPrefix(payload, protocol.Ping16ResponsePrefix) {
 		if p.UseStrict {
 			return nil, fmt.Errorf("%w: server unexpectedly replied with 1.6 response", ErrInvalidStatus)
 		}
@@ -104,27 +120,14 @@ func (p *Pinger) ping14ParseResponsePayload(payload []byte) (*Status14, error) {
 	}
 
 	// Split status string, parse and map to struct returning errors if conversions fail
-	fields := strings.Split(string(payload), ping14ResponsePayloadFieldSeparator)
-	if len(fields) != 3 {
-		return nil, fmt.Errorf("%w: expected 3 status fields, got %d", ErrInvalidStatus, len(fields))
-	}
-	motd, onlineString, maxString := fields[0], fields[1], fields[2]
-
-	// Parse online players
-	online, err := strconv.ParseInt(onlineString, 10, 32)
-	if err != nil {
-		return nil, fmt.Errorf("%w: could not parse online players count: %s", ErrInvalidStatus, err)
-	}
-
-	// Parse max players
-	max, err := strconv.ParseInt(maxString, 10, 32)
+	res, err := protocol.DecodeLegacyResponse(payload)
 	if err != nil {
-		return nil, fmt.Errorf("%w: could not parse max players count: %s", ErrInvalidStatus, err)
+		return nil, invalidStatus(err)
 	}
 
 	return &Status14{
-		MOTD:          motd,
-		OnlinePlayers: int(online),
-		MaxPlayers:    int(max),
+		MOTD:          res.MOTD,
+		OnlinePlayers: res.OnlinePlayers,
+		MaxPlayers:    res.MaxPlayers,
 	}, nil
 }
@@ -1,38 +1,13 @@
 package minequery
 
 import (
-	"bytes"
-	"encoding/binary"
+	"context"
 	"fmt"
 	"io"
-	"strconv"
-	"strings"
-)
-
-var (
-	ping16PingPacketHeader = []byte{
-		// Packet ID (FE)
-		0xfe,
-
-		// Ping payload (01)
-		0x01,
-
-		// Packet identifier for plugin message (FA)
-		0xfa,
-
-		// Length of 'MC|PingHost' string (11) as unsigned short
-		0x00, 0x0b,
 
-		// 'MC|PingHost' string as UTF-16BE
-		0x00, 0x4d, 0x00, 0x43, 0x00, 0x7c, 0x00, 0x50, 0x00, 0x69, 0x00, 0x6e, 0x00, 0x67, 0x00, 0x48, 0x00, 0x6f, 0x00, 0x73, 0x00, 0x74,
-	}
-	ping16ResponsePacketID byte = 0xff
+	"github.com/dreamscached/minequery/v2/protocol"
 )
 
-const ping16ResponseFieldSeparator = "\x00"
-
-var ping16ResponsePrefix = []byte{0xc2, 0xa7, 0x31, 0x0}
-
 // Ping16ProtocolVersionIncompatible holds a special value (=127) returned in response that indicates incompatible
 // Minecraft version (1.7+).
 const Ping16ProtocolVersionIncompatible byte = 127
@@ -420,6 +395,10 @@ type Status16 struct {
 	MOTD            string
 	OnlinePlayers   int
 	MaxPlayers      int
+
+	// SRV is the SRV record server was reached through, or nil if it answered at the address
+	// ping was called with.
+	SRV *SRVRecord
 }
 
 // String returns a user-friendly representation of a server status response.
@@ -429,6 +408,9 @@ func (s *Status16) String() string {
 		s.ServerVersion, s.ProtocolVersion, s.OnlinePlayers, s.MaxPlayers, naturalizeMOTD(s.MOTD))
 }
 
+// MOTDSegments parses MOTD legacy §-formatting into styled text segments, see ParseLegacyMOTD.
+func (s *Status16) MOTDSegments() []ChatSegment { return ParseLegacyMOTD(s.MOTD) }
+
 // IsIncompatible checks if response returned an incompatible protocol version (=127), meaning
 // this server cannot be joined unless client version is 1.7+.
 func (s *Status16) IsIncompatible() bool {
@@ -443,18 +425,32 @@ func Ping16(host string, port int) (*Status16, error) {
 	return defaultPinger.Ping16(host, port)
 }
 
+// Ping16Context pings the same servers Ping16 does, using the provided context for
+// cancellation and deadlines of SRV lookup, connection establishment and packet exchange.
+//
+//goland:noinspection GoUnusedExportedFunction
+func Ping16Context(ctx context.Context, host string, port int) (*Status16, error) {
+	return defaultPinger.Ping16Context(ctx, host, port)
+}
+
 // Ping16 pings 1.6 to 1.7 (exclusively) Minecraft servers (Notchian servers of more late versions also respond
 // to this ping packet.)
 func (p *Pinger) Ping16(host string, port int) (*Status16, error) {
-	status, err := p.pingGeneric(p.ping16, host, port)
+	return p.Ping16Context(context.Background(), host, port)
+}
+
+// Ping16Context pings the same servers Ping16 does, using the provided context for
+// cancellation and deadlines of SRV lookup, connection establishment and packet exchange.
+func (p *Pinger) Ping16Context(ctx context.Context, host string, port int) (*Status16, error) {
+	status, _, err := p.pingGeneric(ctx, p.ping16, host, port)
 	if err != nil {
 		return nil, err
 	}
 	return status.(*Status16), nil
 }
 
-func (p *Pinger) ping16(host string, port int) (interface{}, error) {
-	conn, err := p.openTCPConn(host, port)
+func (p *Pinger) ping16(ctx context.Context, host string, port int) (interface{}, error) {
+	conn, _, err := p.openTCPConn(ctx, host, port)
 	if err != nil {
 		return nil, err
 	}
@@ -470,7 +466,7 @@ func (p *Pinger) ping16(host string, port int) (interface{}, error) {
 	}
 
 	// Read status response (note: uses the same packet reading approach as 1.4)
-	payload, err := p.pingBeta18ReadResponsePacket(conn)
+	payload, err := protocol.ReadKick(conn, p.limits().MaxPacketSize)
 	if err != nil {
 		return nil, fmt.Errorf("could not read response packet: %w", err)
 	}
@@ -486,79 +482,30 @@ func (p *Pinger) ping16(host string, port int) (interface{}, error) {
 
 // Communication
 
-func (p *Pinger) ping16WritePingPacket(writer io.Writer, protocol byte, host string, port int) error {
-	// Allocate buffer with initial capacity of 64 which should be enough for most packets.
-	packet := bytes.NewBuffer(make([]byte, 0, 64))
-
-	// Write hardcoded (it doesn't change ever) packet header
-	packet.Write(ping16PingPacketHeader)
-
-	// Encode hostname to UTF16BE and store in buffer to calculate length further on
-	hb := &bytes.Buffer{}
-	if _, err := utf16BEEncoder.Writer(hb).Write([]byte(host)); err != nil {
-		return err
-	}
-
-	// Write packet length (7 + length of hostname string)
-	_ = binary.Write(packet, binary.BigEndian, uint16(7+hb.Len()))
-
-	// Get preferred protocol version and fallback to Ping16ProtocolVersion162 if not set
-	// and write it to packet
-	packet.WriteByte(protocol)
-
-	// Write hostname string length
-	_ = binary.Write(packet, binary.BigEndian, uint16(len(host)))
-
-	// Write hostname string
-	_, _ = hb.WriteTo(packet)
-
-	// Write target server port
-	_ = binary.Write(packet, binary.BigEndian, uint32(port))
-
-	_, err := packet.WriteTo(writer)
+func (p *Pinger) ping16WritePingPacket(writer io.Writer, protocolVersion byte, host string, port int) error {
+	// Write FE 01 ping packet followed by MC|PingHost plugin message
+	_, err := writer.Write(protocol.Encode16Ping(&protocol.PingHost{
+		ProtocolVersion: protocolVersion,
+		Host:            host,
+		Port:            int32(port),
+	}))
 	return err
 }
 
 // Response processing
 
-func (p *Pinger) ping16ParseResponsePayload(payload []byte) (*Status16, error) {
-	// Check if data string begins with '§1\x00' (00 a7 00 31 00 00) and strip it
-	if bytes.HasPrefix(payload, ping16ResponsePrefix) {
-		payload = payload[len(ping16ResponsePrefix):]
-	} else if p.UseStrict {
-		return nil, fmt.Errorf("%w: status string is missing necessary prefix", ErrInvalidStatus)
-	}
-
-	// Split status string, parse and map to struct returning errors if conversions fail
-	fields := strings.Split(string(payload), ping16ResponseFieldSeparator)
-	if len(fields) != 5 {
-		return nil, fmt.Errorf("%w: expected 5 status fields, got %d", ErrInvalidStatus, len(fields))
-	}
-	serverProtocolVersionString, serverVersion, motd, onlineString, maxString := fields[0], fields[1], fields[2], fields[3], fields[4]
-
-	// Parse protocol version
-	serverProtocolVersion, err := strconv.ParseInt(serverProtocolVersionString, 10, 32)
-	if err != nil {
-		return nil, fmt.Errorf("%w: could not parse protocol version: %s", ErrInvalidStatus, err)
-	}
-
-	// Parse online players
-	online, err := strconv.ParseInt(onlineString, 10, 32)
-	if err != nil {
-		return nil, fmt.Errorf("%w: could not parse online players count: %s", ErrInvalidStatus, err)
-	}
-
-	// Parse max players
-	max, err := strconv.ParseInt(maxString, 10, 32)
+func (p *Pinger) ping16ParseResponsePayload(payload string) (*Status16, error) {
+	// Strip '§1\x00' prefix (required only if UseStrict is set), split and parse status string
+	res, err := protocol.DecodePing16Response(payload, p.UseStrict)
 	if err != nil {
-		return nil, fmt.Errorf("%w: could not parse max players count: %s", ErrInvalidStatus, err)
+		return nil, invalidStatus(err)
 	}
 
 	return &Status16{
-		ProtocolVersion: int(serverProtocolVersion),
-		ServerVersion:   serverVersion,
-		MOTD:            motd,
-		OnlinePlayers:   int(online),
-		MaxPlayers:      int(max),
+		ProtocolVersion: res.ProtocolVersion,
+		ServerVersion:   res.ServerVersion,
+		MOTD:            res.MOTD,
+		OnlinePlayers:   res.OnlinePlayers,
+		MaxPlayers:      res.MaxPlayers,
 	}, nil
 }
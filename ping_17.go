@@ -2,22 +2,28 @@ package minequery
 
 import (
 	"bytes"
-	"encoding/binary"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"image"
+	"image/png"
 	"io"
 	"strings"
+	"time"
 
+	"github.com/dreamscached/minequery/v2/protocol"
 	"github.com/google/uuid"
 )
 
 var (
-	ping17HandshakePacketID uint32 = 0
-	ping17NextStateStatus   uint32 = 1
+	ping17StatusImagePrefix = "data:image/png;base64,"
 
-	ping17StatusRequestPacketID  uint32 = 0
-	ping17StatusResponsePacketID uint32 = 0
-	ping17StatusImagePrefix             = "data:image/png;base64,"
+	// ping17StatusKnownFields lists top-level status response fields mapped by status17JsonMapping.
+	ping17StatusKnownFields = []string{
+		"version", "players", "description", "favicon",
+		"previewsChat", "enforcesSecureChat", "modinfo", "forgeData",
+	}
 )
 
 // Ping17ProtocolVersionUndefined holds a special value (=-1) sent in ping packet that indicates that client
@@ -1825,61 +1831,39 @@ type PlayerEntry17 struct {
 }
 
 // Chat17 holds arbitrary Chat data decoded from JSON and can be converted to string
-// by decoding chat component JSON.
+// by decoding chat component JSON. ChatComponent itself implements Chat17 too; structured
+// component tree of Status17 Description is returned by DescriptionComponent.
 type Chat17 interface{ fmt.Stringer }
-type chat17 struct{ Component interface{} }
+type chat17 struct{ raw interface{} }
 
 func newChat17(component interface{}) *chat17 { return &chat17{component} }
 
-func (c *chat17) String() string {
-	componentStack := make(stack, 0, 8)
-	buffer := bytes.NewBuffer(make([]byte, 0, 128))
-
-	// Push root component to stack, whatever it is (a slice, a map or a string)
-	componentStack.Push(c.Component)
-
-	for len(componentStack) > 0 {
-		// Remove topmost element from stack and get it for processing
-		current, _ := componentStack.Pop()
-
-		switch current.(type) {
-		case string:
-			// If component is a string, just write it to a buffer
-			buffer.WriteString(current.(string))
-
-		case []interface{}:
-			// If component is a slice, push its items to stack in reverse order
-			// (so that they are processed in natural order because stack is LIFO)
-			current := current.([]interface{})
-			for i := len(current) - 1; i >= 0; i-- {
-				componentStack.Push(current[i])
-			}
-
-		case map[string]interface{}:
-			// If component is an object, first its text/translate properties are handled;
-			// subcomponents (aka extra) are processed last and are appended in the end of the string.
-			current := current.(map[string]interface{})
-
-			// Push extra to stack (if there is any) first as it must be processed last (stack is LIFO)
-			if extra, ok := current["extra"]; ok {
-				componentStack.Push(extra)
-			}
-
-			// Push component text to stack (if there is any)
-			if text, ok := current["text"]; ok {
-				componentStack.Push(text)
-			} else if translate, ok := current["translate"]; ok {
-				// If component did not contain text property, look for translate property
-				// and write translate string as is, without applying "with" components or actually trying to
-				// translate anything.
-				componentStack.Push(translate)
-			}
-		}
+// Component returns structured chat component tree decoded from chat JSON.
+func (c *chat17) Component() *ChatComponent { return newChatComponent(c.raw) }
+
+// chat17Componenter is implemented by Chat17 values that can be converted to ChatComponent tree
+// (Chat17 decoded by Pinger and ChatComponent itself).
+type chat17Componenter interface{ Component() *ChatComponent }
+
+// chat17Component converts chat to ChatComponent tree. Chat17 values that cannot be converted
+// are represented with a text component holding their string, and nil chat is converted to nil.
+func chat17Component(chat Chat17) *ChatComponent {
+	switch chat := chat.(type) {
+	case nil:
+		return nil
+	case chat17Componenter:
+		return chat.Component()
+	default:
+		return &ChatComponent{Type: ChatComponentText, Text: chat.String()}
 	}
-
-	return buffer.String()
 }
 
+// String returns text of chat without formatting, see ChatComponent String.
+func (c *chat17) String() string { return c.Component().String() }
+
+// MarshalJSON encodes chat as the original chat component JSON it was decoded from.
+func (c chat17) MarshalJSON() ([]byte, error) { return json.Marshal(c.raw) }
+
 type status17JsonMapping struct {
 	Version struct {
 		Name     string `json:"name"`
@@ -1900,6 +1884,9 @@ type status17JsonMapping struct {
 
 	PreviewsChat       bool `json:"previewsChat,omitempty"`
 	EnforcesSecureChat bool `json:"enforcesSecureChat,omitempty"`
+
+	ModInfo   *modInfo17JsonMapping   `json:"modinfo,omitempty"`
+	ForgeData *forgeData17JsonMapping `json:"forgeData,omitempty"`
 }
 
 // Status17 holds status response returned by 1.7+ Minecraft servers.
@@ -1916,6 +1903,48 @@ type Status17 struct {
 
 	PreviewsChat       bool
 	EnforcesSecureChat bool
+
+	// ModInfo holds mod loader type, mods and channels of modded (Forge and NeoForge) servers.
+	// It is nil for servers that do not report any.
+	ModInfo *ModInfo17
+
+	// Raw holds the original JSON payload of status response.
+	Raw []byte
+
+	// UnknownFields holds top-level fields of status response not mapped to any of the fields above
+	// (such as preventsChatReports, isModded or proxy-specific extensions), decoded with UnmarshalFunc.
+	UnknownFields map[string]interface{}
+
+	// ConnectTime is the time it took to establish TCP connection with server (including SOCKS5
+	// negotiation of custom dialers), excluding DNS lookups of Resolver and PROXY protocol header.
+	ConnectTime time.Duration
+
+	// Latency is the round-trip time of ping/pong packet exchange that follows status response.
+	// It is only measured if Pinger MeasureLatency is set, and is zero otherwise (or if measurement failed,
+	// see LatencyErr.)
+	Latency time.Duration
+
+	// LatencyErr is the error latency measurement failed with if Pinger UseStrict is not set (with UseStrict,
+	// ping itself fails instead.) It is nil if latency was measured or not measured at all.
+	LatencyErr error
+
+	// SRV is the SRV record server was reached through, or nil if it answered at the address
+	// ping was called with.
+	SRV *SRVRecord
+
+	// unmarshalFunc is UnmarshalFunc of Pinger the status was received with.
+	unmarshalFunc UnmarshalFunc
+}
+
+// Unmarshal decodes the original JSON payload of status response (see Raw) into v using UnmarshalFunc
+// of Pinger the status was received with (or json.Unmarshal if status was not received by Pinger).
+// It is useful for reading custom fields that are not mapped to Status17 fields.
+func (s *Status17) Unmarshal(v interface{}) error {
+	unmarshal := s.unmarshalFunc
+	if unmarshal == nil {
+		unmarshal = json.Unmarshal
+	}
+	return unmarshal(s.Raw, v)
 }
 
 // String returns a user-friendly representation of a server status response.
@@ -1925,6 +1954,10 @@ func (s *Status17) String() string {
 		s.VersionName, s.ProtocolVersion, s.OnlinePlayers, s.MaxPlayers, naturalizeMOTD(s.Description.String()))
 }
 
+// DescriptionComponent returns structured chat component tree of Description with text, style and
+// children of every component, or nil if Description is nil.
+func (s *Status17) DescriptionComponent() *ChatComponent { return chat17Component(s.Description) }
+
 // DescriptionText collects text components of Description together into normal string.
 //
 // Deprecated: this function is deprecated and is retained for compatibility. Newer software
@@ -1938,17 +1971,31 @@ func Ping17(host string, port int) (*Status17, error) {
 	return defaultPinger.Ping17(host, port)
 }
 
+// Ping17Context pings the same servers Ping17 does, using the provided context for
+// cancellation and deadlines of SRV lookup, connection establishment and packet exchange.
+//
+//goland:noinspection GoUnusedExportedFunction
+func Ping17Context(ctx context.Context, host string, port int) (*Status17, error) {
+	return defaultPinger.Ping17Context(ctx, host, port)
+}
+
 // Ping17 pings 1.7+ Minecraft servers.
 func (p *Pinger) Ping17(host string, port int) (*Status17, error) {
-	status, err := p.pingGeneric(p.ping17, host, port)
+	return p.Ping17Context(context.Background(), host, port)
+}
+
+// Ping17Context pings the same servers Ping17 does, using the provided context for
+// cancellation and deadlines of SRV lookup, connection establishment and packet exchange.
+func (p *Pinger) Ping17Context(ctx context.Context, host string, port int) (*Status17, error) {
+	status, _, err := p.pingGeneric(ctx, p.ping17, host, port)
 	if err != nil {
 		return nil, err
 	}
 	return status.(*Status17), nil
 }
 
-func (p *Pinger) ping17(host string, port int) (interface{}, error) {
-	conn, err := p.openTCPConn(host, port)
+func (p *Pinger) ping17(ctx context.Context, host string, port int) (interface{}, error) {
+	conn, connectTime, err := p.openTCPConn(ctx, host, port)
 	if err != nil {
 		return nil, err
 	}
@@ -1969,7 +2016,8 @@ func (p *Pinger) ping17(host string, port int) (interface{}, error) {
 	}
 
 	// Read status response
-	payload, err := p.ping17ReadStatusResponsePacketPayload(conn)
+	reader := p.ping17NewPacketReader(conn)
+	payload, err := p.ping17ReadStatusResponsePacketPayload(reader)
 	if err != nil {
 		return nil, fmt.Errorf("could not read response packet: %w", err)
 	}
@@ -1979,107 +2027,84 @@ func (p *Pinger) ping17(host string, port int) (interface{}, error) {
 	if err != nil {
 		return nil, fmt.Errorf("could not parse status from response packet: %w", err)
 	}
+	res.ConnectTime = connectTime
+
+	// Measure latency with ping/pong exchange (returning on errors if UseStrict, or recording them otherwise)
+	if p.MeasureLatency {
+		res.Latency, err = p.ping17MeasureLatency(conn, reader)
+		if err != nil {
+			if p.UseStrict {
+				return nil, fmt.Errorf("could not measure latency: %w", err)
+			}
+			res.LatencyErr = err
+		}
+	}
 
 	return res, nil
 }
 
-// Communication
+func (p *Pinger) ping17MeasureLatency(writer io.Writer, reader *protocol.PacketReader) (time.Duration, error) {
+	start := time.Now()
+	payload := start.UnixNano()
 
-func (p *Pinger) ping17WritePacket(writer io.Writer, packetID uint32, payloadData []byte) error {
-	// Allocate payload buffer of size = 5 (payload length field) + payload length
-	pb := bytes.NewBuffer(make([]byte, 0, 5+len(payloadData)))
-
-	// Write packet ID as unsigned VarInt to content buffer
-	b := make([]byte, 5)
-	pb.Write(b[:binary.PutUvarint(b, uint64(packetID))])
-
-	// Copy packet data to content buffer
-	_, _ = pb.Write(payloadData)
-
-	// Allocate packet buffer of size = 5 (packet ID) + payload length
-	packet := bytes.NewBuffer(make([]byte, 0, 5+pb.Len()))
-
-	// Write packet data length to packet buffer unsigned VarInt
-	packet.Write(b[:binary.PutUvarint(b, uint64(pb.Len()))])
+	// Send ping packet with arbitrary payload that server must echo back
+	if err := p.ping17WritePingPacket(writer, payload); err != nil {
+		return 0, fmt.Errorf("could not write ping packet: %w", err)
+	}
 
-	// Write content buffer to packet buffer
-	_, _ = pb.WriteTo(packet)
+	// Read pong packet and ensure payload is the same (if UseStrict)
+	pongPayload, err := p.ping17ReadPongPacketPayload(reader)
+	if err != nil {
+		return 0, fmt.Errorf("could not read pong packet: %w", err)
+	}
+	latency := time.Since(start)
+	if pongPayload != payload && p.UseStrict {
+		return 0, fmt.Errorf("%w: expected pong payload %#x, but instead got %#x", ErrInvalidStatus, payload, pongPayload)
+	}
 
-	_, err := packet.WriteTo(writer)
-	return err
+	return latency, nil
 }
 
-func (p *Pinger) ping17WriteHandshakePacket(writer io.Writer, protocol int32, host string, port int) error {
-	packet := bytes.NewBuffer(make([]byte, 0, 32))
-
-	// Write protocol version as VarInt
-	b := make([]byte, 5)
-	packet.Write(b[:binary.PutVarint(b, int64(protocol))])
-
-	// Write length of hostname string as unsigned VarInt
-	packet.Write(b[:binary.PutUvarint(b, uint64(len(host)))])
-
-	// Write hostname string as byte array
-	packet.Write([]byte(host))
-
-	// Write port as unsigned short
-	_ = binary.Write(packet, binary.BigEndian, uint16(port))
-
-	// Write next state as unsigned VarInt
-	packet.Write(b[:binary.PutUvarint(b, uint64(ping17NextStateStatus))])
+// Communication
 
-	return p.ping17WritePacket(writer, ping17HandshakePacketID, packet.Bytes())
+func (p *Pinger) ping17WriteHandshakePacket(writer io.Writer, protocolVersion int32, host string, port int) error {
+	return protocol.NewPacketWriter(writer).WritePacket(protocol.EncodeHandshake(&protocol.Handshake{
+		ProtocolVersion: protocolVersion,
+		ServerAddress:   host,
+		ServerPort:      uint16(port),
+		NextState:       protocol.NextStateStatus,
+	}))
 }
 
 func (p *Pinger) ping17WriteStatusRequestPacket(writer io.Writer) error {
-	// Write empty status request packet with only packet ID and zero length
-	return p.ping17WritePacket(writer, ping17StatusRequestPacketID, nil)
+	return protocol.NewPacketWriter(writer).WritePacket(protocol.EncodeStatusRequest())
 }
 
-func (p *Pinger) ping17ReadStatusResponsePacketPayload(reader io.Reader) ([]byte, error) {
-	// Allocate buffer of 5 bytes (VarInt maximum length) and read packet length
-	lb := make([]byte, 5)
-	ln, err := reader.Read(lb)
-	if err != nil {
-		return nil, err
-	}
-	lr := bytes.NewReader(lb)
-
-	// Read packet length as unsigned VarInt
-	pl, err := binary.ReadUvarint(lr)
-	if err != nil {
-		return nil, err
-	}
-
-	// Read entire packet to a buffer
-	pb := bytes.NewBuffer(make([]byte, 0, pl))
-	pb.Write(lb[maxInt(ln-lr.Len(), 0):ln])
-	if _, err = io.CopyN(pb, reader, int64(pl)-int64(lr.Len())); err != nil {
-		return nil, err
-	}
-	pr := bytes.NewReader(pb.Bytes())
+func (p *Pinger) ping17WritePingPacket(writer io.Writer, payload int64) error {
+	return protocol.NewPacketWriter(writer).WritePacket(protocol.EncodePing(payload))
+}
 
-	// Read packet ID as unsigned VarInt
-	id, err := binary.ReadUvarint(pr)
+func (p *Pinger) ping17ReadStatusResponsePacketPayload(reader *protocol.PacketReader) ([]byte, error) {
+	packet, err := reader.ReadPacket()
 	if err != nil {
 		return nil, err
-	} else if uint32(id) != ping17StatusResponsePacketID {
-		return nil, fmt.Errorf("expected packet ID %#x, but instead got %#x", ping17StatusResponsePacketID, id)
 	}
+	return protocol.DecodeStatusResponse(packet, p.limits().MaxJSONSize)
+}
 
-	// Read status payload length
-	dl, err := binary.ReadUvarint(pr)
+func (p *Pinger) ping17ReadPongPacketPayload(reader *protocol.PacketReader) (int64, error) {
+	packet, err := reader.ReadPacket()
 	if err != nil {
-		return nil, err
-	}
-
-	// Read packet payload
-	db := bytes.NewBuffer(make([]byte, 0, dl))
-	if _, err = io.CopyN(db, pr, int64(dl)); err != nil {
-		return nil, err
+		return 0, err
 	}
+	return protocol.DecodePong(packet)
+}
 
-	return db.Bytes(), nil
+// ping17NewPacketReader constructs PacketReader of conn limited by Pinger Limits.
+func (p *Pinger) ping17NewPacketReader(conn io.Reader) *protocol.PacketReader {
+	reader := protocol.NewPacketReader(conn)
+	reader.MaxPacketSize = p.limits().MaxPacketSize
+	return reader
 }
 
 // Response processing
@@ -2100,6 +2125,17 @@ func (p *Pinger) ping17ParseStatusResponsePayload(payload []byte) (*Status17, er
 		Description:        newChat17(statusMapping.Description),
 		PreviewsChat:       statusMapping.PreviewsChat,
 		EnforcesSecureChat: statusMapping.EnforcesSecureChat,
+		Raw:                payload,
+		unmarshalFunc:      p.UnmarshalFunc,
+	}
+
+	// Collect top-level fields that are not mapped (they are of no interest if the map can't be decoded)
+	var fields map[string]interface{}
+	if err := p.UnmarshalFunc(payload, &fields); err == nil {
+		for _, key := range ping17StatusKnownFields {
+			delete(fields, key)
+		}
+		status.UnknownFields = fields
 	}
 
 	// Process players sample (optionally, if UseStrict, returning on tolerable errors)
@@ -2117,6 +2153,13 @@ func (p *Pinger) ping17ParseStatusResponsePayload(payload []byte) (*Status17, er
 		status.SamplePlayers[i] = PlayerEntry17{entry.Name, id}
 	}
 
+	// Process mod info (optionally, if UseStrict, returning on tolerable errors)
+	modInfo, err := p.ping17ParseModInfo(statusMapping.ModInfo, statusMapping.ForgeData)
+	if err != nil && p.UseStrict {
+		return nil, err
+	}
+	status.ModInfo = modInfo
+
 	// Process icon (optionally, if UseStrict, returning on tolerable errors)
 	if statusMapping.Favicon != "" {
 		if !strings.HasPrefix(statusMapping.Favicon, ping17StatusImagePrefix) {
@@ -2125,19 +2168,42 @@ func (p *Pinger) ping17ParseStatusResponsePayload(payload []byte) (*Status17, er
 				return nil, fmt.Errorf("%w: invalid favicon data URL", ErrInvalidStatus)
 			}
 		} else {
-			// Decode Base64 string from favicon data URL
-			pngData, err := p.ImageEncoding.DecodeString(statusMapping.Favicon[len(ping17StatusImagePrefix):])
-			if err != nil {
-				return nil, fmt.Errorf("%w: invalid favicon image: %s", ErrInvalidStatus, err)
-			}
-
-			// Decode PNG image from binary data
-			status.Icon, err = p.ImageDecodeFunc(bytes.NewReader(pngData))
-			if err != nil {
-				return nil, fmt.Errorf("%w: invalid favicon image: %s", ErrInvalidStatus, err)
+			// Favicon exceeding limits only concerns us if in UseStrict mode; drop it otherwise
+			icon, err := p.ping17DecodeFavicon(statusMapping.Favicon[len(ping17StatusImagePrefix):])
+			if err != nil && (p.UseStrict || !errors.Is(err, ErrLimitExceeded)) {
+				return nil, err
 			}
+			status.Icon = icon
 		}
 	}
 
 	return status, nil
 }
+
+// ping17DecodeFavicon decodes PNG favicon image from Base64 string of favicon data URL, returning
+// *LimitError if favicon exceeds MaxFaviconSize or MaxFaviconDimension limits.
+func (p *Pinger) ping17DecodeFavicon(encoded string) (image.Image, error) {
+	// Ensure favicon fits limit before decoding Base64 string
+	limits := p.limits()
+	if err := checkLimit("MaxFaviconSize", int64(p.ImageEncoding.DecodedLen(len(encoded))), limits.MaxFaviconSize); err != nil {
+		return nil, err
+	}
+	pngData, err := p.ImageEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid favicon image: %s", ErrInvalidStatus, err)
+	}
+
+	// Ensure image dimensions fit limit before decoding it (if header can't be decoded, decoder reports it)
+	if config, err := png.DecodeConfig(bytes.NewReader(pngData)); err == nil {
+		if err = checkLimit("MaxFaviconDimension", int64(maxInt(config.Width, config.Height)), limits.MaxFaviconDimension); err != nil {
+			return nil, err
+		}
+	}
+
+	// Decode PNG image from binary data
+	icon, err := p.ImageDecodeFunc(bytes.NewReader(pngData))
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid favicon image: %s", ErrInvalidStatus, err)
+	}
+	return icon, nil
+}
@@ -0,0 +1,263 @@
+package minequery
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dreamscached/minequery/v2/protocol"
+)
+
+const (
+	pingBedrockResponseFieldSeparator  = ';'
+	pingBedrockResponseEscapeCharacter = '\\'
+)
+
+// defaultBedrockPort is a default port Bedrock Edition server runs on and which
+// will be used when server port is left as zero value.
+const defaultBedrockPort = 19132
+
+// StatusBedrock holds status response returned by Bedrock Edition Minecraft servers (and proxies like Geyser).
+//
+// Servers of older versions return only the first six fields, the rest are left zero in this case.
+type StatusBedrock struct {
+	// Edition is server edition; MCPE for Bedrock Edition and MCEE for Education Edition.
+	Edition string
+
+	// MOTD is the first line of server MOTD.
+	MOTD string
+
+	ProtocolVersion int
+	VersionName     string
+	OnlinePlayers   int
+	MaxPlayers      int
+	ServerGUID      uint64
+
+	// LevelName is the second line of server MOTD, which is the level name on vanilla servers.
+	LevelName string
+
+	GameMode   string
+	GameModeID int
+	PortIPv4   int
+	PortIPv6   int
+}
+
+// String returns a user-friendly representation of a server status response.
+// It contains server edition, version, protocol version number, online count and naturalized MOTD.
+func (s *StatusBedrock) String() string {
+	return fmt.Sprintf("Minecraft Server (%s, %s, protocol version %d), %d/%d players online, MOTD: %s",
+		s.Edition, s.VersionName, s.ProtocolVersion, s.OnlinePlayers, s.MaxPlayers,
+		naturalizeMOTD(s.MOTD+" "+s.LevelName))
+}
+
+// PingBedrock pings Bedrock Edition Minecraft servers with RakNet Unconnected Ping.
+//
+//goland:noinspection GoUnusedExportedFunction
+func PingBedrock(host string, port int) (*StatusBedrock, error) {
+	return defaultPinger.PingBedrock(host, port)
+}
+
+// PingBedrockContext pings the same servers PingBedrock does, using the provided context for
+// cancellation and deadlines of connection establishment and packet exchange.
+//
+//goland:noinspection GoUnusedExportedFunction
+func PingBedrockContext(ctx context.Context, host string, port int) (*StatusBedrock, error) {
+	return defaultPinger.PingBedrockContext(ctx, host, port)
+}
+
+// PingBedrock pings Bedrock Edition Minecraft servers with RakNet Unconnected Ping.
+// If port is zero, default Bedrock Edition port (19132) is used. SRV records are not used
+// by Bedrock Edition, so PreferSRVRecord has no effect on this function.
+func (p *Pinger) PingBedrock(host string, port int) (*StatusBedrock, error) {
+	return p.PingBedrockContext(context.Background(), host, port)
+}
+
+// PingBedrockContext pings the same servers PingBedrock does, using the provided context for
+// cancellation and deadlines of connection establishment and packet exchange.
+func (p *Pinger) PingBedrockContext(ctx context.Context, host string, port int) (*StatusBedrock, error) {
+	// Use default Bedrock Edition port if port is 0
+	if port == 0 {
+		port = defaultBedrockPort
+	}
+
+	res, err := p.pingBedrock(ctx, host, port)
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+		return nil, err
+	}
+	return res, nil
+}
+
+func (p *Pinger) pingBedrock(ctx context.Context, host string, port int) (*StatusBedrock, error) {
+	conn, err := p.openUDPConn(ctx, host, port)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = conn.Close() }()
+
+	// Send unconnected ping packet
+	timestamp := time.Now().UnixNano() / int64(time.Millisecond)
+	if err = p.pingBedrockWriteUnconnectedPingPacket(conn, timestamp); err != nil {
+		return nil, fmt.Errorf("could not write ping packet: %w", err)
+	}
+
+	// Read unconnected pong response
+	payload, err := p.pingBedrockReadUnconnectedPongPacket(conn, timestamp)
+	if err != nil {
+		return nil, fmt.Errorf("could not read response packet: %w", err)
+	}
+
+	// Parse response data from pong packet
+	res, err := p.pingBedrockParseResponsePayload(payload)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse status from response packet: %w", err)
+	}
+
+	return res, nil
+}
+
+// Communication
+
+func (p *Pinger) pingBedrockWriteUnconnectedPingPacket(writer io.Writer, timestamp int64) error {
+	// Generate random client GUID
+	guid := make([]byte, 8)
+	_, _ = rand.Read(guid)
+
+	_, err := writer.Write(protocol.EncodeBedrockUnconnectedPing(&protocol.BedrockUnconnectedPing{
+		Timestamp:  timestamp,
+		ClientGUID: int64(binary.BigEndian.Uint64(guid)),
+	}))
+	return err
+}
+
+func (p *Pinger) pingBedrockReadUnconnectedPongPacket(reader io.Reader, timestamp int64) (string, error) {
+	// Read UDP packet and decode it, ensuring offline message magic is valid (if UseStrict)
+	b, err := p.readDatagram(reader)
+	if err != nil {
+		return "", err
+	}
+	pong, err := protocol.DecodeBedrockUnconnectedPong(b, p.UseStrict)
+	if err != nil {
+		return "", err
+	}
+
+	// Ensure echoed timestamp is the one in request (if UseStrict)
+	if pong.Timestamp != timestamp && p.UseStrict {
+		return "", fmt.Errorf("expected timestamp %#x, but instead got %#x", timestamp, pong.Timestamp)
+	}
+
+	return pong.Payload, nil
+}
+
+// Response processing
+
+func (p *Pinger) pingBedrockParseResponsePayload(payload string) (*StatusBedrock, error) {
+	// Split status string, parse and map to struct returning errors if conversions fail
+	fields := pingBedrockSplitResponsePayload(payload)
+	if len(fields) < 6 {
+		return nil, fmt.Errorf("%w: expected at least 6 status fields, got %d", ErrInvalidStatus, len(fields))
+	}
+
+	// Parse protocol version
+	protocolVersion, err := strconv.ParseInt(fields[2], 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("%w: could not parse protocol version: %s", ErrInvalidStatus, err)
+	}
+
+	// Parse online players
+	online, err := strconv.ParseInt(fields[4], 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("%w: could not parse online players count: %s", ErrInvalidStatus, err)
+	}
+
+	// Parse max players
+	max, err := strconv.ParseInt(fields[5], 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("%w: could not parse max players count: %s", ErrInvalidStatus, err)
+	}
+
+	status := &StatusBedrock{
+		Edition:         fields[0],
+		MOTD:            fields[1],
+		ProtocolVersion: int(protocolVersion),
+		VersionName:     fields[3],
+		OnlinePlayers:   int(online),
+		MaxPlayers:      int(max),
+	}
+
+	// Process optional fields sent by newer servers (optionally, if UseStrict, returning on tolerable errors)
+	optionalField := func(i int) string {
+		if i < len(fields) {
+			return fields[i]
+		}
+		return ""
+	}
+	if guid := optionalField(6); guid != "" {
+		// Some servers send GUID as signed long, so try both interpretations
+		if status.ServerGUID, err = strconv.ParseUint(guid, 10, 64); err != nil {
+			signed, signedErr := strconv.ParseInt(guid, 10, 64)
+			if signedErr != nil && p.UseStrict {
+				return nil, fmt.Errorf("%w: could not parse server GUID: %s", ErrInvalidStatus, err)
+			}
+			status.ServerGUID = uint64(signed)
+		}
+	}
+	status.LevelName = optionalField(7)
+	status.GameMode = optionalField(8)
+	for i, target := range []*int{&status.GameModeID, &status.PortIPv4, &status.PortIPv6} {
+		value := optionalField(9 + i)
+		if value == "" {
+			continue
+		}
+		parsed, err := strconv.ParseInt(value, 10, 32)
+		if err != nil {
+			if p.UseStrict {
+				return nil, fmt.Errorf("%w: could not parse status field %d: %s", ErrInvalidStatus, 9+i, err)
+			}
+			continue
+		}
+		*target = int(parsed)
+	}
+
+	return status, nil
+}
+
+// pingBedrockSplitResponsePayload splits payload string by semicolons, treating backslash-escaped
+// semicolons (that some server software uses in MOTD) as literal characters. Trailing empty field
+// (payload string usually ends with a semicolon) is omitted.
+func pingBedrockSplitResponsePayload(payload string) []string {
+	fields := make([]string, 0, 12)
+	var field strings.Builder
+	escaped := false
+	for _, r := range payload {
+		switch {
+		case escaped:
+			if r != pingBedrockResponseFieldSeparator && r != pingBedrockResponseEscapeCharacter {
+				field.WriteRune(pingBedrockResponseEscapeCharacter)
+			}
+			field.WriteRune(r)
+			escaped = false
+		case r == pingBedrockResponseEscapeCharacter:
+			escaped = true
+		case r == pingBedrockResponseFieldSeparator:
+			fields = append(fields, field.String())
+			field.Reset()
+		default:
+			field.WriteRune(r)
+		}
+	}
+	if escaped {
+		field.WriteRune(pingBedrockResponseEscapeCharacter)
+	}
+	if field.Len() > 0 {
+		fields = append(fields, field.String())
+	}
+	return fields
+}
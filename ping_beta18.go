@@ -1,19 +1,11 @@
 package minequery
 
 import (
-	"bytes"
-	"encoding/binary"
+	"context"
 	"fmt"
 	"io"
-	"strconv"
-	"strings"
-)
-
-var pingBeta18PingPacket = []byte{0xfe}
 
-const (
-	pingBeta18ResponsePacketID       byte = 0xff
-	pingBeta18ResponseFieldSeparator      = "§"
+	"github.com/dreamscached/minequery/v2/protocol"
 )
 
 // StatusBeta18 holds status response returned by Beta 1.8 to Release 1.4 (exclusively) Minecraft servers.
@@ -21,6 +13,10 @@ type StatusBeta18 struct {
 	MOTD          string
 	OnlinePlayers int
 	MaxPlayers    int
+
+	// SRV is the SRV record server was reached through, or nil if it answered at the address
+	// ping was called with.
+	SRV *SRVRecord
 }
 
 // String returns a user-friendly representation of a server status response.
@@ -30,6 +26,9 @@ func (s *StatusBeta18) String() string {
 		s.OnlinePlayers, s.MaxPlayers, naturalizeMOTD(s.MOTD))
 }
 
+// MOTDSegments parses MOTD legacy §-formatting into styled text segments, see ParseLegacyMOTD.
+func (s *StatusBeta18) MOTDSegments() []ChatSegment { return ParseLegacyMOTD(s.MOTD) }
+
 // PingBeta18 pings Beta 1.8 to Release 1.4 (exclusively) Minecraft servers (Notchian servers of more late versions
 // also respond to this ping packet.)
 //
@@ -38,18 +37,32 @@ func PingBeta18(host string, port int) (*StatusBeta18, error) {
 	return defaultPinger.PingBeta18(host, port)
 }
 
+// PingBeta18Context pings the same servers PingBeta18 does, using the provided context for
+// cancellation and deadlines of SRV lookup, connection establishment and packet exchange.
+//
+//goland:noinspection GoUnusedExportedFunction
+func PingBeta18Context(ctx context.Context, host string, port int) (*StatusBeta18, error) {
+	return defaultPinger.PingBeta18Context(ctx, host, port)
+}
+
 // PingBeta18 pings Beta 1.8 to Release 1.4 (exclusively) Minecraft servers (Notchian servers of more late versions
 // also respond to this ping packet.)
 func (p *Pinger) PingBeta18(host string, port int) (*StatusBeta18, error) {
-	status, err := p.pingGeneric(p.pingBeta18, host, port)
+	return p.PingBeta18Context(context.Background(), host, port)
+}
+
+// PingBeta18Context pings the same servers PingBeta18 does, using the provided context for
+// cancellation and deadlines of SRV lookup, connection establishment and packet exchange.
+func (p *Pinger) PingBeta18Context(ctx context.Context, host string, port int) (*StatusBeta18, error) {
+	status, _, err := p.pingGeneric(ctx, p.pingBeta18, host, port)
 	if err != nil {
 		return nil, err
 	}
 	return status.(*StatusBeta18), nil
 }
 
-func (p *Pinger) pingBeta18(host string, port int) (interface{}, error) {
-	conn, err := p.openTCPConn(host, port)
+func (p *Pinger) pingBeta18(ctx context.Context, host string, port int) (interface{}, error) {
+	conn, _, err := p.openTCPConn(ctx, host, port)
 	if err != nil {
 		return nil, err
 	}
@@ -61,7 +74,7 @@ func (p *Pinger) pingBeta18(host string, port int) (interface{}, error) {
 	}
 
 	// Read status response (note: uses the same packet reading approach as 1.4)
-	payload, err := p.pingBeta18ReadResponsePacket(conn)
+	payload, err := protocol.ReadKick(conn, p.limits().MaxPacketSize)
 	if err != nil {
 		return nil, fmt.Errorf("could not read response packet: %w", err)
 	}
@@ -79,78 +92,22 @@ func (p *Pinger) pingBeta18(host string, port int) (interface{}, error) {
 
 func (p *Pinger) pingBeta18WritePingPacket(writer io.Writer) error {
 	// Write single-byte FE ping packet
-	_, err := writer.Write(pingBeta18PingPacket)
+	_, err := writer.Write(protocol.EncodeBeta18Ping())
 	return err
 }
 
-func (p *Pinger) pingBeta18ReadResponsePacket(reader io.Reader) ([]byte, error) {
-	// Read first three bytes (packet ID as byte + packet length as short)
-	// and create a reader over this buffer for sequential reading.
-	b := make([]byte, 3)
-	bn, err := reader.Read(b)
-	if err != nil {
-		return nil, err
-	} else if bn < 3 {
-		return nil, io.EOF
-	}
-	br := bytes.NewReader(b)
-
-	// Read packet type, return error if it isn't FF kick packet
-	id, err := br.ReadByte()
-	if err != nil {
-		return nil, err
-	} else if id != pingBeta18ResponsePacketID {
-		return nil, fmt.Errorf("expected packet ID %#x, but instead got %#x", ping16ResponsePacketID, id)
-	}
-
-	// Read packet length, return error if it isn't readable as unsigned short
-	// Worth noting that this needs to be multiplied by two further on (for encoding reasons, most probably)
-	var length uint16
-	if err = binary.Read(br, binary.BigEndian, &length); err != nil {
-		return nil, err
-	}
-
-	// Read remainder of the status packet as raw bytes
-	// This is a UTF-16BE string separated by § (paragraph sign)
-	payload := bytes.NewBuffer(make([]byte, 0, length*2))
-	if _, err = io.CopyN(payload, reader, int64(length*2)); err != nil {
-		return nil, err
-	}
-
-	// Decode UTF-16BE string
-	decoded, err := utf16BEDecoder.Bytes(payload.Bytes())
-	if err != nil {
-		return nil, err
-	}
-
-	return decoded, nil
-}
-
 // Response processing
 
-func (p *Pinger) pingBeta18ParseResponsePayload(payload []byte) (*StatusBeta18, error) {
+func (p *Pinger) pingBeta18ParseResponsePayload(payload string) (*StatusBeta18, error) {
 	// Split status string, parse and map to struct returning errors if conversions fail
-	fields := strings.Split(string(payload), pingBeta18ResponseFieldSeparator)
-	if len(fields) != 3 {
-		return nil, fmt.Errorf("%w: expected 3 status fields, got %d", ErrInvalidStatus, len(fields))
-	}
-	motd, onlineString, maxString := fields[0], fields[1], fields[2]
-
-	// Parse online players
-	online, err := strconv.ParseInt(onlineString, 10, 32)
-	if err != nil {
-		return nil, fmt.Errorf("%w: could not parse online players count: %s", ErrInvalidStatus, err)
-	}
-
-	// Parse max players
-	max, err := strconv.ParseInt(maxString, 10, 32)
+	res, err := protocol.DecodeLegacyResponse(payload)
 	if err != nil {
-		return nil, fmt.Errorf("%w: could not parse max players count: %s", ErrInvalidStatus, err)
+		return nil, invalidStatus(err)
 	}
 
 	return &StatusBeta18{
-		MOTD:          motd,
-		OnlinePlayers: int(online),
-		MaxPlayers:    int(max),
+		MOTD:          res.MOTD,
+		OnlinePlayers: res.OnlinePlayers,
+		MaxPlayers:    res.MaxPlayers,
 	}, nil
 }
@@ -1,14 +1,36 @@
 package minequery
 
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
 // defaultMinecraftPort is a default port Minecraft server runs on and which
 // will be used when server port is left as zero value.
 const defaultMinecraftPort = 25565
 
+// pingFunc is a version-specific ping function signature accepted by pingGeneric.
+type pingFunc func(ctx context.Context, host string, port int) (interface{}, error)
+
+// pingAddress is the address server answered pingGeneric ping at.
+type pingAddress struct {
+	Host string
+	Port int
+
+	// SRV is the SRV record address was taken from, or nil if server answered at the provided address.
+	SRV *SRVRecord
+}
+
 // pingGeneric accepts version-specific ping function and host/port pair. Then it performs
-// (if necessary, see PreferSRVRecord) SRV lookup, and attempts to use the SRV record hostname and port
-// (first returned record is used if more than one is returned, see net.LookupSRV documentation)
-// to ping, if lookup fails or ping fails, the provided hostname/port pair is used directly.
-func (p *Pinger) pingGeneric(pingFn func(string, int) (interface{}, error), host string, port int) (interface{}, error) {
+// (if necessary, see PreferSRVRecord) SRV lookup, and attempts to ping every SRV record target
+// in the order defined by RFC 2782 (see resolveSRV) until one of them responds. If lookup fails,
+// there are no records or all targets fail, the provided hostname/port pair is used directly
+// (unless UseStrict is set, in which case SRV errors are returned.)
+//
+// If ctx is cancelled or its deadline is exceeded, the context error is returned as is and
+// no further attempts are made.
+func (p *Pinger) pingGeneric(ctx context.Context, pingFn pingFunc, host string, port int) (interface{}, pingAddress, error) {
 	// Use default Minecraft port if port is 0
 	if port == 0 {
 		port = defaultMinecraftPort
@@ -16,35 +38,64 @@ func (p *Pinger) pingGeneric(pingFn func(string, int) (interface{}, error), host
 
 	if p.PreferSRVRecord {
 		// When SRV record is preferred, try resolving it
-		srvHost, srvPort, err := p.resolveSRV(host)
+		records, err := p.resolveSRV(ctx, host)
 		if err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				// Context is done, there's no point in trying further
+				return nil, pingAddress{}, ctxErr
+			}
 			if p.UseStrict {
 				// If UseStrict, SRV lookup error is fatal
-				return nil, err
+				return nil, pingAddress{}, err
 			}
 
 			// If not UseStrict, continue pinging on the desired host/port
+		}
 
-		} else {
-			// If SRV lookup is successful, check if there are any records
-			if srvHost != "" {
-				status, err := pingFn(srvHost, int(srvPort))
-				if err != nil {
-					// If pinging on the SRV record failed and UseStrict is set,
-					// this is fatal enough to raise an error
-					if p.UseStrict {
-						return nil, err
-					}
-
-				} else {
-					// Success, SRV record ping passed
-					return status, nil
-				}
+		// If SRV lookup is successful, try record targets one by one
+		var errs []string
+		for i := range records {
+			record := &records[i]
+			status, err := pingFn(ctx, record.Target, record.Port)
+			if err == nil {
+				// Success, SRV record ping passed
+				setStatusSRV(status, record)
+				return status, pingAddress{Host: record.Target, Port: record.Port, SRV: record}, nil
 			}
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return nil, pingAddress{}, ctxErr
+			}
+			errs = append(errs, fmt.Sprintf("%s: %s", toAddrString(record.Target, record.Port), err))
+		}
 
+		// If pinging on all SRV record targets failed and UseStrict is set,
+		// this is fatal enough to raise an error
+		if len(errs) > 0 && p.UseStrict {
+			return nil, pingAddress{}, fmt.Errorf("%w: %s", ErrSRVTargetsFailed, strings.Join(errs, "; "))
 		}
 	}
 
 	// Otherwise just ping normally
-	return pingFn(host, port)
+	status, err := pingFn(ctx, host, port)
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, pingAddress{}, ctxErr
+		}
+		return nil, pingAddress{}, err
+	}
+	return status, pingAddress{Host: host, Port: port}, nil
+}
+
+// setStatusSRV sets SRV record protocol-specific status was received through.
+func setStatusSRV(status interface{}, record *SRVRecord) {
+	switch status := status.(type) {
+	case *Status17:
+		status.SRV = record
+	case *Status16:
+		status.SRV = record
+	case *Status14:
+		status.SRV = record
+	case *StatusBeta18:
+		status.SRV = record
+	}
 }
@@ -0,0 +1,153 @@
+package minequery_test
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/dreamscached/minequery/v2"
+	"github.com/dreamscached/minequery/v2/minequerytest"
+)
+
+func TestPingNegotiation(t *testing.T) {
+	cases := []struct {
+		name     string
+		start    func() (*minequerytest.Server, error)
+		expected minequery.PingProtocol
+	}{
+		{"1.7+", func() (*minequerytest.Server, error) {
+			return minequerytest.NewPing17Server(&minequery.Status17{VersionName: "1.20.1", ProtocolVersion: 763}, nil)
+		}, minequery.PingProtocol17},
+		{"1.6", func() (*minequerytest.Server, error) {
+			return minequerytest.NewPing16Server(&minequery.Status16{ProtocolVersion: 78, ServerVersion: "1.6.4"}, nil)
+		}, minequery.PingProtocol16},
+		{"1.4", func() (*minequerytest.Server, error) {
+			return minequerytest.NewPing14Server(&minequery.Status14{MOTD: "A Minecraft Server"}, nil)
+		}, minequery.PingProtocol14},
+		{"Beta 1.8", func() (*minequerytest.Server, error) {
+			return minequerytest.NewPingBeta18Server(&minequery.StatusBeta18{MOTD: "A Minecraft Server"}, nil)
+		}, minequery.PingProtocolBeta18},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			server, err := c.start()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer func() { _ = server.Close() }()
+
+			pinger := minequery.NewPinger(minequery.WithTimeout(time.Second))
+			for i := 0; i < 2; i++ {
+				// Second ping checks protocol remembered in protocol cache
+				res, err := pinger.Ping(server.Host, server.Port)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if res.Protocol != c.expected {
+					t.Errorf("expected protocol %s, got %s", c.expected, res.Protocol)
+				}
+			}
+		})
+	}
+}
+
+// srvResolver is a Resolver with a single SRV record of play.example.test pointing at port of
+// mc.example.test, which resolves to loopback address.
+type srvResolver struct{ port int }
+
+func (r srvResolver) LookupSRV(_ context.Context, service, proto, name string) (string, []*net.SRV, error) {
+	if service != "minecraft" || proto != "tcp" || name != "play.example.test" {
+		return "", nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+	}
+	return "_minecraft._tcp.play.example.test.", []*net.SRV{{Target: "mc.example.test.", Port: uint16(r.port)}}, nil
+}
+
+func (r srvResolver) LookupIPAddr(_ context.Context, host string) ([]net.IPAddr, error) {
+	if host != "mc.example.test" {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	return []net.IPAddr{{IP: net.IPv4(127, 0, 0, 1)}}, nil
+}
+
+func TestPingSRVRecord(t *testing.T) {
+	cases := []struct {
+		name  string
+		start func() (*minequerytest.Server, error)
+		ping  func(pinger *minequery.Pinger) (*minequery.SRVRecord, error)
+	}{
+		{"1.7+", func() (*minequerytest.Server, error) {
+			return minequerytest.NewPing17Server(&minequery.Status17{VersionName: "1.20.1", ProtocolVersion: 763}, nil)
+		}, func(pinger *minequery.Pinger) (*minequery.SRVRecord, error) {
+			res, err := pinger.Ping17("play.example.test", 0)
+			if err != nil {
+				return nil, err
+			}
+			return res.SRV, nil
+		}},
+		{"1.6", func() (*minequerytest.Server, error) {
+			return minequerytest.NewPing16Server(&minequery.Status16{ProtocolVersion: 78, ServerVersion: "1.6.4"}, nil)
+		}, func(pinger *minequery.Pinger) (*minequery.SRVRecord, error) {
+			res, err := pinger.Ping16("play.example.test", 0)
+			if err != nil {
+				return nil, err
+			}
+			return res.SRV, nil
+		}},
+		{"1.4", func() (*minequerytest.Server, error) {
+			return minequerytest.NewPing14Server(&minequery.Status14{MOTD: "A Minecraft Server"}, nil)
+		}, func(pinger *minequery.Pinger) (*minequery.SRVRecord, error) {
+			res, err := pinger.Ping14("play.example.test", 0)
+			if err != nil {
+				return nil, err
+			}
+			return res.SRV, nil
+		}},
+		{"Beta 1.8", func() (*minequerytest.Server, error) {
+			return minequerytest.NewPingBeta18Server(&minequery.StatusBeta18{MOTD: "A Minecraft Server"}, nil)
+		}, func(pinger *minequery.Pinger) (*minequery.SRVRecord, error) {
+			res, err := pinger.PingBeta18("play.example.test", 0)
+			if err != nil {
+				return nil, err
+			}
+			return res.SRV, nil
+		}},
+		{"Ping", func() (*minequerytest.Server, error) {
+			return minequerytest.NewPing17Server(&minequery.Status17{VersionName: "1.20.1", ProtocolVersion: 763}, nil)
+		}, func(pinger *minequery.Pinger) (*minequery.SRVRecord, error) {
+			res, err := pinger.Ping("play.example.test", 0)
+			if err != nil {
+				return nil, err
+			}
+			if res.Status17.SRV != res.SRV {
+				t.Errorf("expected SRV record of Status17 to be the one of Status")
+			}
+			return res.SRV, nil
+		}},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			server, err := c.start()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer func() { _ = server.Close() }()
+
+			pinger := minequery.NewPinger(
+				minequery.WithResolver(srvResolver{port: server.Port}),
+				minequery.WithTimeout(time.Second),
+			)
+			record, err := c.ping(pinger)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if record == nil || record.Target != "mc.example.test" || record.Port != server.Port {
+				t.Errorf("expected SRV record mc.example.test:%d, got %+v", server.Port, record)
+			}
+		})
+	}
+}
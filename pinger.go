@@ -1,6 +1,7 @@
 package minequery
 
 import (
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"image/png"
@@ -10,6 +11,12 @@ import (
 	"github.com/patrickmn/go-cache"
 )
 
+// ContextDialer establishes connections with servers. *net.Dialer, SOCKS5Dialer and dialers of
+// golang.org/x/net/proxy package satisfy this interface.
+type ContextDialer interface {
+	DialContext(ctx context.Context, network, address string) (net.Conn, error)
+}
+
 // PingerOption is a configuring function that applies certain changes to Pinger.
 type PingerOption func(*Pinger)
 
@@ -22,6 +29,72 @@ func WithDialer(dialer *net.Dialer) PingerOption {
 	}
 }
 
+// WithContextDialer sets Pinger ContextDialer used instead of Dialer for TCP connections (Ping* functions.)
+//
+//goland:noinspection GoUnusedExportedFunction
+func WithContextDialer(dialer ContextDialer) PingerOption {
+	return func(p *Pinger) {
+		p.ContextDialer = dialer
+	}
+}
+
+// WithUDPDialer sets Pinger UDPDialer used for UDP connections (Query* and PingBedrock functions.)
+//
+//goland:noinspection GoUnusedExportedFunction
+func WithUDPDialer(dialer ContextDialer) PingerOption {
+	return func(p *Pinger) {
+		p.UDPDialer = dialer
+	}
+}
+
+// WithSOCKS5Proxy sets Pinger ContextDialer and UDPDialer to SOCKS5Dialer connecting through proxy
+// at address, so that both pings and queries go through proxy.
+//
+//goland:noinspection GoUnusedExportedFunction
+func WithSOCKS5Proxy(address, username, password string) PingerOption {
+	return func(p *Pinger) {
+		dialer := NewSOCKS5Dialer(address, username, password)
+		p.ContextDialer, p.UDPDialer = dialer, dialer
+	}
+}
+
+// WithProxyHeader sets Pinger ProxyHeader sent on TCP connections before any packets.
+//
+//goland:noinspection GoUnusedExportedFunction
+func WithProxyHeader(header *ProxyHeader) PingerOption {
+	return func(p *Pinger) {
+		p.ProxyHeader = header
+	}
+}
+
+// WithQueryEngine sets Pinger QueryEngine that Query* functions send queries through.
+//
+//goland:noinspection GoUnusedExportedFunction
+func WithQueryEngine(engine *QueryEngine) PingerOption {
+	return func(p *Pinger) {
+		p.QueryEngine = engine
+	}
+}
+
+// WithResolver sets Pinger Resolver used for SRV and A/AAAA lookups of server hostnames.
+// Use NewCachingResolver to cache lookups in process memory.
+//
+//goland:noinspection GoUnusedExportedFunction
+func WithResolver(resolver Resolver) PingerOption {
+	return func(p *Pinger) {
+		p.Resolver = resolver
+	}
+}
+
+// WithLimits sets limits of data accepted from servers; zero fields of limits are replaced with defaults.
+//
+//goland:noinspection GoUnusedExportedFunction
+func WithLimits(limits Limits) PingerOption {
+	return func(p *Pinger) {
+		p.Limits = limits
+	}
+}
+
 // WithTimeout sets Pinger Dialer timeout to the provided value.
 //
 //goland:noinspection GoUnusedExportedFunction
@@ -48,6 +121,15 @@ func WithPreferSRVRecord(preferSRV bool) PingerOption {
 	}
 }
 
+// WithMeasureLatency sets Pinger MeasureLatency to the provided value.
+//
+//goland:noinspection GoUnusedExportedFunction
+func WithMeasureLatency(measureLatency bool) PingerOption {
+	return func(p *Pinger) {
+		p.MeasureLatency = measureLatency
+	}
+}
+
 // WithProtocolVersion16 sets Pinger ProtocolVersion16 value.
 //
 //goland:noinspection GoUnusedExportedFunction
@@ -95,6 +177,35 @@ func WithQueryCacheDisabled() PingerOption {
 	}
 }
 
+// WithProtocolCacheExpiry sets Pinger protocol cache expiry and purge duration values.
+// This function uses go-cache library; consider using WithProtocolCache for
+// custom implementations that implement Cache interface.
+//
+//goland:noinspection GoUnusedExportedFunction
+func WithProtocolCacheExpiry(expire, purge time.Duration) PingerOption {
+	return func(p *Pinger) {
+		p.ProtocolCache = cache.New(expire, purge)
+	}
+}
+
+// WithProtocolCache sets Pinger cache instance that will be used for remembering protocols detected by Ping.
+//
+//goland:noinspection GoUnusedExportedFunction
+func WithProtocolCache(cache Cache) PingerOption {
+	return func(p *Pinger) {
+		p.ProtocolCache = cache
+	}
+}
+
+// WithProtocolCacheDisabled disables Pinger cache used for remembering protocols detected by Ping.
+//
+//goland:noinspection GoUnusedExportedFunction
+func WithProtocolCacheDisabled() PingerOption {
+	return func(p *Pinger) {
+		p.ProtocolCache = nil
+	}
+}
+
 // WithUnmarshaller sets JSON unmarshalling function used for unmarshalling 1.7+ responses.
 //
 //goland:noinspection GoUnusedExportedFunction
@@ -131,12 +242,39 @@ type Pinger struct {
 	// Dialer used to establish and maintain connection with servers.
 	Dialer *net.Dialer
 
+	// ContextDialer, if set, is used instead of Dialer to establish TCP connections with servers.
+	// It allows using proxy dialers (such as SOCKS5Dialer), tunnels and custom transports.
+	ContextDialer ContextDialer
+
+	// UDPDialer, if set, is used to establish UDP connections with servers (for Query* and PingBedrock
+	// functions.) Query sessions are not reused when it is set, since they are bound to client address.
+	UDPDialer ContextDialer
+
+	// ProxyHeader, if set, is the HAProxy PROXY protocol header sent on TCP connections before any packets.
+	ProxyHeader *ProxyHeader
+
+	// Resolver is used to look up SRV records and addresses of server hostnames.
+	// By default (if nil), SRV records are looked up with net.DefaultResolver and hostnames
+	// are resolved by Dialer.
+	Resolver Resolver
+
 	// Timeout is used to set TCP/UDP connection timeout on call of Ping* and Query* functions.
 	Timeout time.Duration
 
+	// Limits are limits of data accepted from servers; LimitError is returned when server exceeds them.
+	// Zero fields are replaced with defaults (see DefaultLimits).
+	Limits Limits
+
 	// SessionCache holds query protocol sessions in order to reuse them instead of creating new each time.
 	SessionCache Cache
 
+	// QueryEngine, if set, is used by Query* functions to send queries through its shared sockets
+	// instead of opening a new socket per query; SessionCache and UDPDialer are not used in this case.
+	QueryEngine *QueryEngine
+
+	// ProtocolCache holds ping protocols detected by Ping in order to try them first on subsequent calls.
+	ProtocolCache Cache
+
 	// UseStrict is a configuration value that defines if tolerable errors (in server ping/query responses)
 	// that are by default silently ignored should be actually returned as errors.
 	UseStrict bool
@@ -145,6 +283,10 @@ type Pinger struct {
 	// default behavior of Minecraft clients.
 	PreferSRVRecord bool
 
+	// MeasureLatency is a configuration value that defines if Ping17 will send ping packet after receiving
+	// status response and measure round-trip latency of ping/pong exchange (see Status17 Latency).
+	MeasureLatency bool
+
 	// UnmarshalFunc is the function used to unmarshal JSON (used by Ping17 for responses from 1.7+ servers).
 	// By default, it uses json.Unmarshal function.
 	UnmarshalFunc UnmarshalFunc
@@ -165,6 +307,9 @@ type Pinger struct {
 	// By default, Ping17ProtocolVersionUndefined (=-1) will be used.
 	// See ping_17.go for full list of built-in constants.
 	ProtocolVersion17 int32
+
+	// sessions coordinates use of query sessions by concurrent Query* calls.
+	sessions querySessions
 }
 
 func newDefaultPinger() *Pinger {
@@ -174,6 +319,7 @@ func newDefaultPinger() *Pinger {
 	// Apply default configuration
 	WithDialer(&net.Dialer{})(p)
 	WithQueryCacheExpiry(30*time.Second, 5*time.Minute)(p)
+	WithProtocolCacheExpiry(10*time.Minute, 15*time.Minute)(p)
 	WithTimeout(15 * time.Second)(p)
 	WithPreferSRVRecord(true)(p)
 	WithUnmarshaller(json.Unmarshal)(p)
@@ -183,6 +329,11 @@ func newDefaultPinger() *Pinger {
 	return p
 }
 
+// limits returns Pinger Limits with zero fields replaced with defaults.
+func (p *Pinger) limits() Limits {
+	return p.Limits.withDefaults()
+}
+
 // NewPinger constructs new Pinger instance optionally with additional options.
 func NewPinger(options ...PingerOption) *Pinger {
 	pinger := newDefaultPinger()
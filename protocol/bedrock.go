@@ -0,0 +1,144 @@
+package protocol
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// Packet IDs of Bedrock Edition (RakNet) unconnected ping protocol.
+const (
+	BedrockUnconnectedPingPacketID byte = 0x01
+	BedrockUnconnectedPongPacketID byte = 0x1c
+)
+
+// bedrockMagic is the RakNet offline message magic.
+var bedrockMagic = []byte{0x00, 0xff, 0xff, 0x00, 0xfe, 0xfe, 0xfe, 0xfe, 0xfd, 0xfd, 0xfd, 0xfd, 0x12, 0x34, 0x56, 0x78}
+
+// BedrockUnconnectedPing is unconnected ping packet Bedrock Edition clients send to discover servers.
+type BedrockUnconnectedPing struct {
+	// Timestamp is client time (in milliseconds); server echoes it back in pong.
+	Timestamp int64
+
+	// ClientGUID identifies client.
+	ClientGUID int64
+}
+
+// EncodeBedrockUnconnectedPing encodes unconnected ping packet.
+func EncodeBedrockUnconnectedPing(ping *BedrockUnconnectedPing) []byte {
+	packet := bytes.NewBuffer(make([]byte, 0, 1+8+len(bedrockMagic)+8))
+
+	// Write packet ID, client timestamp, offline message magic and client GUID
+	packet.WriteByte(BedrockUnconnectedPingPacketID)
+	_ = binary.Write(packet, binary.BigEndian, ping.Timestamp)
+	packet.Write(bedrockMagic)
+	_ = binary.Write(packet, binary.BigEndian, ping.ClientGUID)
+
+	return packet.Bytes()
+}
+
+// DecodeBedrockUnconnectedPing decodes unconnected ping packet. Packet with invalid offline
+// message magic is only rejected if strict is set.
+func DecodeBedrockUnconnectedPing(packet []byte, strict bool) (*BedrockUnconnectedPing, error) {
+	reader, err := readBedrockHeader(packet, BedrockUnconnectedPingPacketID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Read client timestamp, offline message magic and client GUID
+	ping := &BedrockUnconnectedPing{}
+	if err = binary.Read(reader, binary.BigEndian, &ping.Timestamp); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	if err = readBedrockMagic(reader, strict); err != nil {
+		return nil, err
+	}
+	if err = binary.Read(reader, binary.BigEndian, &ping.ClientGUID); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+
+	return ping, nil
+}
+
+// BedrockUnconnectedPong is unconnected pong packet Bedrock Edition servers respond to pings with.
+type BedrockUnconnectedPong struct {
+	// Timestamp is client time echoed back from ping.
+	Timestamp int64
+
+	// ServerGUID identifies server.
+	ServerGUID int64
+
+	// Payload is the semicolon-separated server status string.
+	Payload string
+}
+
+// EncodeBedrockUnconnectedPong encodes unconnected pong packet.
+func EncodeBedrockUnconnectedPong(pong *BedrockUnconnectedPong) []byte {
+	packet := bytes.NewBuffer(make([]byte, 0, 1+8+8+len(bedrockMagic)+2+len(pong.Payload)))
+
+	// Write packet ID, echoed timestamp, server GUID and offline message magic
+	packet.WriteByte(BedrockUnconnectedPongPacketID)
+	_ = binary.Write(packet, binary.BigEndian, pong.Timestamp)
+	_ = binary.Write(packet, binary.BigEndian, pong.ServerGUID)
+	packet.Write(bedrockMagic)
+
+	// Write payload string prefixed with its length as unsigned short
+	_ = binary.Write(packet, binary.BigEndian, uint16(len(pong.Payload)))
+	packet.WriteString(pong.Payload)
+
+	return packet.Bytes()
+}
+
+// DecodeBedrockUnconnectedPong decodes unconnected pong packet. Packet with invalid offline
+// message magic is only rejected if strict is set.
+func DecodeBedrockUnconnectedPong(packet []byte, strict bool) (*BedrockUnconnectedPong, error) {
+	reader, err := readBedrockHeader(packet, BedrockUnconnectedPongPacketID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Read echoed timestamp, server GUID and offline message magic
+	pong := &BedrockUnconnectedPong{}
+	if err = binary.Read(reader, binary.BigEndian, &pong.Timestamp); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	if err = binary.Read(reader, binary.BigEndian, &pong.ServerGUID); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	if err = readBedrockMagic(reader, strict); err != nil {
+		return nil, err
+	}
+
+	// Read payload string length as unsigned short and the string itself
+	var length uint16
+	if err = binary.Read(reader, binary.BigEndian, &length); err != nil {
+		return nil, unexpectedEOF(err)
+	} else if int(length) > reader.Len() {
+		return nil, io.ErrUnexpectedEOF
+	}
+	payload := make([]byte, length)
+	_, _ = reader.Read(payload)
+	pong.Payload = string(payload)
+
+	return pong, nil
+}
+
+func readBedrockHeader(packet []byte, packetID byte) (*bytes.Reader, error) {
+	if len(packet) == 0 {
+		return nil, io.ErrUnexpectedEOF
+	} else if packet[0] != packetID {
+		return nil, unexpectedPacket(int64(packetID), int64(packet[0]))
+	}
+	return bytes.NewReader(packet[1:]), nil
+}
+
+func readBedrockMagic(reader *bytes.Reader, strict bool) error {
+	magic := make([]byte, len(bedrockMagic))
+	if _, err := io.ReadFull(reader, magic); err != nil {
+		return unexpectedEOF(err)
+	} else if !bytes.Equal(magic, bedrockMagic) && strict {
+		return fmt.Errorf("%w: offline message magic is invalid", ErrMalformedPacket)
+	}
+	return nil
+}
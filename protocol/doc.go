@@ -0,0 +1,8 @@
+// Package protocol provides encoders and decoders of Minecraft server list ping and query protocol packets:
+// Netty-framed (1.7+) packets, pre-Netty (1.6, 1.4 and Beta 1.8) ping packets, GS4 Query packets and
+// Bedrock Edition unconnected ping packets.
+//
+// These are the wire primitives minequery Pinger and responders are built on; they can be used to build
+// proxies, load testers, packet sniffers and other tools speaking the same protocols. Decoders never
+// allocate more than the data they are given (or limits they are provided with) can hold.
+package protocol
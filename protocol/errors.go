@@ -0,0 +1,68 @@
+package protocol
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+var (
+	// ErrLimitExceeded is returned (wrapped in LimitError) when data exceeds the limit it is read with.
+	ErrLimitExceeded = errors.New("limit exceeded")
+
+	// ErrMalformedPacket is returned (wrapped) when packet framing or contents are invalid.
+	ErrMalformedPacket = errors.New("malformed packet")
+
+	// ErrUnexpectedPacket is returned (wrapped) when packet has a different ID or type than expected.
+	ErrUnexpectedPacket = errors.New("unexpected packet")
+
+	// ErrVarIntTooLong is returned when VarInt is longer than MaxVarIntLength bytes.
+	ErrVarIntTooLong = errors.New("VarInt is too long")
+
+	// ErrVarLongTooLong is returned when VarLong is longer than MaxVarLongLength bytes.
+	ErrVarLongTooLong = errors.New("VarLong is too long")
+)
+
+// LimitError is returned when data exceeds the limit it is read with.
+type LimitError struct {
+	// Limit is the name of exceeded limit (minequery Limits or PacketReader field.)
+	Limit string
+
+	// Size is the size data has or announces it is going to have. For datagrams, which are
+	// not read beyond limit, it is the lower bound of actual size.
+	Size int64
+
+	// Max is the value of exceeded limit.
+	Max int
+}
+
+// Error returns a human-readable description of exceeded limit.
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("%s: %s is %d, but at most %d is allowed", ErrLimitExceeded, e.Limit, e.Size, e.Max)
+}
+
+// Is reports whether target is ErrLimitExceeded, so that errors.Is(err, ErrLimitExceeded) matches LimitError.
+func (e *LimitError) Is(target error) bool {
+	return target == ErrLimitExceeded
+}
+
+// CheckLimit returns LimitError of limit if size exceeds max.
+func CheckLimit(limit string, size int64, max int) error {
+	if size > int64(max) {
+		return &LimitError{Limit: limit, Size: size, Max: max}
+	}
+	return nil
+}
+
+// unexpectedPacket returns ErrUnexpectedPacket error of packet ID (or type) mismatch.
+func unexpectedPacket(expected, actual int64) error {
+	return fmt.Errorf("%w: expected packet ID %#x, but instead got %#x", ErrUnexpectedPacket, expected, actual)
+}
+
+// unexpectedEOF converts io.EOF to io.ErrUnexpectedEOF; it is used when data ended in the middle.
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,284 @@
+package protocol
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"golang.org/x/text/encoding/unicode"
+)
+
+// Packet IDs and constants of pre-Netty (1.6, 1.4 and Beta 1.8) ping protocols.
+const (
+	// LegacyPingPacketID is the ID of ping packet; Beta 1.8 ping is this single byte.
+	LegacyPingPacketID byte = 0xfe
+
+	// LegacyPingPayload follows ping packet ID in 1.4+ pings.
+	LegacyPingPayload byte = 0x01
+
+	// LegacyPluginMessagePacketID is the ID of plugin message packet following 1.6 ping.
+	LegacyPluginMessagePacketID byte = 0xfa
+
+	// LegacyKickPacketID is the ID of kick packet servers respond to pings with.
+	LegacyKickPacketID byte = 0xff
+
+	// PingHostChannel is the plugin message channel of 1.6 ping.
+	PingHostChannel = "MC|PingHost"
+
+	// Ping16ResponsePrefix is the prefix of 1.6 (and 1.4, on newer servers) response payload.
+	Ping16ResponsePrefix = "§1\x00"
+
+	// Ping16ResponseFieldSeparator separates fields of 1.6 response payload.
+	Ping16ResponseFieldSeparator = "\x00"
+
+	// LegacyResponseFieldSeparator separates fields of 1.4 and Beta 1.8 response payload.
+	LegacyResponseFieldSeparator = "§"
+)
+
+// utf16BE is the encoding of legacy strings. Its encoders and decoders are stateful, so a new one
+// is created for every string rather than shared between goroutines.
+var utf16BE = unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM)
+
+// EncodeBeta18Ping encodes Beta 1.8 ping packet (FE.)
+func EncodeBeta18Ping() []byte {
+	return []byte{LegacyPingPacketID}
+}
+
+// Encode14Ping encodes 1.4 ping packet (FE 01.)
+func Encode14Ping() []byte {
+	return []byte{LegacyPingPacketID, LegacyPingPayload}
+}
+
+// PingHost is MC|PingHost plugin message 1.6 clients send following FE 01 ping.
+type PingHost struct {
+	// ProtocolVersion is client protocol version.
+	ProtocolVersion byte
+
+	// Host and Port are the address client connected to.
+	Host string
+	Port int32
+}
+
+// Encode16Ping encodes 1.6 ping packet (FE 01) followed by MC|PingHost plugin message.
+func Encode16Ping(ping *PingHost) []byte {
+	// Encode data of plugin message to calculate its length
+	data := []byte{ping.ProtocolVersion}
+	data = AppendLegacyString(data, ping.Host)
+	data = append(data, byte(ping.Port>>24), byte(ping.Port>>16), byte(ping.Port>>8), byte(ping.Port))
+
+	packet := bytes.NewBuffer(make([]byte, 0, 32+len(data)))
+
+	// Write ping packet and plugin message packet ID followed by channel name
+	packet.Write(Encode14Ping())
+	packet.WriteByte(LegacyPluginMessagePacketID)
+	packet.Write(AppendLegacyString(nil, PingHostChannel))
+
+	// Write data length as unsigned short and the data itself
+	_ = binary.Write(packet, binary.BigEndian, uint16(len(data)))
+	packet.Write(data)
+
+	return packet.Bytes()
+}
+
+// ReadPingHost reads MC|PingHost plugin message (starting with its packet ID) following 1.6 ping packet.
+func ReadPingHost(reader io.Reader) (*PingHost, error) {
+	// Read plugin message packet ID
+	id := make([]byte, 1)
+	if _, err := io.ReadFull(reader, id); err != nil {
+		return nil, err
+	} else if id[0] != LegacyPluginMessagePacketID {
+		return nil, unexpectedPacket(int64(LegacyPluginMessagePacketID), int64(id[0]))
+	}
+
+	// Read channel name
+	channel, err := ReadLegacyString(reader)
+	if err != nil {
+		return nil, unexpectedEOF(err)
+	} else if channel != PingHostChannel {
+		return nil, fmt.Errorf("%w: expected channel %#v, but instead got %#v", ErrMalformedPacket, PingHostChannel, channel)
+	}
+
+	// Read data length as unsigned short (unused, since fields are read one by one)
+	var length uint16
+	if err = binary.Read(reader, binary.BigEndian, &length); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+
+	// Read protocol version as byte, hostname and port as integer
+	ping := &PingHost{}
+	if err = binary.Read(reader, binary.BigEndian, &ping.ProtocolVersion); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	if ping.Host, err = ReadLegacyString(reader); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	if err = binary.Read(reader, binary.BigEndian, &ping.Port); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+
+	return ping, nil
+}
+
+// EncodeKick encodes kick packet servers respond to pings with, with payload as its reason string.
+func EncodeKick(payload string) []byte {
+	return AppendLegacyString([]byte{LegacyKickPacketID}, payload)
+}
+
+// ReadKick reads kick packet servers respond to pings with, returning its reason string.
+// It returns LimitError (of MaxPacketSize limit) if string is longer than maxSize bytes.
+func ReadKick(reader io.Reader, maxSize int) (string, error) {
+	// Read packet ID and string length (in UTF-16 characters) as unsigned short
+	header := make([]byte, 3)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		return "", err
+	} else if header[0] != LegacyKickPacketID {
+		return "", unexpectedPacket(int64(LegacyKickPacketID), int64(header[0]))
+	}
+	size := int(binary.BigEndian.Uint16(header[1:])) * 2
+
+	// Ensure string fits limit before allocating buffer for it
+	if err := CheckLimit("MaxPacketSize", int64(size), maxSize); err != nil {
+		return "", err
+	}
+	b := make([]byte, size)
+	if _, err := io.ReadFull(reader, b); err != nil {
+		return "", unexpectedEOF(err)
+	}
+	return DecodeUTF16BE(b)
+}
+
+// AppendLegacyString appends s encoded as legacy string (UTF-16BE prefixed with its length
+// in UTF-16 characters as unsigned short) to b.
+func AppendLegacyString(b []byte, s string) []byte {
+	encoded := EncodeUTF16BE(s)
+	b = append(b, byte(len(encoded)/2>>8), byte(len(encoded)/2))
+	return append(b, encoded...)
+}
+
+// ReadLegacyString reads legacy string (UTF-16BE prefixed with its length in UTF-16 characters
+// as unsigned short) from reader.
+func ReadLegacyString(reader io.Reader) (string, error) {
+	var length uint16
+	if err := binary.Read(reader, binary.BigEndian, &length); err != nil {
+		return "", err
+	}
+	b := make([]byte, int(length)*2)
+	if _, err := io.ReadFull(reader, b); err != nil {
+		return "", unexpectedEOF(err)
+	}
+	return DecodeUTF16BE(b)
+}
+
+// EncodeUTF16BE encodes s to UTF-16BE; invalid UTF-8 sequences are replaced with U+FFFD.
+func EncodeUTF16BE(s string) []byte {
+	encoded, _ := utf16BE.NewEncoder().Bytes([]byte(s))
+	return encoded
+}
+
+// DecodeUTF16BE decodes UTF-16BE string b; invalid characters are replaced with U+FFFD.
+func DecodeUTF16BE(b []byte) (string, error) {
+	decoded, err := utf16BE.NewDecoder().Bytes(b)
+	if err != nil {
+		return "", err
+	}
+	return string(decoded), nil
+}
+
+// Ping16Response is the payload of kick packet 1.6 servers (and newer, as well as some 1.4 servers)
+// respond to pings with.
+type Ping16Response struct {
+	ProtocolVersion int
+	ServerVersion   string
+	MOTD            string
+	OnlinePlayers   int
+	MaxPlayers      int
+}
+
+// EncodePing16Response encodes 1.6 response payload, including Ping16ResponsePrefix.
+func EncodePing16Response(response *Ping16Response) string {
+	return Ping16ResponsePrefix + strings.Join([]string{
+		strconv.Itoa(response.ProtocolVersion),
+		response.ServerVersion,
+		response.MOTD,
+		strconv.Itoa(response.OnlinePlayers),
+		strconv.Itoa(response.MaxPlayers),
+	}, Ping16ResponseFieldSeparator)
+}
+
+// DecodePing16Response decodes 1.6 response payload. Payload missing Ping16ResponsePrefix
+// is only rejected if strict is set.
+func DecodePing16Response(payload string, strict bool) (*Ping16Response, error) {
+	// Check if payload begins with '§1\x00' and strip it
+	if strings.HasPrefix(payload, Ping16ResponsePrefix) {
+		payload = payload[len(Ping16ResponsePrefix):]
+	} else if strict {
+		return nil, fmt.Errorf("%w: status string is missing necessary prefix", ErrMalformedPacket)
+	}
+
+	// Split status string, parse and map to struct returning errors if conversions fail
+	fields := strings.Split(payload, Ping16ResponseFieldSeparator)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("%w: expected 5 status fields, got %d", ErrMalformedPacket, len(fields))
+	}
+	protocolVersion, err := strconv.ParseInt(fields[0], 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("%w: could not parse protocol version: %s", ErrMalformedPacket, err)
+	}
+	online, max, err := decodePlayerCounts(fields[3], fields[4])
+	if err != nil {
+		return nil, err
+	}
+
+	return &Ping16Response{
+		ProtocolVersion: int(protocolVersion),
+		ServerVersion:   fields[1],
+		MOTD:            fields[2],
+		OnlinePlayers:   online,
+		MaxPlayers:      max,
+	}, nil
+}
+
+// LegacyResponse is the payload of kick packet 1.4 and Beta 1.8 servers respond to pings with.
+type LegacyResponse struct {
+	MOTD          string
+	OnlinePlayers int
+	MaxPlayers    int
+}
+
+// EncodeLegacyResponse encodes 1.4 and Beta 1.8 response payload. Note that MOTD must not contain
+// LegacyResponseFieldSeparator (§), which is also used for formatting.
+func EncodeLegacyResponse(response *LegacyResponse) string {
+	return strings.Join([]string{
+		response.MOTD,
+		strconv.Itoa(response.OnlinePlayers),
+		strconv.Itoa(response.MaxPlayers),
+	}, LegacyResponseFieldSeparator)
+}
+
+// DecodeLegacyResponse decodes 1.4 and Beta 1.8 response payload.
+func DecodeLegacyResponse(payload string) (*LegacyResponse, error) {
+	fields := strings.Split(payload, LegacyResponseFieldSeparator)
+	if len(fields) != 3 {
+		return nil, fmt.Errorf("%w: expected 3 status fields, got %d", ErrMalformedPacket, len(fields))
+	}
+	online, max, err := decodePlayerCounts(fields[1], fields[2])
+	if err != nil {
+		return nil, err
+	}
+	return &LegacyResponse{MOTD: fields[0], OnlinePlayers: online, MaxPlayers: max}, nil
+}
+
+func decodePlayerCounts(onlineString, maxString string) (int, int, error) {
+	online, err := strconv.ParseInt(onlineString, 10, 32)
+	if err != nil {
+		return 0, 0, fmt.Errorf("%w: could not parse online players count: %s", ErrMalformedPacket, err)
+	}
+	max, err := strconv.ParseInt(maxString, 10, 32)
+	if err != nil {
+		return 0, 0, fmt.Errorf("%w: could not parse max players count: %s", ErrMalformedPacket, err)
+	}
+	return int(online), int(max), nil
+}
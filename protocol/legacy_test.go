@@ -0,0 +1,48 @@
+package protocol
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+)
+
+func TestUTF16BEConcurrentUse(t *testing.T) {
+	const s = "§aA Minecraft Server §1 ★"
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				decoded, err := DecodeUTF16BE(EncodeUTF16BE(s))
+				if err != nil {
+					t.Error(err)
+					return
+				} else if decoded != s {
+					t.Errorf("expected %q, got %q", s, decoded)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestKickRoundTrip(t *testing.T) {
+	payload := EncodePing16Response(&Ping16Response{
+		ProtocolVersion: 74, ServerVersion: "1.6.2", MOTD: "héllo", OnlinePlayers: 3, MaxPlayers: 20,
+	})
+
+	decoded, err := ReadKick(bytes.NewReader(EncodeKick(payload)), DefaultMaxPacketSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := DecodePing16Response(decoded, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.MOTD != "héllo" || res.OnlinePlayers != 3 || res.MaxPlayers != 20 {
+		t.Errorf("unexpected response %+v", res)
+	}
+}
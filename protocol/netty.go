@@ -0,0 +1,179 @@
+package protocol
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// Packet IDs of Netty-based (1.7+) handshaking and status states.
+const (
+	HandshakePacketID      int32 = 0x00
+	StatusRequestPacketID  int32 = 0x00
+	StatusResponsePacketID int32 = 0x00
+	PingPacketID           int32 = 0x01
+	PongPacketID           int32 = 0x01
+)
+
+// Next states of Handshake packet.
+const (
+	NextStateStatus int32 = 1
+	NextStateLogin  int32 = 2
+)
+
+// Handshake is the first packet client sends after connecting to 1.7+ server.
+type Handshake struct {
+	// ProtocolVersion is client protocol version; it is -1 if client only wants to ping server.
+	ProtocolVersion int32
+
+	// ServerAddress and ServerPort are the address client connected to.
+	ServerAddress string
+	ServerPort    uint16
+
+	// NextState is the state client switches to, NextStateStatus or NextStateLogin.
+	NextState int32
+}
+
+// EncodeHandshake encodes handshake packet.
+func EncodeHandshake(handshake *Handshake) Packet {
+	data := make([]byte, 0, 2*MaxVarIntLength+len(handshake.ServerAddress)+2+MaxVarIntLength)
+
+	// Write protocol version as VarInt and server address as string
+	data = AppendVarInt(data, handshake.ProtocolVersion)
+	data = AppendString(data, handshake.ServerAddress)
+
+	// Write port as unsigned short and next state as VarInt
+	data = append(data, byte(handshake.ServerPort>>8), byte(handshake.ServerPort))
+	data = AppendVarInt(data, handshake.NextState)
+
+	return Packet{ID: HandshakePacketID, Data: data}
+}
+
+// DecodeHandshake decodes handshake packet.
+func DecodeHandshake(packet Packet) (*Handshake, error) {
+	if packet.ID != HandshakePacketID {
+		return nil, unexpectedPacket(int64(HandshakePacketID), int64(packet.ID))
+	}
+	reader := bytes.NewReader(packet.Data)
+
+	// Read protocol version as VarInt and server address as string
+	protocol, err := ReadVarInt(reader)
+	if err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	address, err := ReadString(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	// Read port as unsigned short and next state as VarInt
+	var port uint16
+	if err = binary.Read(reader, binary.BigEndian, &port); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	nextState, err := ReadVarInt(reader)
+	if err != nil {
+		return nil, unexpectedEOF(err)
+	}
+
+	return &Handshake{ProtocolVersion: protocol, ServerAddress: address, ServerPort: port, NextState: nextState}, nil
+}
+
+// EncodeStatusRequest encodes status request packet, which has no data.
+func EncodeStatusRequest() Packet {
+	return Packet{ID: StatusRequestPacketID}
+}
+
+// EncodeStatusResponse encodes status response packet with status JSON payload.
+func EncodeStatusResponse(payload []byte) Packet {
+	data := AppendVarInt(make([]byte, 0, MaxVarIntLength+len(payload)), int32(len(payload)))
+	return Packet{ID: StatusResponsePacketID, Data: append(data, payload...)}
+}
+
+// DecodeStatusResponse decodes status response packet, returning its status JSON payload.
+// It returns LimitError (of MaxJSONSize limit) if payload is longer than maxLength bytes.
+func DecodeStatusResponse(packet Packet, maxLength int) ([]byte, error) {
+	if packet.ID != StatusResponsePacketID {
+		return nil, unexpectedPacket(int64(StatusResponsePacketID), int64(packet.ID))
+	}
+	reader := bytes.NewReader(packet.Data)
+
+	// Read payload length as VarInt and ensure it fits limit and packet before reading payload
+	length, err := ReadVarInt(reader)
+	if err != nil {
+		return nil, unexpectedEOF(err)
+	} else if length < 0 {
+		return nil, fmt.Errorf("%w: invalid status length %d", ErrMalformedPacket, length)
+	}
+	if err = CheckLimit("MaxJSONSize", int64(length), maxLength); err != nil {
+		return nil, err
+	} else if int(length) > reader.Len() {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	payload := make([]byte, length)
+	_, _ = reader.Read(payload)
+	return payload, nil
+}
+
+// EncodePing encodes ping packet with payload server is expected to echo back in pong packet.
+func EncodePing(payload int64) Packet {
+	return Packet{ID: PingPacketID, Data: encodeLong(payload)}
+}
+
+// DecodePing decodes ping packet, returning its payload.
+func DecodePing(packet Packet) (int64, error) {
+	if packet.ID != PingPacketID {
+		return 0, unexpectedPacket(int64(PingPacketID), int64(packet.ID))
+	}
+	return decodeLong(packet.Data)
+}
+
+// EncodePong encodes pong packet echoing payload of ping packet.
+func EncodePong(payload int64) Packet {
+	return Packet{ID: PongPacketID, Data: encodeLong(payload)}
+}
+
+// DecodePong decodes pong packet, returning its payload.
+func DecodePong(packet Packet) (int64, error) {
+	if packet.ID != PongPacketID {
+		return 0, unexpectedPacket(int64(PongPacketID), int64(packet.ID))
+	}
+	return decodeLong(packet.Data)
+}
+
+func encodeLong(value int64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, uint64(value))
+	return b
+}
+
+func decodeLong(data []byte) (int64, error) {
+	if len(data) != 8 {
+		return 0, fmt.Errorf("%w: expected payload of 8 bytes, but instead got %d", ErrMalformedPacket, len(data))
+	}
+	return int64(binary.BigEndian.Uint64(data)), nil
+}
+
+// AppendString appends s encoded as string (UTF-8 prefixed with its length in bytes as VarInt) to b.
+func AppendString(b []byte, s string) []byte {
+	return append(AppendVarInt(b, int32(len(s))), s...)
+}
+
+// ReadString reads string (UTF-8 prefixed with its length in bytes as VarInt) from reader.
+// Its length is checked against the remaining data before it is allocated.
+func ReadString(reader *bytes.Reader) (string, error) {
+	length, err := ReadVarInt(reader)
+	if err != nil {
+		return "", unexpectedEOF(err)
+	} else if length < 0 {
+		return "", fmt.Errorf("%w: invalid string length %d", ErrMalformedPacket, length)
+	} else if int(length) > reader.Len() {
+		return "", io.ErrUnexpectedEOF
+	}
+
+	b := make([]byte, length)
+	_, _ = reader.Read(b)
+	return string(b), nil
+}
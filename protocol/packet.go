@@ -0,0 +1,197 @@
+package protocol
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+)
+
+const (
+	// DefaultMaxPacketSize is the maximum length of packet vanilla servers accept (3-byte VarInt.)
+	DefaultMaxPacketSize = 2097151
+
+	// DefaultMaxUncompressedSize is the maximum length of decompressed packet vanilla servers accept.
+	DefaultMaxUncompressedSize = 8388608
+)
+
+// Packet is a single packet of Netty-based (1.7+) protocol.
+type Packet struct {
+	// ID is the packet ID.
+	ID int32
+
+	// Data is packet data following packet ID.
+	Data []byte
+}
+
+// PacketReader reads Netty-framed packets (each prefixed with its length as VarInt) from a stream.
+// Reads are buffered, and packets are read in full even if they arrive in multiple segments.
+// PacketReader is not safe for concurrent use.
+type PacketReader struct {
+	// MaxPacketSize is the maximum length of packet frame.
+	// By default (if zero), it is 2097151 bytes, the maximum packet length of vanilla servers.
+	MaxPacketSize int
+
+	// MaxUncompressedSize is the maximum length of decompressed packet (if compression is enabled.)
+	// By default (if zero), it is 8388608 bytes, the maximum decompressed length of vanilla servers.
+	MaxUncompressedSize int
+
+	reader    *bufio.Reader
+	threshold int
+}
+
+// NewPacketReader constructs new PacketReader reading packets from reader without compression.
+// If reader is *bufio.Reader, it is used as is, so that data it already buffered is not lost.
+func NewPacketReader(reader io.Reader) *PacketReader {
+	return &PacketReader{reader: bufio.NewReader(reader), threshold: -1}
+}
+
+// SetCompressionThreshold enables compressed framing of packets (as set by Set Compression packet);
+// packets of threshold bytes or more are expected to be zlib-compressed. Negative threshold disables it.
+func (r *PacketReader) SetCompressionThreshold(threshold int) {
+	r.threshold = threshold
+}
+
+// ReadPacket reads a single packet. It returns io.EOF only if stream ended before the packet began,
+// io.ErrUnexpectedEOF if stream ended in the middle of it, and LimitError if packet exceeds limits.
+func (r *PacketReader) ReadPacket() (Packet, error) {
+	// Read frame length as VarInt, ensure it is sane and fits limit before allocating buffer for it
+	length, err := ReadVarInt(r.reader)
+	if err != nil {
+		return Packet{}, err
+	} else if length <= 0 {
+		return Packet{}, fmt.Errorf("%w: invalid packet length %d", ErrMalformedPacket, length)
+	}
+	if err = CheckLimit("MaxPacketSize", int64(length), r.maxPacketSize()); err != nil {
+		return Packet{}, err
+	}
+
+	// Read entire frame
+	frame := make([]byte, length)
+	if _, err = io.ReadFull(r.reader, frame); err != nil {
+		return Packet{}, unexpectedEOF(err)
+	}
+
+	if r.threshold >= 0 {
+		if frame, err = r.decompress(frame); err != nil {
+			return Packet{}, err
+		}
+	}
+
+	// Read packet ID as VarInt, the rest is packet data
+	fr := bytes.NewReader(frame)
+	id, err := ReadVarInt(fr)
+	if err != nil {
+		return Packet{}, fmt.Errorf("%w: invalid packet ID: %s", ErrMalformedPacket, unexpectedEOF(err))
+	}
+	return Packet{ID: id, Data: frame[len(frame)-fr.Len():]}, nil
+}
+
+// decompress decodes compressed frame, which is prefixed with decompressed length as VarInt
+// (or zero, if frame is not compressed.)
+func (r *PacketReader) decompress(frame []byte) ([]byte, error) {
+	fr := bytes.NewReader(frame)
+	length, err := ReadVarInt(fr)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid decompressed length: %s", ErrMalformedPacket, unexpectedEOF(err))
+	}
+	data := frame[len(frame)-fr.Len():]
+
+	// Zero length means packet is below threshold and is not compressed
+	if length == 0 {
+		return data, nil
+	} else if length < 0 || int(length) < r.threshold {
+		return nil, fmt.Errorf("%w: decompressed length %d is below threshold %d", ErrMalformedPacket, length, r.threshold)
+	}
+	if err = CheckLimit("MaxUncompressedSize", int64(length), r.maxUncompressedSize()); err != nil {
+		return nil, err
+	}
+
+	// Decompress data, ensuring it is exactly of announced length
+	zr, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid compressed data: %s", ErrMalformedPacket, err)
+	}
+	defer func() { _ = zr.Close() }()
+	decompressed := make([]byte, length)
+	if _, err = io.ReadFull(zr, decompressed); err != nil {
+		return nil, fmt.Errorf("%w: invalid compressed data: %s", ErrMalformedPacket, unexpectedEOF(err))
+	}
+	if n, _ := zr.Read(make([]byte, 1)); n != 0 {
+		return nil, fmt.Errorf("%w: compressed data is longer than decompressed length %d", ErrMalformedPacket, length)
+	}
+	return decompressed, nil
+}
+
+func (r *PacketReader) maxPacketSize() int {
+	if r.MaxPacketSize <= 0 {
+		return DefaultMaxPacketSize
+	}
+	return r.MaxPacketSize
+}
+
+func (r *PacketReader) maxUncompressedSize() int {
+	if r.MaxUncompressedSize <= 0 {
+		return DefaultMaxUncompressedSize
+	}
+	return r.MaxUncompressedSize
+}
+
+// PacketWriter writes Netty-framed packets to a stream; every packet is written with a single Write call.
+// PacketWriter is not safe for concurrent use.
+type PacketWriter struct {
+	writer    io.Writer
+	threshold int
+}
+
+// NewPacketWriter constructs new PacketWriter writing packets to writer without compression.
+func NewPacketWriter(writer io.Writer) *PacketWriter {
+	return &PacketWriter{writer: writer, threshold: -1}
+}
+
+// SetCompressionThreshold enables compressed framing of packets (as set by Set Compression packet);
+// packets of threshold bytes or more are zlib-compressed. Negative threshold disables it.
+func (w *PacketWriter) SetCompressionThreshold(threshold int) {
+	w.threshold = threshold
+}
+
+// WritePacket writes a single packet.
+func (w *PacketWriter) WritePacket(packet Packet) error {
+	// Encode packet ID followed by packet data
+	content := make([]byte, 0, MaxVarIntLength+len(packet.Data))
+	content = AppendVarInt(content, packet.ID)
+	content = append(content, packet.Data...)
+
+	if w.threshold >= 0 {
+		var err error
+		if content, err = w.compress(content); err != nil {
+			return err
+		}
+	}
+
+	// Prefix frame with its length as VarInt
+	frame := make([]byte, 0, MaxVarIntLength+len(content))
+	frame = AppendVarInt(frame, int32(len(content)))
+	frame = append(frame, content...)
+	_, err := w.writer.Write(frame)
+	return err
+}
+
+// compress encodes content compressed if it is of threshold length or longer, prefixing it with
+// decompressed length as VarInt (or zero, if content is not compressed.)
+func (w *PacketWriter) compress(content []byte) ([]byte, error) {
+	if len(content) < w.threshold {
+		return append(AppendVarInt(make([]byte, 0, 1+len(content)), 0), content...), nil
+	}
+
+	compressed := bytes.NewBuffer(AppendVarInt(nil, int32(len(content))))
+	zw := zlib.NewWriter(compressed)
+	if _, err := zw.Write(content); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return compressed.Bytes(), nil
+}
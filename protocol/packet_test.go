@@ -0,0 +1,165 @@
+package protocol
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"io"
+	"testing"
+	"testing/iotest"
+)
+
+func TestPacketReaderShortReads(t *testing.T) {
+	packets := []Packet{
+		{ID: 0x00, Data: []byte(`{"description":"A Minecraft Server"}`)},
+		{ID: 0x01, Data: bytes.Repeat([]byte{0xaa}, 300)},
+		{ID: 0x7f, Data: nil},
+	}
+	var buf bytes.Buffer
+	writer := NewPacketWriter(&buf)
+	for _, packet := range packets {
+		if err := writer.WritePacket(packet); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cases := []struct {
+		name   string
+		reader func(r io.Reader) io.Reader
+	}{
+		{"whole", func(r io.Reader) io.Reader { return r }},
+		{"one byte", iotest.OneByteReader},
+		{"half", iotest.HalfReader},
+		{"data with EOF", iotest.DataErrReader},
+	}
+
+	for _, c := range cases {
+		reader := NewPacketReader(c.reader(bytes.NewReader(buf.Bytes())))
+		for i, expected := range packets {
+			packet, err := reader.ReadPacket()
+			if err != nil {
+				t.Fatalf("%s: could not read packet #%d: %s", c.name, i, err)
+			}
+			if packet.ID != expected.ID || !bytes.Equal(packet.Data, expected.Data) {
+				t.Errorf("%s: expected packet #%d to be %#x (%d bytes), got %#x (%d bytes)",
+					c.name, i, expected.ID, len(expected.Data), packet.ID, len(packet.Data))
+			}
+		}
+		if _, err := reader.ReadPacket(); err != io.EOF {
+			t.Errorf("%s: expected io.EOF after the last packet, got %v", c.name, err)
+		}
+	}
+}
+
+func TestPacketReaderErrors(t *testing.T) {
+	cases := []struct {
+		name    string
+		encoded []byte
+		max     int
+		err     error
+	}{
+		{"truncated length", []byte{0x80}, 0, io.ErrUnexpectedEOF},
+		{"truncated frame", []byte{0x05, 0x00, 0x01}, 0, io.ErrUnexpectedEOF},
+		{"zero length", []byte{0x00}, 0, ErrMalformedPacket},
+		{"negative length", []byte{0xff, 0xff, 0xff, 0xff, 0x0f}, 0, ErrMalformedPacket},
+		{"length too long", []byte{0x80, 0x80, 0x80, 0x80, 0x80, 0x01}, 0, ErrVarIntTooLong},
+		{"length over limit", []byte{0x05, 0x00, 0x01, 0x02, 0x03, 0x04}, 4, ErrLimitExceeded},
+		{"truncated packet ID", []byte{0x01, 0x80}, 0, ErrMalformedPacket},
+	}
+
+	for _, c := range cases {
+		reader := NewPacketReader(bytes.NewReader(c.encoded))
+		reader.MaxPacketSize = c.max
+		if _, err := reader.ReadPacket(); !errors.Is(err, c.err) {
+			t.Errorf("%s: expected error %v, got %v", c.name, c.err, err)
+		}
+	}
+}
+
+func TestPacketCompressionThreshold(t *testing.T) {
+	const threshold = 64
+	cases := []struct {
+		name       string
+		size       int
+		compressed bool
+	}{
+		{"empty", 0, false},
+		{"below threshold", threshold - 2, false},
+		{"at threshold", threshold - 1, true},
+		{"above threshold", 1024, true},
+	}
+
+	for _, c := range cases {
+		// Packet ID takes one byte, so that content is size+1 bytes long
+		packet := Packet{ID: 0x01, Data: bytes.Repeat([]byte("minequery"), c.size)[:c.size]}
+
+		var buf bytes.Buffer
+		writer := NewPacketWriter(&buf)
+		writer.SetCompressionThreshold(threshold)
+		if err := writer.WritePacket(packet); err != nil {
+			t.Fatal(err)
+		}
+
+		// Data length following frame length is zero for uncompressed packets
+		frame := bytes.NewReader(buf.Bytes())
+		if _, err := ReadVarInt(frame); err != nil {
+			t.Fatal(err)
+		}
+		length, err := ReadVarInt(frame)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if compressed := length != 0; compressed != c.compressed {
+			t.Errorf("%s: expected compressed to be %t, got %t", c.name, c.compressed, compressed)
+		}
+
+		reader := NewPacketReader(&buf)
+		reader.SetCompressionThreshold(threshold)
+		read, err := reader.ReadPacket()
+		if err != nil {
+			t.Fatalf("%s: %s", c.name, err)
+		}
+		if read.ID != packet.ID || !bytes.Equal(read.Data, packet.Data) {
+			t.Errorf("%s: packet doesn't match after round trip", c.name)
+		}
+	}
+}
+
+func TestPacketDecompressionErrors(t *testing.T) {
+	const threshold = 64
+	compress := func(data []byte) []byte {
+		var buf bytes.Buffer
+		zw := zlib.NewWriter(&buf)
+		_, _ = zw.Write(data)
+		_ = zw.Close()
+		return buf.Bytes()
+	}
+	frame := func(length int32, data []byte) []byte {
+		content := append(AppendVarInt(nil, length), data...)
+		return append(AppendVarInt(nil, int32(len(content))), content...)
+	}
+	content := append([]byte{0x01}, bytes.Repeat([]byte{0xaa}, 99)...)
+
+	cases := []struct {
+		name    string
+		encoded []byte
+		maxSize int
+		err     error
+	}{
+		{"below threshold", frame(threshold-1, compress(content[:threshold-1])), 0, ErrMalformedPacket},
+		{"negative length", frame(-1, compress(content)), 0, ErrMalformedPacket},
+		{"over limit", frame(100, compress(content)), 99, ErrLimitExceeded},
+		{"shorter than length", frame(101, compress(content)), 0, ErrMalformedPacket},
+		{"longer than length", frame(99, compress(content)), 0, ErrMalformedPacket},
+		{"not zlib", frame(100, content), 0, ErrMalformedPacket},
+	}
+
+	for _, c := range cases {
+		reader := NewPacketReader(bytes.NewReader(c.encoded))
+		reader.SetCompressionThreshold(threshold)
+		reader.MaxUncompressedSize = c.maxSize
+		if _, err := reader.ReadPacket(); !errors.Is(err, c.err) {
+			t.Errorf("%s: expected error %v, got %v", c.name, c.err, err)
+		}
+	}
+}
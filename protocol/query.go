@@ -0,0 +1,335 @@
+package protocol
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Packet types and constants of GS4 Query protocol.
+const (
+	QueryPacketTypeHandshake byte = 9
+	QueryPacketTypeStat      byte = 0
+
+	// QuerySessionIDMask masks bits of session ID vanilla servers echo back.
+	QuerySessionIDMask int32 = 0x0f0f0f0f
+
+	// QueryKeyValuePadding precedes Key-Value section of full stat response.
+	QueryKeyValuePadding = "splitnum\x00\x80\x00"
+
+	// QueryPlayersPadding precedes player section of full stat response.
+	QueryPlayersPadding = "\x01player_\x00\x00"
+)
+
+const (
+	queryHandshakeRequestLength = 7
+	queryBasicStatRequestLength = 11
+	queryFullStatRequestLength  = 15
+	queryResponseHeaderLength   = 5
+	queryStringTerminator       = 0
+)
+
+var (
+	queryRequestMagic    = []byte{0xfe, 0xfd}
+	queryFullStatPadding = []byte{0xff, 0xff, 0xff, 0x01}
+)
+
+// QueryRequest is a request packet of Query protocol.
+type QueryRequest struct {
+	// Type is the packet type, QueryPacketTypeHandshake or QueryPacketTypeStat.
+	Type byte
+
+	// SessionID identifies request; server echoes it back in response.
+	SessionID int32
+
+	// Token is the challenge token obtained with handshake (stat requests only.)
+	Token int32
+
+	// Full tells if full stat is requested, as opposed to basic stat (stat requests only.)
+	Full bool
+}
+
+// EncodeQueryRequest encodes request packet.
+func EncodeQueryRequest(request *QueryRequest) []byte {
+	packet := bytes.NewBuffer(make([]byte, 0, queryFullStatRequestLength))
+
+	// Write request magic, packet type and session ID
+	packet.Write(queryRequestMagic)
+	packet.WriteByte(request.Type)
+	_ = binary.Write(packet, binary.BigEndian, request.SessionID)
+
+	// Write token and padding (full stat only) of stat request
+	if request.Type == QueryPacketTypeStat {
+		_ = binary.Write(packet, binary.BigEndian, request.Token)
+		if request.Full {
+			packet.Write(queryFullStatPadding)
+		}
+	}
+
+	return packet.Bytes()
+}
+
+// DecodeQueryRequest decodes request packet. Requests are recognized by their length, contents
+// of full stat request padding are not checked.
+func DecodeQueryRequest(packet []byte) (*QueryRequest, error) {
+	if len(packet) < queryHandshakeRequestLength || !bytes.HasPrefix(packet, queryRequestMagic) {
+		return nil, fmt.Errorf("%w: not a query request", ErrMalformedPacket)
+	}
+	request := &QueryRequest{
+		Type:      packet[len(queryRequestMagic)],
+		SessionID: int32(binary.BigEndian.Uint32(packet[3:7])),
+	}
+
+	switch {
+	case request.Type == QueryPacketTypeHandshake && len(packet) == queryHandshakeRequestLength:
+		return request, nil
+	case request.Type == QueryPacketTypeStat &&
+		(len(packet) == queryBasicStatRequestLength || len(packet) == queryFullStatRequestLength):
+		request.Token = int32(binary.BigEndian.Uint32(packet[7:11]))
+		request.Full = len(packet) == queryFullStatRequestLength
+		return request, nil
+	default:
+		return nil, fmt.Errorf("%w: invalid request of type %#x and length %d", ErrMalformedPacket, request.Type, len(packet))
+	}
+}
+
+// QueryResponse is a response packet of Query protocol.
+type QueryResponse struct {
+	// Type is the packet type of request response is sent to.
+	Type byte
+
+	// SessionID is the session ID of request response is sent to.
+	SessionID int32
+
+	// Body is the response body following packet type and session ID.
+	Body []byte
+}
+
+// EncodeQueryResponse encodes response packet.
+func EncodeQueryResponse(response *QueryResponse) []byte {
+	packet := make([]byte, queryResponseHeaderLength, queryResponseHeaderLength+len(response.Body))
+	packet[0] = response.Type
+	binary.BigEndian.PutUint32(packet[1:], uint32(response.SessionID))
+	return append(packet, response.Body...)
+}
+
+// DecodeQueryResponse decodes response packet; its Body refers to packet.
+func DecodeQueryResponse(packet []byte) (*QueryResponse, error) {
+	if len(packet) < queryResponseHeaderLength {
+		return nil, fmt.Errorf("%w: response is too short", ErrMalformedPacket)
+	}
+	return &QueryResponse{
+		Type:      packet[0],
+		SessionID: int32(binary.BigEndian.Uint32(packet[1:5])),
+		Body:      packet[queryResponseHeaderLength:],
+	}, nil
+}
+
+// EncodeQueryToken encodes handshake response body with challenge token.
+func EncodeQueryToken(token int32) []byte {
+	return append([]byte(strconv.Itoa(int(token))), queryStringTerminator)
+}
+
+// DecodeQueryToken decodes handshake response body, returning challenge token. Body missing
+// NUL terminator is only rejected if strict is set.
+func DecodeQueryToken(body []byte, strict bool) (int32, error) {
+	token, err := trimQueryBody(body, strict)
+	if err != nil {
+		return 0, err
+	}
+	value, err := strconv.ParseInt(string(token), 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("%w: could not parse challenge token: %s", ErrMalformedPacket, err)
+	}
+	return int32(value), nil
+}
+
+// QueryBasicStat is the body of basic stat response.
+type QueryBasicStat struct {
+	MOTD          string
+	GameType      string
+	Map           string
+	OnlinePlayers int
+	MaxPlayers    int
+	Port          uint16
+	Host          string
+}
+
+// EncodeQueryBasicStat encodes basic stat response body.
+func EncodeQueryBasicStat(stat *QueryBasicStat) []byte {
+	var body bytes.Buffer
+
+	// Write NUL-terminated string fields
+	for _, field := range []string{
+		stat.MOTD,
+		stat.GameType,
+		stat.Map,
+		strconv.Itoa(stat.OnlinePlayers),
+		strconv.Itoa(stat.MaxPlayers),
+	} {
+		writeQueryString(&body, field)
+	}
+
+	// Write port as short integer (little-endian) and NUL-terminated host
+	_ = binary.Write(&body, binary.LittleEndian, stat.Port)
+	writeQueryString(&body, stat.Host)
+
+	return body.Bytes()
+}
+
+// DecodeQueryBasicStat decodes basic stat response body. Body missing NUL terminator is only
+// rejected if strict is set.
+func DecodeQueryBasicStat(body []byte, strict bool) (*QueryBasicStat, error) {
+	data, err := trimQueryBody(body, strict)
+	if err != nil {
+		return nil, err
+	}
+
+	// Split body by NUL bytes (into 6 substrings, because 6th also contains port and hostname
+	// that need to be parsed specially).
+	fields := strings.SplitN(string(data), string(rune(queryStringTerminator)), 6)
+	if len(fields) != 6 {
+		return nil, fmt.Errorf("%w: expected 5 first string fields in response body, got %d", ErrMalformedPacket, len(fields)-1)
+	}
+	online, max, err := decodePlayerCounts(fields[3], fields[4])
+	if err != nil {
+		return nil, err
+	}
+
+	// Unpack port as unsigned short integer (little-endian) followed by host
+	if len(fields[5]) < 2 {
+		return nil, fmt.Errorf("%w: response body is missing port", ErrMalformedPacket)
+	}
+	port := binary.LittleEndian.Uint16([]byte(fields[5][:2]))
+
+	return &QueryBasicStat{
+		MOTD:          fields[0],
+		GameType:      fields[1],
+		Map:           fields[2],
+		OnlinePlayers: online,
+		MaxPlayers:    max,
+		Port:          port,
+		Host:          fields[5][2:],
+	}, nil
+}
+
+// QueryKeyValue is a single entry of full stat Key-Value section.
+type QueryKeyValue struct {
+	Key   string
+	Value string
+}
+
+// QueryFullStat is the body of full stat response.
+type QueryFullStat struct {
+	// KeyValues holds Key-Value section entries in order.
+	KeyValues []QueryKeyValue
+
+	// Players holds player names of player section.
+	Players []string
+}
+
+// EncodeQueryFullStat encodes full stat response body. Entries with empty keys and empty player
+// names are skipped, since they would terminate their sections.
+func EncodeQueryFullStat(stat *QueryFullStat) []byte {
+	var body bytes.Buffer
+
+	// Write Key-Value section padding and entries, followed by empty key terminating section
+	body.WriteString(QueryKeyValuePadding)
+	for _, entry := range stat.KeyValues {
+		if entry.Key == "" {
+			continue
+		}
+		writeQueryString(&body, entry.Key)
+		writeQueryString(&body, entry.Value)
+	}
+	body.WriteByte(queryStringTerminator)
+
+	// Write player section padding and names, followed by empty name terminating section
+	body.WriteString(QueryPlayersPadding)
+	for _, player := range stat.Players {
+		if player == "" {
+			continue
+		}
+		writeQueryString(&body, player)
+	}
+	body.WriteByte(queryStringTerminator)
+
+	return body.Bytes()
+}
+
+// DecodeQueryFullStat decodes full stat response body. Body missing NUL terminator or with
+// invalid section paddings is only rejected if strict is set.
+func DecodeQueryFullStat(body []byte, strict bool) (*QueryFullStat, error) {
+	data, err := trimQueryBody(body, strict)
+	if err != nil {
+		return nil, err
+	}
+	reader := bytes.NewReader(data)
+	stat := &QueryFullStat{KeyValues: make([]QueryKeyValue, 0, 16), Players: make([]string, 0, 10)}
+
+	// Read Key-Value section padding and entries until empty key
+	if !readQueryPadding(reader, QueryKeyValuePadding) && strict {
+		return nil, fmt.Errorf("%w: key-value section padding is invalid", ErrMalformedPacket)
+	}
+	for {
+		key := readQueryString(reader)
+		if key == "" {
+			break
+		}
+		stat.KeyValues = append(stat.KeyValues, QueryKeyValue{key, readQueryString(reader)})
+	}
+
+	// Read player section padding and names until empty name
+	if !readQueryPadding(reader, QueryPlayersPadding) && strict {
+		return nil, fmt.Errorf("%w: player section padding is invalid", ErrMalformedPacket)
+	}
+	for {
+		player := readQueryString(reader)
+		if player == "" {
+			break
+		}
+		stat.Players = append(stat.Players, player)
+	}
+
+	return stat, nil
+}
+
+// trimQueryBody strips NUL terminator of response body, returning error if it is missing
+// and strict is set, or if body is empty.
+func trimQueryBody(body []byte, strict bool) ([]byte, error) {
+	if len(body) == 0 {
+		return nil, fmt.Errorf("%w: empty response body", ErrMalformedPacket)
+	}
+	if body[len(body)-1] == queryStringTerminator {
+		return body[:len(body)-1], nil
+	} else if strict {
+		return nil, fmt.Errorf("%w: response body is not NUL-terminated", ErrMalformedPacket)
+	}
+	return body, nil
+}
+
+func writeQueryString(buffer *bytes.Buffer, s string) {
+	buffer.WriteString(s)
+	buffer.WriteByte(queryStringTerminator)
+}
+
+// readQueryString reads NUL-terminated string; string is terminated by the end of data too.
+func readQueryString(reader *bytes.Reader) string {
+	var s strings.Builder
+	for {
+		b, err := reader.ReadByte()
+		if err != nil || b == queryStringTerminator {
+			return s.String()
+		}
+		s.WriteByte(b)
+	}
+}
+
+// readQueryPadding skips section padding, reporting if it matches expected one.
+func readQueryPadding(reader *bytes.Reader, expected string) bool {
+	padding := make([]byte, len(expected))
+	n, _ := reader.Read(padding)
+	return string(padding[:n]) == expected
+}
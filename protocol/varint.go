@@ -0,0 +1,89 @@
+package protocol
+
+import "io"
+
+const (
+	// MaxVarIntLength is the maximum length of encoded VarInt.
+	MaxVarIntLength = 5
+
+	// MaxVarLongLength is the maximum length of encoded VarLong.
+	MaxVarLongLength = 10
+)
+
+// ReadVarInt reads VarInt (two's complement 32-bit integer, 7 bits per byte, least significant
+// group first.) It returns ErrVarIntTooLong if VarInt is longer than MaxVarIntLength bytes.
+func ReadVarInt(reader io.ByteReader) (int32, error) {
+	value, err := readVarNumber(reader, MaxVarIntLength, ErrVarIntTooLong)
+	return int32(uint32(value)), err
+}
+
+// ReadUnsignedVarInt reads VarInt as unsigned 32-bit integer (such as lengths and packet IDs.)
+func ReadUnsignedVarInt(reader io.ByteReader) (uint32, error) {
+	value, err := readVarNumber(reader, MaxVarIntLength, ErrVarIntTooLong)
+	return uint32(value), err
+}
+
+// ReadVarLong reads VarLong (two's complement 64-bit integer, 7 bits per byte, least significant
+// group first.) It returns ErrVarLongTooLong if VarLong is longer than MaxVarLongLength bytes.
+func ReadVarLong(reader io.ByteReader) (int64, error) {
+	value, err := readVarNumber(reader, MaxVarLongLength, ErrVarLongTooLong)
+	return int64(value), err
+}
+
+// ReadUnsignedVarLong reads VarLong as unsigned 64-bit integer.
+func ReadUnsignedVarLong(reader io.ByteReader) (uint64, error) {
+	return readVarNumber(reader, MaxVarLongLength, ErrVarLongTooLong)
+}
+
+// readVarNumber reads at most maxLength bytes of VarInt or VarLong, returning io.EOF if reader
+// is empty and io.ErrUnexpectedEOF if it ends in the middle of number.
+func readVarNumber(reader io.ByteReader, maxLength int, errTooLong error) (uint64, error) {
+	var value uint64
+	for i := 0; i < maxLength; i++ {
+		b, err := reader.ReadByte()
+		if err != nil {
+			if i > 0 {
+				return 0, unexpectedEOF(err)
+			}
+			return 0, err
+		}
+
+		value |= uint64(b&0x7f) << (7 * i)
+		if b&0x80 == 0 {
+			return value, nil
+		}
+	}
+	return 0, errTooLong
+}
+
+// AppendVarInt appends value encoded as VarInt to b. Negative values are encoded in two's
+// complement form and always take MaxVarIntLength bytes.
+func AppendVarInt(b []byte, value int32) []byte {
+	return appendVarNumber(b, uint64(uint32(value)))
+}
+
+// AppendVarLong appends value encoded as VarLong to b. Negative values are encoded in two's
+// complement form and always take MaxVarLongLength bytes.
+func AppendVarLong(b []byte, value int64) []byte {
+	return appendVarNumber(b, uint64(value))
+}
+
+func appendVarNumber(b []byte, value uint64) []byte {
+	for value >= 0x80 {
+		b = append(b, byte(value)|0x80)
+		value >>= 7
+	}
+	return append(b, byte(value))
+}
+
+// WriteVarInt writes value encoded as VarInt to writer.
+func WriteVarInt(writer io.Writer, value int32) error {
+	_, err := writer.Write(AppendVarInt(make([]byte, 0, MaxVarIntLength), value))
+	return err
+}
+
+// WriteVarLong writes value encoded as VarLong to writer.
+func WriteVarLong(writer io.Writer, value int64) error {
+	_, err := writer.Write(AppendVarLong(make([]byte, 0, MaxVarLongLength), value))
+	return err
+}
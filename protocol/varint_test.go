@@ -0,0 +1,109 @@
+package protocol
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math"
+	"testing"
+)
+
+func TestVarInt(t *testing.T) {
+	cases := []struct {
+		value   int32
+		encoded []byte
+	}{
+		{0, []byte{0x00}},
+		{1, []byte{0x01}},
+		{127, []byte{0x7f}},
+		{128, []byte{0x80, 0x01}},
+		{255, []byte{0xff, 0x01}},
+		{25565, []byte{0xdd, 0xc7, 0x01}},
+		{2097151, []byte{0xff, 0xff, 0x7f}},
+		{math.MaxInt32, []byte{0xff, 0xff, 0xff, 0xff, 0x07}},
+		{-1, []byte{0xff, 0xff, 0xff, 0xff, 0x0f}},
+		{math.MinInt32, []byte{0x80, 0x80, 0x80, 0x80, 0x08}},
+	}
+
+	for _, c := range cases {
+		if encoded := AppendVarInt(nil, c.value); !bytes.Equal(encoded, c.encoded) {
+			t.Errorf("expected %d to be encoded as %#v, got %#v", c.value, c.encoded, encoded)
+		}
+		value, err := ReadVarInt(bytes.NewReader(c.encoded))
+		if err != nil {
+			t.Errorf("could not read %#v: %s", c.encoded, err)
+		} else if value != c.value {
+			t.Errorf("expected %#v to be read as %d, got %d", c.encoded, c.value, value)
+		}
+	}
+}
+
+func TestVarLong(t *testing.T) {
+	cases := []struct {
+		value   int64
+		encoded []byte
+	}{
+		{0, []byte{0x00}},
+		{127, []byte{0x7f}},
+		{128, []byte{0x80, 0x01}},
+		{math.MaxInt32, []byte{0xff, 0xff, 0xff, 0xff, 0x07}},
+		{math.MaxInt64, []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x7f}},
+		{-1, []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01}},
+		{math.MinInt64, []byte{0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x01}},
+	}
+
+	for _, c := range cases {
+		if encoded := AppendVarLong(nil, c.value); !bytes.Equal(encoded, c.encoded) {
+			t.Errorf("expected %d to be encoded as %#v, got %#v", c.value, c.encoded, encoded)
+		}
+		value, err := ReadVarLong(bytes.NewReader(c.encoded))
+		if err != nil {
+			t.Errorf("could not read %#v: %s", c.encoded, err)
+		} else if value != c.value {
+			t.Errorf("expected %#v to be read as %d, got %d", c.encoded, c.value, value)
+		}
+	}
+}
+
+func TestReadVarNumberErrors(t *testing.T) {
+	cases := []struct {
+		name    string
+		encoded []byte
+		long    bool
+		value   int64
+		err     error
+	}{
+		// Overlong (non-minimal) encodings are accepted, as they are by vanilla servers
+		{name: "overlong zero", encoded: []byte{0x80, 0x80, 0x00}, value: 0},
+		{name: "overlong VarInt", encoded: []byte{0xff, 0x80, 0x80, 0x80, 0x00}, value: 127},
+		{name: "overlong VarLong", encoded: []byte{0x81, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x00}, long: true, value: 1},
+		{name: "empty", encoded: nil, err: io.EOF},
+		{name: "empty VarLong", encoded: nil, long: true, err: io.EOF},
+		{name: "truncated", encoded: []byte{0x80, 0x80}, err: io.ErrUnexpectedEOF},
+		{name: "truncated VarLong", encoded: []byte{0xff, 0xff, 0xff, 0xff, 0xff}, long: true, err: io.ErrUnexpectedEOF},
+		{name: "6 bytes", encoded: []byte{0x80, 0x80, 0x80, 0x80, 0x80, 0x00}, err: ErrVarIntTooLong},
+		{name: "11 bytes", encoded: bytes.Repeat([]byte{0x80}, 11), long: true, err: ErrVarLongTooLong},
+	}
+
+	for _, c := range cases {
+		var value int64
+		var err error
+		if c.long {
+			value, err = ReadVarLong(bytes.NewReader(c.encoded))
+		} else {
+			var v int32
+			v, err = ReadVarInt(bytes.NewReader(c.encoded))
+			value = int64(v)
+		}
+
+		if c.err != nil {
+			if !errors.Is(err, c.err) {
+				t.Errorf("%s: expected error %v, got %v", c.name, c.err, err)
+			}
+		} else if err != nil {
+			t.Errorf("%s: unexpected error %v", c.name, err)
+		} else if value != c.value {
+			t.Errorf("%s: expected %d, got %d", c.name, c.value, value)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package minequery
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strconv"
+)
+
+// ProxyProtocolVersion is a version of HAProxy PROXY protocol header.
+type ProxyProtocolVersion byte
+
+const (
+	// ProxyProtocolV1 is the human-readable text header version.
+	ProxyProtocolV1 ProxyProtocolVersion = 1
+
+	// ProxyProtocolV2 is the binary header version.
+	ProxyProtocolV2 ProxyProtocolVersion = 2
+)
+
+// proxyProtocolV2Signature is the signature every PROXY protocol v2 header starts with.
+var proxyProtocolV2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+const (
+	proxyProtocolV2CommandProxy = 0x21
+
+	proxyProtocolV2FamilyUnspec = 0x00
+	proxyProtocolV2FamilyTCP4   = 0x11
+	proxyProtocolV2FamilyTCP6   = 0x21
+)
+
+// ProxyHeader configures HAProxy PROXY protocol header sent on TCP connections before any packets,
+// which is required by servers and proxies (such as Velocity or BungeeCord with haproxy-protocol
+// enabled) that are behind load balancers speaking PROXY protocol.
+type ProxyHeader struct {
+	// Version is the PROXY protocol version of the header.
+	Version ProxyProtocolVersion
+
+	// SourceAddr is the client address sent in the header.
+	// By default (if nil), local address of connection is used.
+	SourceAddr *net.TCPAddr
+
+	// DestinationAddr is the server address sent in the header. By default (if nil), address of
+	// server connection was dialed to is used: remote address of connection made with net.Dialer,
+	// or dialed IP address for custom dialers (such as SOCKS5 proxies, remote address of which is
+	// the address of proxy.) If server hostname was resolved by custom dialer, address is unknown.
+	DestinationAddr *net.TCPAddr
+}
+
+// Marshal encodes header of connection made from local to destination address, which are used
+// in place of unset SourceAddr and DestinationAddr. If addresses are not TCP addresses, header
+// with unknown addresses is encoded.
+func (h *ProxyHeader) Marshal(local, destination net.Addr) ([]byte, error) {
+	sourceAddr, destinationAddr := h.SourceAddr, h.DestinationAddr
+	if sourceAddr == nil {
+		sourceAddr, _ = local.(*net.TCPAddr)
+	}
+	if destinationAddr == nil {
+		destinationAddr, _ = destination.(*net.TCPAddr)
+	}
+
+	switch h.Version {
+	case ProxyProtocolV1:
+		return proxyProtocolV1Header(sourceAddr, destinationAddr), nil
+	case ProxyProtocolV2:
+		return proxyProtocolV2Header(sourceAddr, destinationAddr), nil
+	default:
+		return nil, fmt.Errorf("unknown PROXY protocol version %d", h.Version)
+	}
+}
+
+// proxyProtocolV1Header encodes text header; if any address is unknown, UNKNOWN protocol is used.
+func proxyProtocolV1Header(source, destination *net.TCPAddr) []byte {
+	if source == nil || destination == nil {
+		return []byte("PROXY UNKNOWN\r\n")
+	}
+
+	sourceIP, destinationIP, ip4 := proxyProtocolIPs(source, destination)
+	protocol := "TCP6"
+	if ip4 {
+		protocol = "TCP4"
+	}
+	return []byte(fmt.Sprintf("PROXY %s %s %s %d %d\r\n",
+		protocol, proxyProtocolV1IP(sourceIP, ip4), proxyProtocolV1IP(destinationIP, ip4), source.Port, destination.Port))
+}
+
+// proxyProtocolV1IP formats ip of text header; unlike net.IP String, IPv4-mapped IPv6 addresses
+// are formatted as IPv6 addresses, since both addresses of TCP6 header must be IPv6.
+func proxyProtocolV1IP(ip net.IP, ip4 bool) string {
+	if ip4Mapped := ip.To4(); !ip4 && ip4Mapped != nil {
+		return "::ffff:" + ip4Mapped.String()
+	}
+	return ip.String()
+}
+
+// proxyProtocolV2Header encodes binary header; if any address is unknown, UNSPEC family is used.
+func proxyProtocolV2Header(source, destination *net.TCPAddr) []byte {
+	var header bytes.Buffer
+	header.Write(proxyProtocolV2Signature)
+	header.WriteByte(proxyProtocolV2CommandProxy)
+
+	if source == nil || destination == nil {
+		header.WriteByte(proxyProtocolV2FamilyUnspec)
+		_ = binary.Write(&header, binary.BigEndian, uint16(0))
+		return header.Bytes()
+	}
+
+	// Write family and length of address block
+	sourceIP, destinationIP, ip4 := proxyProtocolIPs(source, destination)
+	if ip4 {
+		header.WriteByte(proxyProtocolV2FamilyTCP4)
+	} else {
+		header.WriteByte(proxyProtocolV2FamilyTCP6)
+	}
+	_ = binary.Write(&header, binary.BigEndian, uint16(2*len(sourceIP)+4))
+
+	// Write address block: source and destination addresses followed by their ports
+	header.Write(sourceIP)
+	header.Write(destinationIP)
+	_ = binary.Write(&header, binary.BigEndian, uint16(source.Port))
+	_ = binary.Write(&header, binary.BigEndian, uint16(destination.Port))
+	return header.Bytes()
+}
+
+// proxyProtocolIPs returns addresses of the same family: IPv4 if both addresses are IPv4,
+// or IPv6 (with IPv4 addresses mapped to IPv6) otherwise.
+func proxyProtocolIPs(source, destination *net.TCPAddr) (net.IP, net.IP, bool) {
+	sourceIP4, destinationIP4 := source.IP.To4(), destination.IP.To4()
+	if sourceIP4 != nil && destinationIP4 != nil {
+		return sourceIP4, destinationIP4, true
+	}
+	return source.IP.To16(), destination.IP.To16(), false
+}
+
+// proxyHeaderDestination returns server address of conn dialed to target (host:port) with dialer,
+// or nil if it is not known.
+func proxyHeaderDestination(dialer ContextDialer, conn net.Conn, target string) net.Addr {
+	// Connections of net.Dialer are made to server directly, so remote address is the resolved server address
+	if _, ok := dialer.(*net.Dialer); ok {
+		return conn.RemoteAddr()
+	}
+
+	// Remote address of custom dialer connections may be the address of proxy, so dialed address is used
+	// (unless it is a hostname resolved by dialer itself)
+	host, portStr, err := net.SplitHostPort(target)
+	if err != nil {
+		return nil
+	}
+	ip := net.ParseIP(host)
+	port, err := strconv.Atoi(portStr)
+	if ip == nil || err != nil {
+		return nil
+	}
+	return &net.TCPAddr{IP: ip, Port: port}
+}
+
+// writeProxyHeader writes Pinger ProxyHeader (if it is set) with destination address to conn.
+func (p *Pinger) writeProxyHeader(conn net.Conn, destination net.Addr) error {
+	if p.ProxyHeader == nil {
+		return nil
+	}
+
+	header, err := p.ProxyHeader.Marshal(conn.LocalAddr(), destination)
+	if err != nil {
+		return err
+	}
+	if _, err = conn.Write(header); err != nil {
+		return fmt.Errorf("could not write PROXY protocol header: %w", err)
+	}
+	return nil
+}
@@ -1,31 +1,16 @@
 package minequery
 
 import (
-	"bytes"
+	"context"
+	"crypto/rand"
 	"encoding/binary"
 	"fmt"
-	"io"
 	"net"
 	"strconv"
 	"strings"
-	"time"
-)
-
-var (
-	queryRequestHeader            = []byte{0xfe, 0xfd}
-	queryResponseStringTerminator = []byte{0x0}
-	queryFullStatPadding          = []byte{0xff, 0xff, 0xff, 0x01}
-	queryKVSectionPadding         = []byte{0x73, 0x70, 0x6c, 0x69, 0x74, 0x6e, 0x75, 0x6d, 0x00, 0x80, 0x00}
-	queryPlayerSectionPadding     = []byte{0x01, 0x70, 0x6c, 0x61, 0x79, 0x65, 0x72, 0x5f, 0x00, 0x00}
-)
+	"sync"
 
-const (
-	queryPacketTypeHandshake byte = 9
-	queryPacketTypeStat      byte = 0
-)
-
-const (
-	querySessionIDMask int32 = 0x0f0f0f0f
+	"github.com/dreamscached/minequery/v2/protocol"
 )
 
 const (
@@ -44,6 +29,13 @@ type BasicQueryStatus struct {
 	Host          string
 }
 
+// String returns a user-friendly representation of a basic query response.
+// It contains game type, map, online count and naturalized MOTD.
+func (s *BasicQueryStatus) String() string {
+	return fmt.Sprintf("Minecraft Server (Query, %s, map %s), %d/%d players online, MOTD: %s",
+		s.GameType, s.Map, s.OnlinePlayers, s.MaxPlayers, naturalizeMOTD(s.MOTD))
+}
+
 // FullQueryPluginEntry holds plugin entry info (name and version) of plugin sent via Query protocol.
 type FullQueryPluginEntry struct {
 	Name    string
@@ -67,6 +59,13 @@ type FullQueryStatus struct {
 	Data          map[string]string
 }
 
+// String returns a user-friendly representation of a full query response.
+// It contains server version, online count, plugin count and naturalized MOTD.
+func (s *FullQueryStatus) String() string {
+	return fmt.Sprintf("Minecraft Server (Query, %s, %s), %d/%d players online, %d plugins, MOTD: %s",
+		s.Version, s.ServerVersion, s.OnlinePlayers, s.MaxPlayers, len(s.Plugins), naturalizeMOTD(s.MOTD))
+}
+
 // QueryBasic queries Minecraft servers and returns simplified query response.
 //
 //goland:noinspection GoUnusedExportedFunction
@@ -74,49 +73,36 @@ func QueryBasic(host string, port int) (*BasicQueryStatus, error) {
 	return defaultPinger.QueryBasic(host, port)
 }
 
+// QueryBasicContext queries Minecraft servers and returns simplified query response, using the provided context
+// for cancellation and deadlines of connection establishment and packet exchange.
+//
+//goland:noinspection GoUnusedExportedFunction
+func QueryBasicContext(ctx context.Context, host string, port int) (*BasicQueryStatus, error) {
+	return defaultPinger.QueryBasicContext(ctx, host, port)
+}
+
 // QueryBasic queries Minecraft servers and returns simplified query response.
 //
 //goland:noinspection GoUnusedExportedFunction
 func (p *Pinger) QueryBasic(host string, port int) (*BasicQueryStatus, error) {
-	// Try to use cache first.
-	sessionData, hit := p.getCachedSession(host, port)
-	if hit {
-		// Open UDP connection with predefined local address from cache.
-		conn, err := p.openUDPConnWithLocalAddr(host, port, sessionData.Address)
-		if err != nil {
-			return nil, err
-		}
-
-		// Request basic query info with cached session.
-		res, err := p.requestBasicStat(conn, sessionData)
-		if err == nil {
-			_ = conn.Close()
-			return res, nil
-		}
-
-		_ = conn.Close()
-		// On error, fall back to creating a new session.
-	}
-
-	// Open UDP connection.
-	conn, err := p.openUDPConn(host, port)
-	if err != nil {
-		return nil, err
-	}
-	defer func() { _ = conn.Close() }()
+	return p.QueryBasicContext(context.Background(), host, port)
+}
 
-	// Create a new session and obtain challenge token.
-	sessionData, err = p.createAndCacheSession(port, host, conn)
-	if err != nil {
-		return nil, err
+// QueryBasicContext queries Minecraft servers and returns simplified query response, using the provided context
+// for cancellation and deadlines of connection establishment and packet exchange.
+func (p *Pinger) QueryBasicContext(ctx context.Context, host string, port int) (*BasicQueryStatus, error) {
+	// Use shared sockets of query engine, if there is one.
+	if p.QueryEngine != nil {
+		return p.QueryEngine.queryBasic(ctx, p, host, port)
 	}
 
-	// Request basic query info with newly created session.
-	res, err := p.requestBasicStat(conn, sessionData)
+	res, err := p.query(ctx, host, port, func(conn net.Conn, session session) (interface{}, error) {
+		return p.requestBasicStat(conn, session)
+	})
 	if err != nil {
 		return nil, err
 	}
-	return res, nil
+	return res.(*BasicQueryStatus), nil
 }
 
 // QueryFull queries Minecraft servers and returns full query response.
@@ -126,53 +112,53 @@ func QueryFull(host string, port int) (*FullQueryStatus, error) {
 	return defaultPinger.QueryFull(host, port)
 }
 
+// QueryFullContext queries Minecraft servers and returns full query response, using the provided context
+// for cancellation and deadlines of connection establishment and packet exchange.
+//
+//goland:noinspection GoUnusedExportedFunction
+func QueryFullContext(ctx context.Context, host string, port int) (*FullQueryStatus, error) {
+	return defaultPinger.QueryFullContext(ctx, host, port)
+}
+
 // QueryFull queries Minecraft servers and returns full query response.
 //
 //goland:noinspection GoUnusedExportedFunction
 func (p *Pinger) QueryFull(host string, port int) (*FullQueryStatus, error) {
-	// Try to use cache first.
-	sessionData, hit := p.getCachedSession(host, port)
-	if hit {
-		// Open UDP connection with predefined local address from cache.
-		conn, err := p.openUDPConnWithLocalAddr(host, port, sessionData.Address)
-		if err != nil {
-			return nil, err
-		}
-
-		// Request full query info with cached session.
-		res, err := p.requestFullStat(conn, sessionData)
-		if err == nil {
-			_ = conn.Close()
-			return res, nil
-		}
+	return p.QueryFullContext(context.Background(), host, port)
+}
 
-		_ = conn.Close()
-		// On error, fall back to creating a new session.
+// QueryFullContext queries Minecraft servers and returns full query response, using the provided context
+// for cancellation and deadlines of connection establishment and packet exchange.
+func (p *Pinger) QueryFullContext(ctx context.Context, host string, port int) (*FullQueryStatus, error) {
+	// Use shared sockets of query engine, if there is one.
+	if p.QueryEngine != nil {
+		return p.QueryEngine.queryFull(ctx, p, host, port)
 	}
 
-	// Open UDP connection.
-	conn, err := p.openUDPConn(host, port)
+	res, err := p.query(ctx, host, port, func(conn net.Conn, session session) (interface{}, error) {
+		return p.requestFullStat(conn, session)
+	})
 	if err != nil {
 		return nil, err
 	}
-	defer func() { _ = conn.Close() }()
+	return res.(*FullQueryStatus), nil
+}
 
-	// Create a new session and obtain challenge token.
-	sessionData, err = p.createAndCacheSession(port, host, conn)
-	if err != nil {
+func (p *Pinger) requestBasicStat(conn net.Conn, session session) (*BasicQueryStatus, error) {
+	if err := p.writeQueryBasicStatPacket(conn, session.SessionID, session.Token); err != nil {
 		return nil, err
 	}
 
-	// Request full query info with newly created session.
-	res, err := p.requestFullStat(conn, sessionData)
+	content, err := p.readQueryStatResponsePacket(conn, session.SessionID)
 	if err != nil {
 		return nil, err
 	}
-	return res, nil
+
+	return p.parseQueryBasicStatResponse(content)
 }
 
-func (p *Pinger) requestBasicStat(conn *net.UDPConn, session session) (*BasicQueryStatus, error) {
-	if err := p.writeQueryBasicStatPacket(conn, session.SessionID, session.Token); err != nil {
+func (p *Pinger) requestFullStat(conn net.Conn, session session) (*FullQueryStatus, error) {
+	if err := p.writeQueryFullStatPacket(conn, session.SessionID, session.Token); err != nil {
 		return nil, err
 	}
 
@@ -181,20 +167,124 @@ func (p *Pinger) requestBasicStat(conn *net.UDPConn, session session) (*BasicQue
 		return nil, err
 	}
 
-	return p.parseQueryBasicStatResponse(content)
+	return p.parseQueryFullStatResponse(content)
 }
 
-func (p *Pinger) requestFullStat(conn *net.UDPConn, session session) (*FullQueryStatus, error) {
-	if err := p.writeQueryFullStatPacket(conn, session.SessionID, session.Token); err != nil {
+// queryContextError returns ctx error if ctx is done (and therefore is the reason of err), or err otherwise.
+func queryContextError(ctx context.Context, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	return err
+}
+
+// queryRequestFunc is a stat request function accepted by query.
+type queryRequestFunc func(conn net.Conn, session session) (interface{}, error)
+
+// query obtains session of server at host and port and performs request with it.
+//
+// Sessions are cached (unless SessionCache is nil) and bound to local address of the socket handshake
+// was performed on, so requests with cached session rebind that address. Concurrent handshakes with
+// the same server are shared, and requests with the same session are serialized, since only one socket
+// can be bound to its address at a time.
+func (p *Pinger) query(ctx context.Context, host string, port int, request queryRequestFunc) (interface{}, error) {
+	// Without session cache (or with custom UDP dialer, which can't bind local address) each query
+	// performs its own handshake on its own socket.
+	if p.SessionCache == nil || p.UDPDialer != nil {
+		conn, err := p.openUDPConn(ctx, host, port)
+		if err != nil {
+			return nil, err
+		}
+		defer func() { _ = conn.Close() }()
+
+		sessionData, err := p.createSession(conn)
+		if err != nil {
+			return nil, queryContextError(ctx, err)
+		}
+		res, err := request(conn, sessionData)
+		if err != nil {
+			return nil, queryContextError(ctx, err)
+		}
+		return res, nil
+	}
+
+	// Try to use cache first, then fall back to creating a new session.
+	sessionData, hit := p.getCachedSession(host, port)
+	if hit {
+		res, err := p.requestWithSession(ctx, host, port, sessionData, request)
+		if err == nil {
+			return res, nil
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+		// On error, fall back to creating a new session (unless another call already did.)
+	}
+
+	fresh, err := p.sharedSession(ctx, host, port, sessionData)
+	if err != nil {
+		return nil, queryContextError(ctx, err)
+	}
+	res, err := p.requestWithSession(ctx, host, port, fresh, request)
+	if err != nil {
+		return nil, queryContextError(ctx, err)
+	}
+	return res, nil
+}
+
+// requestWithSession performs request on a socket bound to session local address, waiting for other
+// requests with the same session to finish first.
+func (p *Pinger) requestWithSession(
+	ctx context.Context, host string, port int, sessionData session, request queryRequestFunc,
+) (interface{}, error) {
+	release, err := p.sessions.acquire(ctx, sessionData.Address)
+	if err != nil {
 		return nil, err
 	}
+	defer release()
 
-	content, err := p.readQueryStatResponsePacket(conn, session.SessionID)
+	conn, err := p.openUDPConnWithLocalAddr(ctx, host, port, sessionData.Address)
 	if err != nil {
 		return nil, err
 	}
+	defer func() { _ = conn.Close() }()
+	return request(conn, sessionData)
+}
 
-	return p.parseQueryFullStatResponse(content)
+// sharedSession performs handshake with server at host and port and caches the new session. Concurrent
+// calls for the same server share handshake. If session in cache is no longer stale (that is, another call
+// has replaced it already), it is returned instead.
+func (p *Pinger) sharedSession(ctx context.Context, host string, port int, stale session) (session, error) {
+	if cached, hit := p.getCachedSession(host, port); hit && cached != stale {
+		return cached, nil
+	}
+
+	sessionData, err := p.sessions.handshakes.Do(ctx, getSessionCacheKey(host, port), func() (interface{}, error) {
+		// Shared handshake is limited by Pinger timeout rather than ctx of any of its callers
+		handshakeCtx := context.Background()
+		if p.Timeout > 0 {
+			var cancel context.CancelFunc
+			handshakeCtx, cancel = context.WithTimeout(handshakeCtx, p.Timeout)
+			defer cancel()
+		}
+
+		conn, err := p.openUDPConn(handshakeCtx, host, port)
+		if err != nil {
+			return nil, err
+		}
+		defer func() { _ = conn.Close() }()
+
+		sessionData, err := p.createSession(conn)
+		if err != nil {
+			return nil, err
+		}
+		p.SessionCache.SetDefault(getSessionCacheKey(host, port), sessionData)
+		return sessionData, nil
+	})
+	if err != nil {
+		return session{}, err
+	}
+	return sessionData.(session), nil
 }
 
 // Session management
@@ -204,11 +294,69 @@ type session struct {
 	Address          string
 }
 
+// querySessions coordinates use of query sessions by concurrent calls of Pinger.
+type querySessions struct {
+	// handshakes deduplicates concurrent handshakes with the same server.
+	handshakes flightGroup
+
+	// leases holds a semaphore per local address of sessions in use.
+	mu     sync.Mutex
+	leases map[string]*sessionLease
+}
+
+type sessionLease struct {
+	sem  chan struct{}
+	refs int
+}
+
+// acquire waits until no other call uses session bound to local address (or ctx is done) and returns
+// function releasing it.
+func (s *querySessions) acquire(ctx context.Context, address string) (func(), error) {
+	s.mu.Lock()
+	if s.leases == nil {
+		s.leases = make(map[string]*sessionLease)
+	}
+	lease, ok := s.leases[address]
+	if !ok {
+		lease = &sessionLease{sem: make(chan struct{}, 1)}
+		s.leases[address] = lease
+	}
+	lease.refs++
+	s.mu.Unlock()
+
+	unref := func() {
+		s.mu.Lock()
+		lease.refs--
+		if lease.refs == 0 {
+			delete(s.leases, address)
+		}
+		s.mu.Unlock()
+	}
+
+	select {
+	case lease.sem <- struct{}{}:
+		return func() {
+			<-lease.sem
+			unref()
+		}, nil
+	case <-ctx.Done():
+		unref()
+		return nil, ctx.Err()
+	}
+}
+
 func getSessionCacheKey(host string, port int) string { return fmt.Sprintf("%s:%d", host, port) }
-func generateSessionID() int32                        { return int32(time.Now().Unix()) & querySessionIDMask }
 
-func (p *Pinger) createAndCacheSession(port int, host string, conn *net.UDPConn) (session, error) {
-	// Generate new time-based session ID and write a handshake packet
+// generateSessionID returns random session ID within bits servers echo back. It is read from
+// crypto/rand, so that session IDs are unpredictable and differ between processes.
+func generateSessionID() int32 {
+	b := make([]byte, 4)
+	_, _ = rand.Read(b)
+	return int32(binary.BigEndian.Uint32(b)) & protocol.QuerySessionIDMask
+}
+
+func (p *Pinger) createSession(conn net.Conn) (session, error) {
+	// Generate new random session ID and write a handshake packet
 	sessionID := generateSessionID()
 	if err := p.writeQueryHandshakePacket(conn, sessionID); err != nil {
 		return session{}, err
@@ -226,11 +374,7 @@ func (p *Pinger) createAndCacheSession(port int, host string, conn *net.UDPConn)
 		return session{}, err
 	}
 
-	sessionData := session{sessionID, token, conn.LocalAddr().String()}
-	if p.SessionCache != nil {
-		p.SessionCache.SetDefault(getSessionCacheKey(host, port), sessionData)
-	}
-	return sessionData, nil
+	return session{sessionID, token, conn.LocalAddr().String()}, nil
 }
 
 func (p *Pinger) getCachedSession(host string, port int) (session, bool) {
@@ -250,247 +394,109 @@ func (p *Pinger) getCachedSession(host string, port int) (session, bool) {
 
 // Communication
 
-func (p *Pinger) writeQueryHandshakePacket(conn *net.UDPConn, sessionID int32) error {
-	var packet bytes.Buffer
-
-	// Write request packet header
-	_, _ = packet.Write(queryRequestHeader)
-
-	// Write packet type
-	_ = packet.WriteByte(queryPacketTypeHandshake)
-
-	// Write session ID
-	_ = binary.Write(&packet, binary.BigEndian, sessionID)
-
-	_, err := packet.WriteTo(conn)
+func (p *Pinger) writeQueryHandshakePacket(conn net.Conn, sessionID int32) error {
+	_, err := conn.Write(queryHandshakePacket(sessionID))
 	return err
 }
 
-func (p *Pinger) readQueryHandshakeResponsePacket(conn *net.UDPConn, sessionID int32) (io.Reader, error) {
-	// Read UDP packet into 1024 byte buffer and create a reader
-	// of it to read data sequentially.
-	b := make([]byte, 1024)
-	n, _, err := conn.ReadFromUDP(b)
-	if err != nil {
-		return nil, err
-	}
-	reader := bytes.NewReader(b[:n])
-
-	// Read packet type, which must be handshake
-	id, err := reader.ReadByte()
-	if err != nil {
-		return nil, err
-	} else if id != queryPacketTypeHandshake {
-		return nil, fmt.Errorf("expected packet ID %#x, but instead got %#x", queryPacketTypeHandshake, id)
-	}
-
-	// Read session ID from response, return an error if it's
-	// not the one in request.
-	var resSessionID int32
-	if err = binary.Read(reader, binary.BigEndian, &resSessionID); err != nil {
-		return nil, err
-	} else if resSessionID != sessionID {
-		return nil, fmt.Errorf("expected session ID %#x, but instead got %#x", sessionID, resSessionID)
-	}
-
-	return reader, nil
+func queryHandshakePacket(sessionID int32) []byte {
+	return protocol.EncodeQueryRequest(&protocol.QueryRequest{
+		Type:      protocol.QueryPacketTypeHandshake,
+		SessionID: sessionID,
+	})
 }
 
-func (p *Pinger) writeQueryBasicStatPacket(conn *net.UDPConn, sessionID int32, token int32) error {
-	var packet bytes.Buffer
-
-	// Write request packet header
-	_, _ = packet.Write(queryRequestHeader)
+func (p *Pinger) readQueryHandshakeResponsePacket(conn net.Conn, sessionID int32) ([]byte, error) {
+	return p.readQueryResponsePacket(conn, protocol.QueryPacketTypeHandshake, sessionID)
+}
 
-	// Write packet type
-	_ = packet.WriteByte(queryPacketTypeStat)
+func (p *Pinger) writeQueryBasicStatPacket(conn net.Conn, sessionID int32, token int32) error {
+	_, err := conn.Write(queryStatPacket(sessionID, token, false))
+	return err
+}
 
-	// Write session ID
-	_ = binary.Write(&packet, binary.BigEndian, sessionID)
+func queryStatPacket(sessionID int32, token int32, full bool) []byte {
+	return protocol.EncodeQueryRequest(&protocol.QueryRequest{
+		Type:      protocol.QueryPacketTypeStat,
+		SessionID: sessionID,
+		Token:     token,
+		Full:      full,
+	})
+}
 
-	// Write token
-	_ = binary.Write(&packet, binary.BigEndian, token)
+func (p *Pinger) readQueryStatResponsePacket(conn net.Conn, sessionID int32) ([]byte, error) {
+	return p.readQueryResponsePacket(conn, protocol.QueryPacketTypeStat, sessionID)
+}
 
-	_, err := packet.WriteTo(conn)
+func (p *Pinger) writeQueryFullStatPacket(conn net.Conn, sessionID int32, token int32) error {
+	_, err := conn.Write(queryStatPacket(sessionID, token, true))
 	return err
 }
 
-func (p *Pinger) readQueryStatResponsePacket(conn *net.UDPConn, sessionID int32) (io.Reader, error) {
-	// Read UDP packet into 1024 byte buffer and create a reader
-	// of it to read data sequentially.
-	b := make([]byte, 1024)
-	n, _, err := conn.ReadFromUDP(b)
+func (p *Pinger) readQueryResponsePacket(conn net.Conn, packetType byte, sessionID int32) ([]byte, error) {
+	// Read UDP packet and decode its header
+	b, err := p.readDatagram(conn)
 	if err != nil {
 		return nil, err
 	}
-	reader := bytes.NewReader(b[:n])
-
-	// Read packet type, which must be stat
-	id, err := reader.ReadByte()
+	res, err := protocol.DecodeQueryResponse(b)
 	if err != nil {
 		return nil, err
-	} else if id != queryPacketTypeStat {
-		return nil, fmt.Errorf("expected packet ID %#x, but instead got %#x", queryPacketTypeStat, id)
 	}
 
-	// Read session ID from response, return an error if it's
-	// not the one in request.
-	var resSessionID int32
-	if err = binary.Read(reader, binary.BigEndian, &resSessionID); err != nil {
-		return nil, err
-	} else if resSessionID != sessionID {
-		return nil, fmt.Errorf("expected session ID %#x, but instead got %#x", sessionID, resSessionID)
+	// Ensure packet type and session ID are the ones in request
+	if res.Type != packetType {
+		return nil, fmt.Errorf("expected packet ID %#x, but instead got %#x", packetType, res.Type)
+	} else if res.SessionID != sessionID {
+		return nil, fmt.Errorf("expected session ID %#x, but instead got %#x", sessionID, res.SessionID)
 	}
 
-	return reader, nil
-}
-
-func (p *Pinger) writeQueryFullStatPacket(conn *net.UDPConn, sessionID int32, token int32) error {
-	var packet bytes.Buffer
-
-	// Write request packet header
-	_, _ = packet.Write(queryRequestHeader)
-
-	// Write packet type
-	_ = packet.WriteByte(queryPacketTypeStat)
-
-	// Write session ID
-	_ = binary.Write(&packet, binary.BigEndian, sessionID)
-
-	// Write token
-	_ = binary.Write(&packet, binary.BigEndian, token)
-
-	// Write padding
-	_, _ = packet.Write(queryFullStatPadding)
-
-	_, err := packet.WriteTo(conn)
-	return err
+	return res.Body, nil
 }
 
 // Response processing
 
-func (p *Pinger) parseQueryHandshakeResponse(reader io.Reader) (int32, error) {
-	// Read all the remaining data in packet and ensure it has NUL terminator (if UseStrict)
-	token, _ := readAll(reader)
-	if len(token) == 0 {
-		return 0, fmt.Errorf("challenge token is empty")
-	}
-	if bytes.HasSuffix(token, queryResponseStringTerminator) {
-		token = token[:len(token)-1]
-	} else if p.UseStrict {
-		return 0, fmt.Errorf("challenge token did not end with NUL byte")
-	}
-
-	// Parse token string to int
-	tokenInt, err := strconv.ParseInt(string(token), 10, 32)
+func (p *Pinger) parseQueryHandshakeResponse(body []byte) (int32, error) {
+	// Ensure body has NUL terminator (if UseStrict) and parse challenge token from it
+	token, err := protocol.DecodeQueryToken(body, p.UseStrict)
 	if err != nil {
-		return 0, err
+		return 0, invalidStatus(err)
 	}
-
-	return int32(tokenInt), nil
+	return token, nil
 }
 
-func (p *Pinger) parseQueryBasicStatResponse(reader io.Reader) (*BasicQueryStatus, error) {
-	// Read all the remaining data and ensure it has NUL terminator (if UseStrict)
-	data, _ := readAll(reader)
-	if len(data) == 0 {
-		return nil, fmt.Errorf("%w: empty response body", ErrInvalidStatus)
-	}
-	if bytes.HasSuffix(data, queryResponseStringTerminator) {
-		data = data[:len(data)-len(queryResponseStringTerminator)]
-	} else if p.UseStrict {
-		return nil, fmt.Errorf("%w: response body is not NUL-termianted", ErrInvalidStatus)
-	}
-
-	// Split response string by NUL bytes (into 6 substrings, because 6th also contains port and hostname
-	// that need to be parsed specially).
-	fields := strings.SplitN(string(data), string(queryResponseStringTerminator), 6)
-	if len(fields) != 6 {
-		return nil, fmt.Errorf("%w: expected 5 first string fields in response body, got %#v", ErrInvalidStatus, len(fields)-1)
-	}
-	motd, gameType, mapName, onlinePlayersStr, maxPlayerStr := fields[0], fields[1], fields[2], fields[3], fields[4]
-
-	// Ensure gametype is indeed a hardcoded SMP string
-	if gameType != queryGameType && p.UseStrict {
-		return nil, fmt.Errorf("%w: expected gametype field to be %#v, got %#v", ErrInvalidStatus, queryGameType, gameType)
-	}
-
-	// Parse online players integer
-	onlinePlayers, err := strconv.ParseInt(onlinePlayersStr, 10, 32)
-	if err != nil {
-		return nil, fmt.Errorf("%w: could not parse online players count: %s", ErrInvalidStatus, err)
-	}
-
-	// Parse max players integer
-	maxPlayers, err := strconv.ParseInt(maxPlayerStr, 10, 32)
+func (p *Pinger) parseQueryBasicStatResponse(body []byte) (*BasicQueryStatus, error) {
+	// Ensure body has NUL terminator (if UseStrict) and split it into fields
+	res, err := protocol.DecodeQueryBasicStat(body, p.UseStrict)
 	if err != nil {
-		return nil, fmt.Errorf("%w: could not parse max players count: %s", ErrInvalidStatus, err)
+		return nil, invalidStatus(err)
 	}
 
-	// Create a reader for remaining data to read it sequentially (port + hostname)
-	remReader := bytes.NewReader([]byte(fields[5]))
-
-	// Unpack port as short integer (little-endian)
-	var port int16
-	if err = binary.Read(remReader, binary.LittleEndian, &port); err != nil {
-		return nil, err
+	// Ensure gametype is indeed a hardcoded SMP string
+	if res.GameType != queryGameType && p.UseStrict {
+		return nil, fmt.Errorf("%w: expected gametype field to be %#v, got %#v", ErrInvalidStatus, queryGameType, res.GameType)
 	}
 
-	// Read host as byte sequence
-	hostBytes, err := readAll(remReader)
-	if err != nil {
-		return nil, err
-	}
 	return &BasicQueryStatus{
-		MOTD:          motd,
-		GameType:      gameType,
-		Map:           mapName,
-		OnlinePlayers: int(onlinePlayers),
-		MaxPlayers:    int(maxPlayers),
-		Port:          int(port),
-		Host:          string(hostBytes),
+		MOTD:          res.MOTD,
+		GameType:      res.GameType,
+		Map:           res.Map,
+		OnlinePlayers: res.OnlinePlayers,
+		MaxPlayers:    res.MaxPlayers,
+		Port:          int(res.Port),
+		Host:          res.Host,
 	}, nil
 }
 
-func (p *Pinger) parseQueryFullStatResponse(reader io.Reader) (*FullQueryStatus, error) {
-	// Read all the remaining data and ensure it has NUL terminator (if UseStrict)
-	data, _ := readAll(reader)
-	if len(data) == 0 {
-		return nil, fmt.Errorf("%w: empty response body", ErrInvalidStatus)
-	}
-	if bytes.HasSuffix(data, queryResponseStringTerminator) {
-		data = data[:len(data)-len(queryResponseStringTerminator)]
-	} else if p.UseStrict {
-		return nil, fmt.Errorf("%w: response body is not NUL-termianted", ErrInvalidStatus)
-	}
-	dataReader := bytes.NewReader(data)
-
-	// Read padding for Key-Value section and ensure it is equal to hardcoded value (if UseStrict)
-	pb := make([]byte, len(queryKVSectionPadding))
-	if _, err := dataReader.Read(pb); err != nil {
-		return nil, err
-	} else if !bytes.Equal(pb, queryKVSectionPadding) && p.UseStrict {
-		return nil, fmt.Errorf("%w: key-value section padding is invalid", ErrInvalidStatus)
-	}
-
-	// Read and parse KV map
-	fields, err := queryReadFullStatFieldMap(dataReader)
+func (p *Pinger) parseQueryFullStatResponse(body []byte) (*FullQueryStatus, error) {
+	// Ensure body has NUL terminator and valid section paddings (if UseStrict) and read its sections
+	res, err := protocol.DecodeQueryFullStat(body, p.UseStrict)
 	if err != nil {
-		return nil, err
-	}
-
-	// Read padding for players section and ensure it is also hardcoded (if UseStrict)
-	pb = make([]byte, len(queryPlayerSectionPadding))
-	if _, err = dataReader.Read(pb); err != nil {
-		return nil, err
-	} else if !bytes.Equal(pb, queryPlayerSectionPadding) && p.UseStrict {
-		return nil, fmt.Errorf("%w: player section padding is invalid", ErrInvalidStatus)
+		return nil, invalidStatus(err)
 	}
-
-	// Read player list
-	players, err := queryReadFullStatPlayerList(dataReader)
-	if err != nil {
-		return nil, err
+	fields := make(map[string]string, len(res.KeyValues))
+	for _, entry := range res.KeyValues {
+		fields[entry.Key] = entry.Value
 	}
 
 	// Read hostname field (MOTD)
@@ -562,7 +568,7 @@ func (p *Pinger) parseQueryFullStatResponse(reader io.Reader) (*FullQueryStatus,
 	if err != nil {
 		return nil, err
 	}
-	port, err := strconv.ParseInt(portStr, 10, 16)
+	port, err := strconv.ParseUint(portStr, 10, 16)
 	if err != nil {
 		return nil, fmt.Errorf("%w: could not parse hostport field: %s", ErrInvalidStatus, err)
 	}
@@ -583,45 +589,13 @@ func (p *Pinger) parseQueryFullStatResponse(reader io.Reader) (*FullQueryStatus,
 		Map:           mapName,
 		OnlinePlayers: int(onlinePlayers),
 		MaxPlayers:    int(maxPlayers),
-		SamplePlayers: players,
+		SamplePlayers: res.Players,
 		Port:          int(port),
 		Host:          hostname,
 		Data:          fields,
 	}, nil
 }
 
-func queryReadFullStatFieldMap(reader io.ByteReader) (map[string]string, error) {
-	fields := make(map[string]string)
-	for {
-		key, err := readAllUntilZero(reader)
-		if err != nil {
-			return nil, err
-		} else if len(key) == 0 {
-			break
-		}
-		value, err := readAllUntilZero(reader)
-		if err != nil {
-			return nil, err
-		}
-		fields[string(key)] = string(value)
-	}
-	return fields, nil
-}
-
-func queryReadFullStatPlayerList(reader io.ByteReader) ([]string, error) {
-	players := make([]string, 0, 10)
-	for {
-		nickname, err := readAllUntilZero(reader)
-		if err != nil {
-			return nil, err
-		} else if len(nickname) == 0 {
-			break
-		}
-		players = append(players, string(nickname))
-	}
-	return players, nil
-}
-
 func queryParseFullStatPluginsList(str string) (string, []FullQueryPluginEntry, error) {
 	// Split version string by color; left part is server version and brand, right part is plugins list
 	parts := strings.SplitN(str, ":", 2)
@@ -0,0 +1,479 @@
+package minequery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"net"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/dreamscached/minequery/v2/protocol"
+)
+
+const (
+	// defaultQueryTokenExpiry is the time challenge tokens are reused for by QueryEngine. Vanilla servers
+	// regenerate tokens every 30 seconds (and keep previous ones for a while), so tokens are refreshed early.
+	defaultQueryTokenExpiry = 25 * time.Second
+
+	// queryEngineMaxPacketLength is the size of buffer datagrams are read into by QueryEngine.
+	queryEngineMaxPacketLength = 65535
+
+	// defaultQueryHandshakeTimeout limits shared handshakes if neither Pinger Timeout nor context
+	// deadline of query that started handshake is set, so that handshake with silent server can't hang forever.
+	defaultQueryHandshakeTimeout = 5 * time.Second
+
+	// queryRetransmits is the number of times handshake and stat requests are retransmitted within
+	// their timeout if server does not respond, since UDP datagrams may be lost.
+	queryRetransmits = 2
+
+	// queryEngineSessionIDAttempts is the number of random session IDs tried before giving up
+	// on finding one not used by other pending requests to the same server.
+	queryEngineSessionIDAttempts = 16
+
+	// queryEngineReadBuffer is the receive buffer size requested for sockets created by NewQueryEngine.
+	// Operating system may limit it (for example, with net.core.rmem_max sysctl on Linux.)
+	queryEngineReadBuffer = 4 << 20
+)
+
+// ErrQueryEngineClosed is returned by QueryEngine functions after QueryEngine is closed.
+var ErrQueryEngineClosed = errors.New("query engine is closed")
+
+// ErrQuerySessionIDsExhausted is returned by QueryEngine functions if no session ID not used by other
+// pending requests to the same server could be found.
+var ErrQuerySessionIDsExhausted = errors.New("no free query session ID")
+
+// QueryEngine queries servers through a fixed set of shared UDP sockets instead of opening a new socket
+// per query, which avoids exhausting ephemeral ports and rebinding cached local addresses when many servers
+// are queried concurrently. Responses are demultiplexed by server address, packet type and session ID,
+// and challenge tokens are managed per server internally.
+//
+// QueryEngine is safe for concurrent use. It can be used directly, or set as Pinger QueryEngine
+// (see WithQueryEngine) to route Pinger Query* functions through it.
+type QueryEngine struct {
+	// Pinger provides timeout, resolver and response parsing options (such as UseStrict) of queries
+	// made with QueryEngine functions. By default (if nil), the default Pinger is used.
+	Pinger *Pinger
+
+	// TokenExpiry is the time challenge token of server is reused for.
+	// By default (if zero), it is 25 seconds.
+	TokenExpiry time.Duration
+
+	conns []net.PacketConn
+
+	mu        sync.Mutex
+	pending   map[queryEngineKey]chan []byte
+	tokens    map[string]queryEngineToken
+	closed    bool
+	handshake flightGroup
+	wg        sync.WaitGroup
+}
+
+// queryEngineKey identifies a pending request by server address, packet type and session ID.
+type queryEngineKey struct {
+	addr       string
+	packetType byte
+	sessionID  int32
+}
+
+type queryEngineToken struct {
+	token    int32
+	obtained time.Time
+}
+
+// NewQueryEngine constructs new QueryEngine instance that listens on the given number of UDP sockets
+// bound to random ports. If pinger is nil, the default Pinger is used. Zero or negative sockets means one socket.
+//
+//goland:noinspection GoUnusedExportedFunction
+func NewQueryEngine(pinger *Pinger, sockets int) (*QueryEngine, error) {
+	if sockets <= 0 {
+		sockets = 1
+	}
+
+	conns := make([]net.PacketConn, 0, sockets)
+	for i := 0; i < sockets; i++ {
+		conn, err := net.ListenPacket("udp", ":0")
+		if err != nil {
+			for _, conn := range conns {
+				_ = conn.Close()
+			}
+			return nil, err
+		}
+		// Bursts of responses to concurrent queries easily overflow the default receive buffer
+		if udpConn, ok := conn.(*net.UDPConn); ok {
+			_ = udpConn.SetReadBuffer(queryEngineReadBuffer)
+		}
+		conns = append(conns, conn)
+	}
+	return NewQueryEngineWithConns(pinger, conns...)
+}
+
+// NewQueryEngineWithConns constructs new QueryEngine instance that sends and receives queries
+// through conns, which are closed when QueryEngine is closed. If pinger is nil, the default Pinger is used.
+// At least one connection must be provided, otherwise an error is returned.
+//
+//goland:noinspection GoUnusedExportedFunction
+func NewQueryEngineWithConns(pinger *Pinger, conns ...net.PacketConn) (*QueryEngine, error) {
+	if len(conns) == 0 {
+		return nil, errors.New("query engine requires at least one connection")
+	}
+
+	e := &QueryEngine{
+		Pinger:  pinger,
+		conns:   conns,
+		pending: make(map[queryEngineKey]chan []byte),
+		tokens:  make(map[string]queryEngineToken),
+	}
+	e.wg.Add(len(conns))
+	for _, conn := range conns {
+		go e.readLoop(conn)
+	}
+	return e, nil
+}
+
+// QueryBasic queries Minecraft servers and returns simplified query response.
+func (e *QueryEngine) QueryBasic(host string, port int) (*BasicQueryStatus, error) {
+	return e.QueryBasicContext(context.Background(), host, port)
+}
+
+// QueryBasicContext queries Minecraft servers and returns simplified query response, using the provided context
+// for cancellation and deadlines.
+func (e *QueryEngine) QueryBasicContext(ctx context.Context, host string, port int) (*BasicQueryStatus, error) {
+	return e.queryBasic(ctx, e.pinger(), host, port)
+}
+
+// QueryFull queries Minecraft servers and returns full query response.
+func (e *QueryEngine) QueryFull(host string, port int) (*FullQueryStatus, error) {
+	return e.QueryFullContext(context.Background(), host, port)
+}
+
+// QueryFullContext queries Minecraft servers and returns full query response, using the provided context
+// for cancellation and deadlines.
+func (e *QueryEngine) QueryFullContext(ctx context.Context, host string, port int) (*FullQueryStatus, error) {
+	return e.queryFull(ctx, e.pinger(), host, port)
+}
+
+// Close closes sockets of QueryEngine and fails all pending queries with ErrQueryEngineClosed.
+func (e *QueryEngine) Close() error {
+	e.mu.Lock()
+	if e.closed {
+		e.mu.Unlock()
+		return nil
+	}
+	e.closed = true
+	for key, ch := range e.pending {
+		close(ch)
+		delete(e.pending, key)
+	}
+	e.mu.Unlock()
+
+	var err error
+	for _, conn := range e.conns {
+		if closeErr := conn.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+	e.wg.Wait()
+	return err
+}
+
+func (e *QueryEngine) isClosed() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.closed
+}
+
+func (e *QueryEngine) pinger() *Pinger {
+	if e.Pinger == nil {
+		return defaultPinger
+	}
+	return e.Pinger
+}
+
+func (e *QueryEngine) queryBasic(ctx context.Context, p *Pinger, host string, port int) (*BasicQueryStatus, error) {
+	body, err := e.stat(ctx, p, host, port, false)
+	if err != nil {
+		return nil, err
+	}
+	return p.parseQueryBasicStatResponse(body)
+}
+
+func (e *QueryEngine) queryFull(ctx context.Context, p *Pinger, host string, port int) (*FullQueryStatus, error) {
+	body, err := e.stat(ctx, p, host, port, true)
+	if err != nil {
+		return nil, err
+	}
+	return p.parseQueryFullStatResponse(body)
+}
+
+// stat obtains challenge token of server and requests its basic or full stat, returning response body.
+func (e *QueryEngine) stat(ctx context.Context, p *Pinger, host string, port int, full bool) ([]byte, error) {
+	if p.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.Timeout)
+		defer cancel()
+	}
+
+	addr, err := e.resolve(ctx, p, host, port)
+	if err != nil {
+		return nil, queryContextError(ctx, err)
+	}
+	conn := e.connOf(addr)
+
+	token, err := e.token(ctx, p, conn, addr)
+	if err != nil {
+		return nil, queryContextError(ctx, err)
+	}
+
+	// Stat requests are retransmitted the same way handshakes are: token stays valid, and server answers
+	// every copy of request, while responses to copies other than the first one are ignored
+	timeout := defaultQueryHandshakeTimeout
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout = time.Until(deadline)
+	}
+	body, err := e.exchange(ctx, conn, addr, protocol.QueryPacketTypeStat, func(sessionID int32) []byte {
+		return queryStatPacket(sessionID, token, full)
+	}, queryRetransmitInterval(timeout))
+	if err != nil {
+		// Servers silently ignore requests with invalid tokens, so token is refreshed on next query
+		e.invalidateToken(addr)
+		return nil, queryContextError(ctx, err)
+	}
+
+	// Datagrams are read into a buffer of maximum size, so Pinger limit is checked on delivered response
+	if err = checkLimit("MaxDatagramSize", int64(5+len(body)), p.limits().MaxDatagramSize); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// resolve resolves host with Pinger Resolver (or net.DefaultResolver) into UDP address. The first
+// address socket of which can reach it (see queryEngineCanReach) is preferred.
+func (e *QueryEngine) resolve(ctx context.Context, p *Pinger, host string, port int) (*net.UDPAddr, error) {
+	if ip := net.ParseIP(host); ip != nil {
+		return &net.UDPAddr{IP: ip, Port: port}, nil
+	}
+
+	addrs, err := p.lookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	if len(addrs) == 0 {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	for _, addr := range addrs {
+		udpAddr := &net.UDPAddr{IP: addr.IP, Port: port, Zone: addr.Zone}
+		if queryEngineCanReach(e.connOf(udpAddr), udpAddr) {
+			return udpAddr, nil
+		}
+	}
+	return &net.UDPAddr{IP: addrs[0].IP, Port: port, Zone: addrs[0].Zone}, nil
+}
+
+// queryEngineCanReach reports whether conn can send datagrams to addr: sockets bound to IPv4 address
+// can only reach IPv4 addresses and vice versa, while sockets bound to IPv6 wildcard address are dual-stack.
+func queryEngineCanReach(conn net.PacketConn, addr *net.UDPAddr) bool {
+	local, ok := conn.LocalAddr().(*net.UDPAddr)
+	if !ok || local.IP == nil || (local.IP.IsUnspecified() && local.IP.To4() == nil) {
+		return true
+	}
+	return (local.IP.To4() != nil) == (addr.IP.To4() != nil)
+}
+
+// connOf returns socket queries to addr are sent from. The same socket is always used for the same
+// server, since challenge tokens are bound to client address.
+func (e *QueryEngine) connOf(addr *net.UDPAddr) net.PacketConn {
+	hash := fnv.New32a()
+	_, _ = hash.Write([]byte(queryEngineAddrKey(addr)))
+	return e.conns[hash.Sum32()%uint32(len(e.conns))]
+}
+
+// token returns cached challenge token of server at addr, or performs handshake to obtain a new one.
+// Concurrent handshakes with the same server are shared.
+func (e *QueryEngine) token(ctx context.Context, p *Pinger, conn net.PacketConn, addr *net.UDPAddr) (int32, error) {
+	key := queryEngineAddrKey(addr)
+	expiry := e.TokenExpiry
+	if expiry <= 0 {
+		expiry = defaultQueryTokenExpiry
+	}
+
+	e.mu.Lock()
+	cached, ok := e.tokens[key]
+	e.mu.Unlock()
+	if ok && time.Since(cached.obtained) < expiry {
+		return cached.token, nil
+	}
+
+	// Shared handshake is limited by Pinger timeout (or deadline of ctx that started it, or the default
+	// timeout) rather than ctx of any of its callers
+	timeout := p.Timeout
+	if timeout <= 0 {
+		timeout = defaultQueryHandshakeTimeout
+		if deadline, ok := ctx.Deadline(); ok {
+			timeout = time.Until(deadline)
+		}
+	}
+
+	token, err := e.handshake.Do(ctx, key, func() (interface{}, error) {
+		handshakeCtx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+
+		body, err := e.exchange(
+			handshakeCtx, conn, addr, protocol.QueryPacketTypeHandshake, queryHandshakePacket, queryRetransmitInterval(timeout),
+		)
+		if err != nil {
+			return nil, err
+		}
+		token, err := p.parseQueryHandshakeResponse(body)
+		if err != nil {
+			return nil, err
+		}
+
+		e.mu.Lock()
+		e.tokens[key] = queryEngineToken{token: token, obtained: time.Now()}
+		e.mu.Unlock()
+		return token, nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return token.(int32), nil
+}
+
+func (e *QueryEngine) invalidateToken(addr *net.UDPAddr) {
+	e.mu.Lock()
+	delete(e.tokens, queryEngineAddrKey(addr))
+	e.mu.Unlock()
+}
+
+// exchange sends packet built for a new session ID to addr and waits for response of packetType
+// with the same session ID, returning response body following packet type and session ID.
+// If retransmit is positive, packet is sent again every retransmit interval until response arrives.
+func (e *QueryEngine) exchange(
+	ctx context.Context, conn net.PacketConn, addr *net.UDPAddr, packetType byte, packet func(sessionID int32) []byte,
+	retransmit time.Duration,
+) ([]byte, error) {
+	key, response, err := e.register(addr, packetType)
+	if err != nil {
+		return nil, err
+	}
+	defer e.unregister(key)
+
+	request := packet(key.sessionID)
+	if _, err = conn.WriteTo(request, addr); err != nil {
+		return nil, err
+	}
+
+	var retransmitC <-chan time.Time
+	if retransmit > 0 {
+		ticker := time.NewTicker(retransmit)
+		defer ticker.Stop()
+		retransmitC = ticker.C
+	}
+
+	for {
+		select {
+		case body, ok := <-response:
+			if !ok {
+				return nil, ErrQueryEngineClosed
+			}
+			return body, nil
+		case <-retransmitC:
+			if _, err = conn.WriteTo(request, addr); err != nil {
+				return nil, err
+			}
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// register allocates session ID not used by other pending requests to addr and registers a request.
+func (e *QueryEngine) register(addr *net.UDPAddr, packetType byte) (queryEngineKey, chan []byte, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.closed {
+		return queryEngineKey{}, nil, ErrQueryEngineClosed
+	}
+
+	// Session IDs are random (see generateSessionID), so that responses can't be spoofed by guessing them
+	key := queryEngineKey{addr: queryEngineAddrKey(addr), packetType: packetType}
+	for attempt := 0; attempt < queryEngineSessionIDAttempts; attempt++ {
+		key.sessionID = generateSessionID()
+		if _, ok := e.pending[key]; ok {
+			continue
+		}
+
+		response := make(chan []byte, 1)
+		e.pending[key] = response
+		return key, response, nil
+	}
+	return queryEngineKey{}, nil, fmt.Errorf("%w: %s", ErrQuerySessionIDsExhausted, key.addr)
+}
+
+func (e *QueryEngine) unregister(key queryEngineKey) {
+	e.mu.Lock()
+	delete(e.pending, key)
+	e.mu.Unlock()
+}
+
+// readLoop reads datagrams from conn and delivers them to pending requests until conn is closed.
+func (e *QueryEngine) readLoop(conn net.PacketConn) {
+	defer e.wg.Done()
+
+	b := make([]byte, queryEngineMaxPacketLength)
+	for {
+		n, from, err := conn.ReadFrom(b)
+		if err != nil {
+			// Socket is closed by Close (net.ErrClosed can't be matched before Go 1.16)
+			if e.isClosed() {
+				return
+			}
+			// Timeouts and ICMP errors some platforms report on reads of unconnected sockets don't break socket
+			var netErr net.Error
+			if (errors.As(err, &netErr) && netErr.Timeout()) ||
+				errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) {
+				continue
+			}
+			return
+		}
+
+		// Responses start with packet type and session ID; anything else is not a response to us
+		udpAddr, ok := from.(*net.UDPAddr)
+		if !ok {
+			continue
+		}
+		res, err := protocol.DecodeQueryResponse(b[:n])
+		if err != nil {
+			continue
+		}
+		key := queryEngineKey{
+			addr:       queryEngineAddrKey(udpAddr),
+			packetType: res.Type,
+			sessionID:  res.SessionID,
+		}
+
+		e.mu.Lock()
+		response, ok := e.pending[key]
+		if ok {
+			delete(e.pending, key)
+			response <- append([]byte(nil), res.Body...)
+		}
+		e.mu.Unlock()
+	}
+}
+
+// queryEngineAddrKey returns address key requests and tokens are tracked by. IPv4-mapped
+// IPv6 addresses are formatted as IPv4, so that addresses from dual-stack sockets match.
+func queryEngineAddrKey(addr *net.UDPAddr) string {
+	return net.JoinHostPort(addr.IP.String(), strconv.Itoa(addr.Port))
+}
+
+// queryRetransmitInterval returns interval requests are retransmitted at, so that they are sent
+// queryRetransmits more times within timeout.
+func queryRetransmitInterval(timeout time.Duration) time.Duration {
+	return timeout / (queryRetransmits + 1)
+}
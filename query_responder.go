@@ -0,0 +1,352 @@
+package minequery
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dreamscached/minequery/v2/protocol"
+)
+
+const (
+	queryMaxRequestLength  = 1500
+	queryTokenMask         = 0x7fffffff
+	queryTokenSecretLength = 32
+)
+
+const queryResponderDefaultTokenRotation = 30 * time.Second
+
+// QueryRequest holds information about incoming stat request served by QueryResponder.
+type QueryRequest struct {
+	// Full tells if client requested full stat (as opposed to basic stat.)
+	Full bool
+
+	// SessionID is the session ID client sent in request.
+	SessionID int32
+
+	// RemoteAddr is the network address of client.
+	RemoteAddr net.Addr
+}
+
+// QueryHandler provides query status responses for QueryResponder.
+//
+// Full stat response is built from FullQueryStatus returned by handler, basic stat response is built from
+// the same fields shared with BasicQueryStatus. Empty GameType, GameID, Host fields and zero Port field are
+// filled with defaults (SMP, MINECRAFT and local address responder listens on.) Returning an error
+// leaves the request without response.
+type QueryHandler interface {
+	ServeQuery(ctx context.Context, req *QueryRequest) (*FullQueryStatus, error)
+}
+
+// QueryHandlerFunc is a function adapter for QueryHandler interface.
+type QueryHandlerFunc func(ctx context.Context, req *QueryRequest) (*FullQueryStatus, error)
+
+// ServeQuery calls f(ctx, req).
+func (f QueryHandlerFunc) ServeQuery(ctx context.Context, req *QueryRequest) (*FullQueryStatus, error) {
+	return f(ctx, req)
+}
+
+// StaticQueryHandler returns QueryHandler that responds with the same status to every request.
+//
+//goland:noinspection GoUnusedExportedFunction
+func StaticQueryHandler(status *FullQueryStatus) QueryHandler {
+	return QueryHandlerFunc(func(context.Context, *QueryRequest) (*FullQueryStatus, error) { return status, nil })
+}
+
+// QueryResponder answers Query protocol requests with responses provided by QueryHandler. It is the
+// server-side counterpart of Pinger Query* functions and can be used to expose Query for servers
+// and proxies that don't support it natively.
+//
+// Like vanilla server, QueryResponder hands out challenge tokens bound to client address, which are
+// only accepted until they rotate; malformed requests and requests with invalid tokens are ignored.
+type QueryResponder struct {
+	// Handler provides query status responses for incoming requests.
+	Handler QueryHandler
+
+	// Timeout limits the time a single request is handled for.
+	// By default, it is 15 seconds.
+	Timeout time.Duration
+
+	// TokenRotation is the interval challenge tokens rotate at. Token stays valid for at least this
+	// long and at most twice as long after it was handed out.
+	// By default, it is 30 seconds.
+	TokenRotation time.Duration
+
+	state responderState
+
+	tokenMu      sync.Mutex
+	tokenSecrets [2][]byte
+	rotatedAt    time.Time
+}
+
+// NewQueryResponder constructs new QueryResponder instance with default parameters.
+//
+//goland:noinspection GoUnusedExportedFunction
+func NewQueryResponder(handler QueryHandler) *QueryResponder {
+	return &QueryResponder{
+		Handler:       handler,
+		Timeout:       responderDefaultTimeout,
+		TokenRotation: queryResponderDefaultTokenRotation,
+	}
+}
+
+// ListenAndServe listens on UDP network address addr and serves incoming requests until Close is called.
+func (r *QueryResponder) ListenAndServe(addr string) error {
+	conn, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return err
+	}
+	return r.Serve(conn)
+}
+
+// Serve reads incoming requests from conn and serves each of them in a new goroutine
+// until Close is called. Connection is closed when Serve returns.
+func (r *QueryResponder) Serve(conn net.PacketConn) error {
+	if !r.state.track(conn) {
+		_ = conn.Close()
+		return ErrResponderClosed
+	}
+	defer r.state.untrack(conn)
+
+	b := make([]byte, queryMaxRequestLength)
+	for {
+		n, addr, err := conn.ReadFrom(b)
+		if err != nil {
+			if r.state.isClosed() {
+				return ErrResponderClosed
+			}
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				continue
+			}
+			return err
+		}
+
+		packet := make([]byte, n)
+		copy(packet, b[:n])
+		go r.servePacket(conn, addr, packet)
+	}
+}
+
+// Close stops all Serve calls, closing their connections, and cancels context of requests being served.
+func (r *QueryResponder) Close() error {
+	return r.state.close()
+}
+
+func (r *QueryResponder) servePacket(conn net.PacketConn, addr net.Addr, packet []byte) {
+	// Ensure packet is a valid handshake or stat request
+	request, err := protocol.DecodeQueryRequest(packet)
+	if err != nil {
+		return
+	}
+
+	var response []byte
+	switch request.Type {
+	case protocol.QueryPacketTypeHandshake:
+		response = queryResponderHandshakeResponse(request.SessionID, r.token(addr))
+
+	case protocol.QueryPacketTypeStat:
+		// Ensure token is the one handed out to this address
+		if !r.validToken(addr, request.Token) {
+			return
+		}
+
+		req := &QueryRequest{Full: request.Full, SessionID: request.SessionID, RemoteAddr: addr}
+		status, err := r.status(req)
+		if err != nil {
+			return
+		}
+		status = queryResponderFillDefaults(status, conn.LocalAddr())
+
+		if req.Full {
+			response = queryResponderFullStatResponse(request.SessionID, status)
+		} else {
+			response = queryResponderBasicStatResponse(request.SessionID, status)
+		}
+
+	default:
+		return
+	}
+
+	_, _ = conn.WriteTo(response, addr)
+}
+
+func (r *QueryResponder) status(req *QueryRequest) (*FullQueryStatus, error) {
+	if r.Handler == nil {
+		return nil, errors.New("query handler is not set")
+	}
+
+	ctx, cancel := r.state.requestContext(r.Timeout)
+	defer cancel()
+
+	status, err := r.Handler.ServeQuery(ctx, req)
+	if err != nil {
+		return nil, err
+	} else if status == nil {
+		return nil, errors.New("query handler returned nil status")
+	}
+	return status, nil
+}
+
+// Challenge tokens
+
+// token returns challenge token for addr derived from the current secret.
+func (r *QueryResponder) token(addr net.Addr) int32 {
+	secrets := r.secrets()
+	return queryResponderToken(secrets[0], addr)
+}
+
+// validToken checks if token was derived for addr from the current or the previous secret.
+func (r *QueryResponder) validToken(addr net.Addr, token int32) bool {
+	for _, secret := range r.secrets() {
+		if secret != nil && queryResponderToken(secret, addr) == token {
+			return true
+		}
+	}
+	return false
+}
+
+// secrets returns the current and the previous token secrets, rotating them if it's time to.
+func (r *QueryResponder) secrets() [2][]byte {
+	r.tokenMu.Lock()
+	defer r.tokenMu.Unlock()
+
+	rotation := r.TokenRotation
+	if rotation <= 0 {
+		rotation = queryResponderDefaultTokenRotation
+	}
+
+	now := time.Now()
+	if elapsed := now.Sub(r.rotatedAt); r.tokenSecrets[0] == nil || elapsed >= rotation {
+		if elapsed >= 2*rotation {
+			// Previous secret has expired too
+			r.tokenSecrets[0] = nil
+		}
+		r.tokenSecrets[1] = r.tokenSecrets[0]
+		r.tokenSecrets[0] = queryResponderNewSecret()
+		r.rotatedAt = now
+	}
+	return r.tokenSecrets
+}
+
+func queryResponderNewSecret() []byte {
+	secret := make([]byte, queryTokenSecretLength)
+	if _, err := rand.Read(secret); err != nil {
+		// Fall back to time-based secret, it is still bound to rotate
+		binary.BigEndian.PutUint64(secret, uint64(time.Now().UnixNano()))
+	}
+	return secret
+}
+
+func queryResponderToken(secret []byte, addr net.Addr) int32 {
+	mac := hmac.New(sha256.New, secret)
+	_, _ = mac.Write([]byte(addr.String()))
+	return int32(binary.BigEndian.Uint32(mac.Sum(nil)) & queryTokenMask)
+}
+
+// Response building
+
+func queryResponderFillDefaults(status *FullQueryStatus, localAddr net.Addr) *FullQueryStatus {
+	filled := *status
+	if filled.GameType == "" {
+		filled.GameType = queryGameType
+	}
+	if filled.GameID == "" {
+		filled.GameID = queryGameID
+	}
+	if udpAddr, ok := localAddr.(*net.UDPAddr); ok {
+		if filled.Host == "" {
+			filled.Host = udpAddr.IP.String()
+		}
+		if filled.Port == 0 {
+			filled.Port = udpAddr.Port
+		}
+	}
+	return &filled
+}
+
+func queryResponderHandshakeResponse(sessionID int32, token int32) []byte {
+	return protocol.EncodeQueryResponse(&protocol.QueryResponse{
+		Type:      protocol.QueryPacketTypeHandshake,
+		SessionID: sessionID,
+		Body:      protocol.EncodeQueryToken(token),
+	})
+}
+
+func queryResponderBasicStatResponse(sessionID int32, status *FullQueryStatus) []byte {
+	return protocol.EncodeQueryResponse(&protocol.QueryResponse{
+		Type:      protocol.QueryPacketTypeStat,
+		SessionID: sessionID,
+		Body: protocol.EncodeQueryBasicStat(&protocol.QueryBasicStat{
+			MOTD:          status.MOTD,
+			GameType:      status.GameType,
+			Map:           status.Map,
+			OnlinePlayers: status.OnlinePlayers,
+			MaxPlayers:    status.MaxPlayers,
+			Port:          uint16(status.Port),
+			Host:          status.Host,
+		}),
+	})
+}
+
+func queryResponderFullStatResponse(sessionID int32, status *FullQueryStatus) []byte {
+	// Build Key-Value section in the same order vanilla server does, followed by additional data
+	keyValues := []protocol.QueryKeyValue{
+		{Key: "hostname", Value: status.MOTD},
+		{Key: "gametype", Value: status.GameType},
+		{Key: "game_id", Value: status.GameID},
+		{Key: "version", Value: status.Version},
+		{Key: "plugins", Value: queryResponderPluginsList(status.ServerVersion, status.Plugins)},
+		{Key: "map", Value: status.Map},
+		{Key: "numplayers", Value: strconv.Itoa(status.OnlinePlayers)},
+		{Key: "maxplayers", Value: strconv.Itoa(status.MaxPlayers)},
+		{Key: "hostport", Value: strconv.Itoa(status.Port)},
+		{Key: "hostip", Value: status.Host},
+	}
+	keys := make([]string, 0, len(status.Data))
+	for key := range status.Data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if !queryResponderIsStandardKey(key) {
+			keyValues = append(keyValues, protocol.QueryKeyValue{Key: key, Value: status.Data[key]})
+		}
+	}
+
+	return protocol.EncodeQueryResponse(&protocol.QueryResponse{
+		Type:      protocol.QueryPacketTypeStat,
+		SessionID: sessionID,
+		Body:      protocol.EncodeQueryFullStat(&protocol.QueryFullStat{KeyValues: keyValues, Players: status.SamplePlayers}),
+	})
+}
+
+func queryResponderPluginsList(serverVersion string, plugins []FullQueryPluginEntry) string {
+	if len(plugins) == 0 {
+		return serverVersion
+	}
+
+	entries := make([]string, len(plugins))
+	for i, plugin := range plugins {
+		entries[i] = plugin.Name + " " + plugin.Version
+	}
+	return serverVersion + ": " + strings.Join(entries, "; ")
+}
+
+func queryResponderIsStandardKey(key string) bool {
+	switch key {
+	case "hostname", "gametype", "game_id", "version", "plugins", "map",
+		"numplayers", "maxplayers", "hostport", "hostip":
+		return true
+	}
+	return false
+}
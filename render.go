@@ -0,0 +1,249 @@
+package minequery
+
+import (
+	"fmt"
+	"html"
+	"math/rand"
+	"strings"
+	"unicode"
+)
+
+// ObfuscatedMode defines how renderers handle text with obfuscated (§k) formatting.
+type ObfuscatedMode int
+
+//goland:noinspection GoUnusedConst
+const (
+	// ObfuscatedKeep renders obfuscated text as is.
+	ObfuscatedKeep ObfuscatedMode = iota
+
+	// ObfuscatedHide omits obfuscated text entirely.
+	ObfuscatedHide
+
+	// ObfuscatedReplace replaces every non-space character of obfuscated text with RenderOptions ObfuscatedRune.
+	ObfuscatedReplace
+
+	// ObfuscatedRandomize replaces every non-space character of obfuscated text with random printable
+	// character, the way Notchian client does it.
+	ObfuscatedRandomize
+)
+
+// ANSIColorMode defines which escape sequences RenderANSI uses for colors.
+type ANSIColorMode int
+
+//goland:noinspection GoUnusedConst
+const (
+	// ANSIColorTrueColor uses 24-bit color escape sequences.
+	ANSIColorTrueColor ANSIColorMode = iota
+
+	// ANSIColor256 uses 256-color palette escape sequences, approximating colors to the closest palette entry.
+	ANSIColor256
+)
+
+// defaultObfuscatedRune is the character used for ObfuscatedReplace if RenderOptions ObfuscatedRune is not set.
+const defaultObfuscatedRune = '*'
+
+// RenderOptions holds options of RenderANSI, RenderHTML and RenderPlainText functions.
+// Nil options are equivalent to zero value.
+type RenderOptions struct {
+	// Obfuscated defines how obfuscated text is rendered. By default, it is rendered as is.
+	Obfuscated ObfuscatedMode
+
+	// ObfuscatedRune is the character obfuscated text is replaced with in ObfuscatedReplace mode.
+	// By default, asterisk is used.
+	ObfuscatedRune rune
+
+	// ANSIColorMode defines color escape sequences used by RenderANSI. By default, 24-bit colors are used.
+	ANSIColorMode ANSIColorMode
+}
+
+// RenderANSI renders styled segments (see ParseLegacyMOTD and ChatComponent Segments) into a string
+// with ANSI escape sequences for terminal output.
+func RenderANSI(segments []ChatSegment, options *RenderOptions) string {
+	options = normalizeRenderOptions(options)
+	var builder strings.Builder
+
+	for _, segment := range segments {
+		text, ok := renderSegmentText(segment, options)
+		if !ok {
+			continue
+		}
+
+		codes := make([]string, 0, 6)
+		if r, g, b, ok := segment.Style.Color.RGB(); ok {
+			if options.ANSIColorMode == ANSIColor256 {
+				codes = append(codes, fmt.Sprintf("38;5;%d", rgbToANSI256(r, g, b)))
+			} else {
+				codes = append(codes, fmt.Sprintf("38;2;%d;%d;%d", r, g, b))
+			}
+		}
+		if segment.Style.IsBold() {
+			codes = append(codes, "1")
+		}
+		if segment.Style.IsItalic() {
+			codes = append(codes, "3")
+		}
+		if segment.Style.IsUnderlined() {
+			codes = append(codes, "4")
+		}
+		if segment.Style.IsStrikethrough() {
+			codes = append(codes, "9")
+		}
+
+		if len(codes) == 0 {
+			builder.WriteString(text)
+			continue
+		}
+		builder.WriteString("\x1b[" + strings.Join(codes, ";") + "m")
+		builder.WriteString(text)
+		builder.WriteString("\x1b[0m")
+	}
+
+	return builder.String()
+}
+
+// RenderHTML renders styled segments (see ParseLegacyMOTD and ChatComponent Segments) into HTML
+// markup of span elements with inline styles. Text is escaped and only styles produced by the
+// renderer itself are emitted, so the output is safe to embed into a page. Newlines are rendered
+// as line breaks, and hover text (if any) is rendered as title attribute.
+func RenderHTML(segments []ChatSegment, options *RenderOptions) string {
+	options = normalizeRenderOptions(options)
+	var builder strings.Builder
+
+	for _, segment := range segments {
+		text, ok := renderSegmentText(segment, options)
+		if !ok {
+			continue
+		}
+
+		styles := make([]string, 0, 4)
+		if r, g, b, ok := segment.Style.Color.RGB(); ok {
+			styles = append(styles, fmt.Sprintf("color:#%02x%02x%02x", r, g, b))
+		}
+		if segment.Style.IsBold() {
+			styles = append(styles, "font-weight:bold")
+		}
+		if segment.Style.IsItalic() {
+			styles = append(styles, "font-style:italic")
+		}
+		decorations := make([]string, 0, 2)
+		if segment.Style.IsUnderlined() {
+			decorations = append(decorations, "underline")
+		}
+		if segment.Style.IsStrikethrough() {
+			decorations = append(decorations, "line-through")
+		}
+		if len(decorations) > 0 {
+			styles = append(styles, "text-decoration:"+strings.Join(decorations, " "))
+		}
+
+		builder.WriteString("<span")
+		if len(styles) > 0 {
+			builder.WriteString(` style="` + strings.Join(styles, ";") + `"`)
+		}
+		if hover := segment.Style.HoverEvent; hover != nil && hover.Text != nil {
+			builder.WriteString(` title="` + html.EscapeString(hover.Text.String()) + `"`)
+		}
+		builder.WriteString(">")
+		builder.WriteString(strings.ReplaceAll(html.EscapeString(text), "\n", "<br>"))
+		builder.WriteString("</span>")
+	}
+
+	return builder.String()
+}
+
+// RenderPlainText renders styled segments (see ParseLegacyMOTD and ChatComponent Segments) into
+// plain text without any formatting. Unlike String functions of status responses, line breaks are preserved.
+func RenderPlainText(segments []ChatSegment, options *RenderOptions) string {
+	options = normalizeRenderOptions(options)
+	var builder strings.Builder
+
+	for _, segment := range segments {
+		if text, ok := renderSegmentText(segment, options); ok {
+			builder.WriteString(text)
+		}
+	}
+
+	return builder.String()
+}
+
+func normalizeRenderOptions(options *RenderOptions) *RenderOptions {
+	normalized := RenderOptions{}
+	if options != nil {
+		normalized = *options
+	}
+	if normalized.ObfuscatedRune == 0 {
+		normalized.ObfuscatedRune = defaultObfuscatedRune
+	}
+	return &normalized
+}
+
+// renderSegmentText returns segment text with obfuscation handling applied, returning false as
+// the last return value if segment must not be rendered at all.
+func renderSegmentText(segment ChatSegment, options *RenderOptions) (string, bool) {
+	if !segment.Style.IsObfuscated() {
+		return segment.Text, true
+	}
+
+	switch options.Obfuscated {
+	case ObfuscatedHide:
+		return "", false
+	case ObfuscatedReplace:
+		return replaceNonSpace(segment.Text, func(rune) rune { return options.ObfuscatedRune }), true
+	case ObfuscatedRandomize:
+		return replaceNonSpace(segment.Text, func(rune) rune { return rune('!' + rand.Intn('~'-'!'+1)) }), true
+	default:
+		return segment.Text, true
+	}
+}
+
+func replaceNonSpace(s string, replace func(rune) rune) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) {
+			return r
+		}
+		return replace(r)
+	}, s)
+}
+
+// rgbToANSI256 approximates color to the closest entry of xterm 256-color palette
+// (either 6x6x6 color cube or grayscale ramp.)
+func rgbToANSI256(r, g, b uint8) int {
+	cubeIndex := func(v uint8) int {
+		if v < 48 {
+			return 0
+		} else if v < 115 {
+			return 1
+		}
+		return int(v-35) / 40
+	}
+	cubeValue := func(i int) int {
+		if i == 0 {
+			return 0
+		}
+		return 55 + i*40
+	}
+	distance := func(r2, g2, b2 int) int {
+		dr, dg, db := int(r)-r2, int(g)-g2, int(b)-b2
+		return dr*dr + dg*dg + db*db
+	}
+
+	// Closest color cube entry
+	ri, gi, bi := cubeIndex(r), cubeIndex(g), cubeIndex(b)
+	cube := 16 + 36*ri + 6*gi + bi
+	cubeDistance := distance(cubeValue(ri), cubeValue(gi), cubeValue(bi))
+
+	// Closest grayscale ramp entry
+	average := (int(r) + int(g) + int(b)) / 3
+	grayIndex := 23
+	if average <= 238 {
+		grayIndex = maxInt(average-3, 0) / 10
+	}
+	grayValue := 8 + grayIndex*10
+	gray := 232 + grayIndex
+	grayDistance := distance(grayValue, grayValue, grayValue)
+
+	if grayDistance < cubeDistance {
+		return gray
+	}
+	return cube
+}
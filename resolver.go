@@ -0,0 +1,173 @@
+package minequery
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/patrickmn/go-cache"
+)
+
+// Resolver looks up DNS records Pinger uses to reach servers: SRV records of hostnames and
+// their A/AAAA records. *net.Resolver satisfies this interface.
+type Resolver interface {
+	// LookupSRV looks up SRV records of _service._proto.name, see net.Resolver LookupSRV.
+	LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error)
+
+	// LookupIPAddr looks up IPv4 and IPv6 addresses of host, see net.Resolver LookupIPAddr.
+	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+}
+
+// TTLResolver is an optional interface of Resolver reporting TTLs of the records it looks up.
+// CachingResolver caches results of TTLResolver for the reported TTL. DNSResolver implements it,
+// while *net.Resolver does not (it doesn't expose record TTLs.)
+type TTLResolver interface {
+	Resolver
+
+	// LookupSRVTTL is the same as LookupSRV, but also returns TTL of the records.
+	LookupSRVTTL(ctx context.Context, service, proto, name string) (string, []*net.SRV, time.Duration, error)
+
+	// LookupIPAddrTTL is the same as LookupIPAddr, but also returns TTL of the records.
+	LookupIPAddrTTL(ctx context.Context, host string) ([]net.IPAddr, time.Duration, error)
+}
+
+const (
+	defaultResolverCacheTTL    = time.Minute
+	resolverCachePurgeInterval = 5 * time.Minute
+)
+
+// CachingResolver is a Resolver that caches results of another Resolver in process memory.
+// Results of TTLResolver (such as DNSResolver) are cached for TTL of the records, while results
+// of other resolvers (including the default one, which can't report TTLs) are cached for fixed TTL.
+// Zero value is ready to use, and it is safe for concurrent use.
+type CachingResolver struct {
+	// Resolver is the resolver results of which are cached.
+	// By default (if nil), net.DefaultResolver is used.
+	Resolver Resolver
+
+	// TTL is the time results are cached for if Resolver doesn't report TTLs (see TTLResolver.)
+	// By default, it is 1 minute.
+	TTL time.Duration
+
+	// NegativeTTL is the time "not found" results are cached for. By default (if zero),
+	// they are not cached.
+	NegativeTTL time.Duration
+
+	once  sync.Once
+	cache *cache.Cache
+}
+
+// resolverCacheEntry holds a single cached lookup result.
+type resolverCacheEntry struct {
+	cname   string
+	records []*net.SRV
+	addrs   []net.IPAddr
+	err     error
+}
+
+// NewCachingResolver constructs new CachingResolver instance caching results of resolver for record TTLs
+// if resolver is TTLResolver, or for ttl otherwise. If resolver is nil, net.DefaultResolver is used.
+//
+//goland:noinspection GoUnusedExportedFunction
+func NewCachingResolver(resolver Resolver, ttl time.Duration) *CachingResolver {
+	return &CachingResolver{Resolver: resolver, TTL: ttl}
+}
+
+// LookupSRV looks up SRV records of _service._proto.name, returning cached result if there is one.
+func (r *CachingResolver) LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+	key := "srv/" + service + "/" + proto + "/" + name
+	if entry, ok := r.get(key); ok {
+		return entry.cname, copySRVRecords(entry.records), entry.err
+	}
+
+	var entry resolverCacheEntry
+	ttl := time.Duration(-1)
+	if resolver, ok := r.resolver().(TTLResolver); ok {
+		entry.cname, entry.records, ttl, entry.err = resolver.LookupSRVTTL(ctx, service, proto, name)
+	} else {
+		entry.cname, entry.records, entry.err = r.resolver().LookupSRV(ctx, service, proto, name)
+	}
+	r.set(key, entry, ttl)
+	return entry.cname, copySRVRecords(entry.records), entry.err
+}
+
+// LookupIPAddr looks up IPv4 and IPv6 addresses of host, returning cached result if there is one.
+func (r *CachingResolver) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	key := "ip/" + host
+	if entry, ok := r.get(key); ok {
+		return append([]net.IPAddr(nil), entry.addrs...), entry.err
+	}
+
+	var entry resolverCacheEntry
+	ttl := time.Duration(-1)
+	if resolver, ok := r.resolver().(TTLResolver); ok {
+		entry.addrs, ttl, entry.err = resolver.LookupIPAddrTTL(ctx, host)
+	} else {
+		entry.addrs, entry.err = r.resolver().LookupIPAddr(ctx, host)
+	}
+	r.set(key, entry, ttl)
+	return append([]net.IPAddr(nil), entry.addrs...), entry.err
+}
+
+// Flush removes all cached results.
+func (r *CachingResolver) Flush() {
+	r.results().Flush()
+}
+
+// results returns cache of lookup results, creating it on first use.
+func (r *CachingResolver) results() *cache.Cache {
+	r.once.Do(func() { r.cache = cache.New(cache.NoExpiration, resolverCachePurgeInterval) })
+	return r.cache
+}
+
+func (r *CachingResolver) resolver() Resolver {
+	if r.Resolver == nil {
+		return net.DefaultResolver
+	}
+	return r.Resolver
+}
+
+func (r *CachingResolver) get(key string) (resolverCacheEntry, bool) {
+	data, hit := r.results().Get(key)
+	if !hit {
+		return resolverCacheEntry{}, false
+	}
+	return data.(resolverCacheEntry), true
+}
+
+// set caches lookup result for ttl reported by resolver, or for default TTL if ttl is negative
+// (not reported.) Only successful and "not found" results are cached, other errors are temporary.
+func (r *CachingResolver) set(key string, entry resolverCacheEntry, ttl time.Duration) {
+	if entry.err != nil {
+		var dnsError *net.DNSError
+		if r.NegativeTTL <= 0 || !errors.As(entry.err, &dnsError) || !dnsError.IsNotFound {
+			return
+		}
+		ttl = r.NegativeTTL
+	} else if ttl < 0 {
+		ttl = r.TTL
+		if ttl <= 0 {
+			ttl = defaultResolverCacheTTL
+		}
+	}
+	if ttl == 0 {
+		// Zero TTL means records must not be cached
+		return
+	}
+	r.results().Set(key, entry, ttl)
+}
+
+// copySRVRecords deep copies records, so that cached records can't be modified by callers.
+func copySRVRecords(records []*net.SRV) []*net.SRV {
+	if records == nil {
+		return nil
+	}
+	result := make([]*net.SRV, len(records))
+	for i, record := range records {
+		copied := *record
+		result[i] = &copied
+	}
+	return result
+}
@@ -0,0 +1,593 @@
+package minequery
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image/png"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/dreamscached/minequery/v2/protocol"
+)
+
+const (
+	responderMaxPacketLength   = 4096
+	responderDefaultTimeout    = 15 * time.Second
+	responderDefaultLegacyWait = 100 * time.Millisecond
+)
+
+// ErrResponderClosed is returned by Serve and ListenAndServe functions of responders after Close is called.
+var ErrResponderClosed = errors.New("responder is closed")
+
+// StatusRequest holds information about incoming status request served by StatusResponder.
+type StatusRequest struct {
+	// Protocol is the ping protocol client used.
+	Protocol PingProtocol
+
+	// ProtocolVersion is client protocol version; it is -1 for 1.4 and Beta 1.8 pings, which don't send it.
+	ProtocolVersion int
+
+	// Host and Port are server address client connected to, as sent in 1.7+ handshake or 1.6 ping;
+	// they are empty for 1.4 and Beta 1.8 pings.
+	Host string
+	Port int
+
+	// RemoteAddr is the network address of client.
+	RemoteAddr net.Addr
+}
+
+// StatusHandler provides status responses for StatusResponder.
+//
+// Status returned by handler is converted to response of protocol client used: protocol-specific
+// status (for example, Status17 for 1.7+ ping) is used as is if it is set, otherwise the response
+// is built from common Status fields. If common fields are all left zero, they are taken from the first
+// protocol-specific status that is set. Returning an error closes connection without response.
+type StatusHandler interface {
+	ServeStatus(ctx context.Context, req *StatusRequest) (*Status, error)
+}
+
+// StatusHandlerFunc is a function adapter for StatusHandler interface.
+type StatusHandlerFunc func(ctx context.Context, req *StatusRequest) (*Status, error)
+
+// ServeStatus calls f(ctx, req).
+func (f StatusHandlerFunc) ServeStatus(ctx context.Context, req *StatusRequest) (*Status, error) {
+	return f(ctx, req)
+}
+
+// StaticStatusHandler returns StatusHandler that responds with the same status to every request.
+//
+//goland:noinspection GoUnusedExportedFunction
+func StaticStatusHandler(status *Status) StatusHandler {
+	return StatusHandlerFunc(func(context.Context, *StatusRequest) (*Status, error) { return status, nil })
+}
+
+// StatusResponder answers Server List Ping requests of all versions (1.7+ including ping/pong, 1.6, 1.4
+// and Beta 1.8) with responses provided by StatusHandler. It is the server-side counterpart of Pinger
+// Ping* functions and can be used to run placeholder servers without a real Minecraft server.
+type StatusResponder struct {
+	// Handler provides status responses for incoming requests.
+	Handler StatusHandler
+
+	// Timeout limits the time a single connection is served for.
+	// By default, it is 15 seconds.
+	Timeout time.Duration
+
+	// LegacyWait is the time responder waits for the rest of legacy ping packet in order to tell
+	// Beta 1.8, 1.4 and 1.6 pings apart, since they begin with the same bytes.
+	// By default, it is 100 milliseconds.
+	LegacyWait time.Duration
+
+	state responderState
+}
+
+// NewStatusResponder constructs new StatusResponder instance with default parameters.
+//
+//goland:noinspection GoUnusedExportedFunction
+func NewStatusResponder(handler StatusHandler) *StatusResponder {
+	return &StatusResponder{
+		Handler:    handler,
+		Timeout:    responderDefaultTimeout,
+		LegacyWait: responderDefaultLegacyWait,
+	}
+}
+
+// ListenAndServe listens on TCP network address addr and serves incoming connections until Close is called.
+func (r *StatusResponder) ListenAndServe(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return r.Serve(listener)
+}
+
+// Serve accepts incoming connections on listener and serves each of them in a new goroutine
+// until Close is called. Listener is closed when Serve returns.
+func (r *StatusResponder) Serve(listener net.Listener) error {
+	if !r.state.track(listener) {
+		_ = listener.Close()
+		return ErrResponderClosed
+	}
+	defer r.state.untrack(listener)
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if r.state.isClosed() {
+				return ErrResponderClosed
+			}
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				continue
+			}
+			return err
+		}
+		go func() { _ = r.ServeConn(conn) }()
+	}
+}
+
+// ServeConn serves a single connection, detecting ping protocol client uses. Connection is closed
+// when ServeConn returns.
+func (r *StatusResponder) ServeConn(conn net.Conn) error {
+	defer func() { _ = conn.Close() }()
+
+	ctx, cancel := r.state.requestContext(r.Timeout)
+	defer cancel()
+	stop := watchContext(ctx, conn)
+	defer stop()
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			return err
+		}
+	}
+
+	reader := bufio.NewReader(conn)
+	first, err := reader.Peek(1)
+	if err != nil {
+		return err
+	}
+
+	// Legacy pings begin with FE byte, which can't be the first byte of 1.7+ handshake packet
+	// (as it would mean handshake packet is at least 254 bytes long.)
+	if first[0] == protocol.LegacyPingPacketID {
+		err = r.serveLegacy(ctx, conn, reader)
+	} else {
+		err = r.serve17(ctx, conn, reader)
+	}
+	if ctxErr := ctx.Err(); err != nil && ctxErr != nil {
+		return ctxErr
+	}
+	return err
+}
+
+// Close stops all Serve calls, closing their listeners, and cancels context of connections being served.
+func (r *StatusResponder) Close() error {
+	return r.state.close()
+}
+
+func (r *StatusResponder) status(ctx context.Context, req *StatusRequest) (*Status, error) {
+	if r.Handler == nil {
+		return nil, errors.New("status handler is not set")
+	}
+	status, err := r.Handler.ServeStatus(ctx, req)
+	if err != nil {
+		return nil, err
+	} else if status == nil {
+		return nil, errors.New("status handler returned nil status")
+	}
+	return status, nil
+}
+
+// responderState tracks listeners of a responder and the context cancelled on its Close.
+type responderState struct {
+	mu      sync.Mutex
+	closers map[io.Closer]struct{}
+	ctx     context.Context
+	cancel  context.CancelFunc
+	closed  bool
+}
+
+// track registers listener to be closed on close call; it returns false if state is already closed.
+func (s *responderState) track(listener io.Closer) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return false
+	}
+	if s.closers == nil {
+		s.closers = make(map[io.Closer]struct{})
+	}
+	s.closers[listener] = struct{}{}
+	return true
+}
+
+// untrack closes listener registered with track and forgets it.
+func (s *responderState) untrack(listener io.Closer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.closers[listener]; ok {
+		delete(s.closers, listener)
+		_ = listener.Close()
+	}
+}
+
+func (s *responderState) isClosed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closed
+}
+
+// requestContext returns context of a single request, limited with timeout (if positive)
+// and cancelled on close call.
+func (s *responderState) requestContext(timeout time.Duration) (context.Context, context.CancelFunc) {
+	s.mu.Lock()
+	if s.ctx == nil {
+		s.ctx, s.cancel = context.WithCancel(context.Background())
+		if s.closed {
+			s.cancel()
+		}
+	}
+	ctx := s.ctx
+	s.mu.Unlock()
+
+	if timeout > 0 {
+		return context.WithTimeout(ctx, timeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+func (s *responderState) close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closed = true
+	if s.cancel != nil {
+		s.cancel()
+	}
+
+	var err error
+	for listener := range s.closers {
+		if closeErr := listener.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+	s.closers = nil
+	return err
+}
+
+// 1.7+ protocol
+
+func (r *StatusResponder) serve17(ctx context.Context, conn net.Conn, reader *bufio.Reader) error {
+	packets, writer := protocol.NewPacketReader(reader), protocol.NewPacketWriter(conn)
+	packets.MaxPacketSize = responderMaxPacketLength
+
+	// Read handshake packet
+	packet, err := packets.ReadPacket()
+	if err != nil {
+		return fmt.Errorf("could not read handshake packet: %w", err)
+	}
+	handshake, err := protocol.DecodeHandshake(packet)
+	if err != nil {
+		return fmt.Errorf("could not parse handshake packet: %w", err)
+	}
+	req := &StatusRequest{
+		Protocol:        PingProtocol17,
+		ProtocolVersion: int(handshake.ProtocolVersion),
+		Host:            handshake.ServerAddress,
+		Port:            int(handshake.ServerPort),
+		RemoteAddr:      conn.RemoteAddr(),
+	}
+	if handshake.NextState != protocol.NextStateStatus {
+		// Only status requests are served
+		return nil
+	}
+
+	for {
+		// Read next packet, which is either status request or ping
+		packet, err := packets.ReadPacket()
+		if err != nil {
+			if err == io.EOF {
+				// Client closed connection, that's fine
+				return nil
+			}
+			return fmt.Errorf("could not read packet: %w", err)
+		}
+
+		switch packet.ID {
+		case protocol.StatusRequestPacketID:
+			status, err := r.status(ctx, req)
+			if err != nil {
+				return err
+			}
+			payload, err := MarshalStatus17(responderStatus17(status, req))
+			if err != nil {
+				return fmt.Errorf("could not marshal status: %w", err)
+			}
+			if err = writer.WritePacket(protocol.EncodeStatusResponse(payload)); err != nil {
+				return fmt.Errorf("could not write status response packet: %w", err)
+			}
+
+		case protocol.PingPacketID:
+			// Echo ping payload back and finish
+			payload, err := protocol.DecodePing(packet)
+			if err != nil {
+				return fmt.Errorf("could not parse ping packet: %w", err)
+			}
+			if err = writer.WritePacket(protocol.EncodePong(payload)); err != nil {
+				return fmt.Errorf("could not write pong packet: %w", err)
+			}
+			return nil
+
+		default:
+			return fmt.Errorf("unexpected packet ID %#x", packet.ID)
+		}
+	}
+}
+
+// Legacy protocols
+
+func (r *StatusResponder) serveLegacy(ctx context.Context, conn net.Conn, reader *bufio.Reader) error {
+	// Skip FE packet ID
+	if _, err := reader.ReadByte(); err != nil {
+		return err
+	}
+
+	req := &StatusRequest{Protocol: PingProtocolBeta18, ProtocolVersion: -1, RemoteAddr: conn.RemoteAddr()}
+	if r.peekLegacy(ctx, conn, reader, protocol.LegacyPingPayload) {
+		// FE 01 is either 1.4 ping or the beginning of 1.6 ping
+		_, _ = reader.ReadByte()
+		req.Protocol = PingProtocol14
+
+		if r.peekLegacy(ctx, conn, reader, protocol.LegacyPluginMessagePacketID) {
+			ping, err := protocol.ReadPingHost(reader)
+			if err != nil {
+				return fmt.Errorf("could not parse ping packet: %w", err)
+			}
+			req.Protocol = PingProtocol16
+			req.ProtocolVersion = int(ping.ProtocolVersion)
+			req.Host = ping.Host
+			req.Port = int(ping.Port)
+		}
+	}
+
+	status, err := r.status(ctx, req)
+	if err != nil {
+		return err
+	}
+
+	var payload string
+	switch req.Protocol {
+	case PingProtocol16:
+		payload = responderPayload16(responderStatus16(status, req))
+	case PingProtocol14:
+		s := responderStatus14(status)
+		payload = responderPayloadBeta18(s.MOTD, s.OnlinePlayers, s.MaxPlayers)
+	default:
+		s := responderStatusBeta18(status)
+		payload = responderPayloadBeta18(s.MOTD, s.OnlinePlayers, s.MaxPlayers)
+	}
+
+	if _, err = conn.Write(protocol.EncodeKick(payload)); err != nil {
+		return fmt.Errorf("could not write response packet: %w", err)
+	}
+	return nil
+}
+
+// peekLegacy waits for at most LegacyWait for the next byte to arrive and checks if it is expected.
+func (r *StatusResponder) peekLegacy(ctx context.Context, conn net.Conn, reader *bufio.Reader, expected byte) bool {
+	if reader.Buffered() == 0 {
+		wait := r.LegacyWait
+		if wait == 0 {
+			wait = responderDefaultLegacyWait
+		}
+		_ = conn.SetReadDeadline(time.Now().Add(wait))
+		defer func() {
+			// Restore connection deadline after waiting
+			deadline, _ := ctx.Deadline()
+			_ = conn.SetReadDeadline(deadline)
+		}()
+	}
+
+	b, err := reader.Peek(1)
+	return err == nil && b[0] == expected
+}
+
+func responderPayload16(s *Status16) string {
+	return protocol.EncodePing16Response(&protocol.Ping16Response{
+		ProtocolVersion: s.ProtocolVersion,
+		ServerVersion:   s.ServerVersion,
+		MOTD:            s.MOTD,
+		OnlinePlayers:   s.OnlinePlayers,
+		MaxPlayers:      s.MaxPlayers,
+	})
+}
+
+func responderPayloadBeta18(motd string, online, max int) string {
+	// Legacy formatting must be stripped from MOTD since § is used as field separator
+	motd = RenderPlainText(ParseLegacyMOTD(motd), nil)
+	return protocol.EncodeLegacyResponse(&protocol.LegacyResponse{MOTD: motd, OnlinePlayers: online, MaxPlayers: max})
+}
+
+// Response building
+
+// responderCommonStatus returns status with common fields filled from the first set protocol-specific
+// status if they are all left zero.
+func responderCommonStatus(status *Status) *Status {
+	if status.VersionName != "" || status.ProtocolVersion != 0 || status.MOTD != "" ||
+		status.OnlinePlayers != 0 || status.MaxPlayers != 0 {
+		return status
+	}
+
+	var specific interface{}
+	switch {
+	case status.Status17 != nil:
+		specific = status.Status17
+	case status.Status16 != nil:
+		specific = status.Status16
+	case status.Status14 != nil:
+		specific = status.Status14
+	case status.StatusBeta18 != nil:
+		specific = status.StatusBeta18
+	default:
+		return status
+	}
+	return newStatus(specific)
+}
+
+// responderStatus17 returns Status17 to respond with. If status is built from common fields and protocol
+// version is zero, client protocol version is used, so that client considers server compatible.
+func responderStatus17(status *Status, req *StatusRequest) *Status17 {
+	if status.Status17 != nil {
+		return status.Status17
+	}
+
+	common := responderCommonStatus(status)
+	protocolVersion := common.ProtocolVersion
+	if protocolVersion == 0 {
+		protocolVersion = req.ProtocolVersion
+	}
+	return &Status17{
+		VersionName:     common.VersionName,
+		ProtocolVersion: protocolVersion,
+		OnlinePlayers:   common.OnlinePlayers,
+		MaxPlayers:      common.MaxPlayers,
+		Description:     &ChatComponent{Type: ChatComponentText, Text: common.MOTD},
+	}
+}
+
+// responderStatus16 returns Status16 to respond with. If status is built from common fields, protocol
+// versions that don't fit 1.6 protocol are replaced with Ping16ProtocolVersionIncompatible, and zero
+// protocol version is replaced with client protocol version.
+func responderStatus16(status *Status, req *StatusRequest) *Status16 {
+	if status.Status16 != nil {
+		return status.Status16
+	}
+
+	common := responderCommonStatus(status)
+	protocolVersion := common.ProtocolVersion
+	if protocolVersion == 0 {
+		protocolVersion = req.ProtocolVersion
+	}
+	if protocolVersion < 0 || protocolVersion > int(Ping16ProtocolVersionIncompatible) {
+		protocolVersion = int(Ping16ProtocolVersionIncompatible)
+	}
+	return &Status16{
+		ProtocolVersion: protocolVersion,
+		ServerVersion:   common.VersionName,
+		MOTD:            common.MOTD,
+		OnlinePlayers:   common.OnlinePlayers,
+		MaxPlayers:      common.MaxPlayers,
+	}
+}
+
+func responderStatus14(status *Status) *Status14 {
+	if status.Status14 != nil {
+		return status.Status14
+	}
+
+	common := responderCommonStatus(status)
+	return &Status14{
+		MOTD:          common.MOTD,
+		OnlinePlayers: common.OnlinePlayers,
+		MaxPlayers:    common.MaxPlayers,
+	}
+}
+
+func responderStatusBeta18(status *Status) *StatusBeta18 {
+	if status.StatusBeta18 != nil {
+		return status.StatusBeta18
+	}
+
+	common := responderCommonStatus(status)
+	return &StatusBeta18{
+		MOTD:          common.MOTD,
+		OnlinePlayers: common.OnlinePlayers,
+		MaxPlayers:    common.MaxPlayers,
+	}
+}
+
+// MarshalStatus17 encodes Status17 to JSON payload of 1.7+ status response, the same way StatusResponder
+// sends it. UnknownFields are included into the payload too, but fields mapped by Status17 take precedence.
+func MarshalStatus17(status *Status17) ([]byte, error) {
+	object := make(map[string]interface{}, len(status.UnknownFields)+8)
+	for key, value := range status.UnknownFields {
+		object[key] = value
+	}
+
+	object["version"] = map[string]interface{}{
+		"name":     status.VersionName,
+		"protocol": status.ProtocolVersion,
+	}
+
+	sample := make([]map[string]interface{}, 0, len(status.SamplePlayers))
+	for _, player := range status.SamplePlayers {
+		sample = append(sample, map[string]interface{}{"name": player.Nickname, "id": player.UUID.String()})
+	}
+	players := map[string]interface{}{"max": status.MaxPlayers, "online": status.OnlinePlayers}
+	if len(sample) > 0 {
+		players["sample"] = sample
+	}
+	object["players"] = players
+
+	if status.Description != nil {
+		object["description"] = chat17Component(status.Description).jsonValue()
+	} else {
+		object["description"] = ""
+	}
+
+	if status.Icon != nil {
+		var icon bytes.Buffer
+		if err := png.Encode(&icon, status.Icon); err != nil {
+			return nil, fmt.Errorf("could not encode favicon: %w", err)
+		}
+		object["favicon"] = ping17StatusImagePrefix + base64.StdEncoding.EncodeToString(icon.Bytes())
+	}
+
+	if status.PreviewsChat {
+		object["previewsChat"] = true
+	}
+	if status.EnforcesSecureChat {
+		object["enforcesSecureChat"] = true
+	}
+
+	if modInfo := status.ModInfo; modInfo != nil {
+		if modInfo.NetworkVersion <= 1 {
+			modList := make([]map[string]interface{}, 0, len(modInfo.Mods))
+			for _, mod := range modInfo.Mods {
+				modList = append(modList, map[string]interface{}{"modid": mod.ID, "version": mod.Version})
+			}
+			loader := modInfo.Loader
+			if loader == "" {
+				loader = modLoaderFML
+			}
+			object["modinfo"] = map[string]interface{}{"type": loader, "modList": modList}
+		} else {
+			mods := make([]map[string]interface{}, 0, len(modInfo.Mods))
+			for _, mod := range modInfo.Mods {
+				mods = append(mods, map[string]interface{}{"modId": mod.ID, "modmarker": mod.Version})
+			}
+			channels := make([]map[string]interface{}, 0, len(modInfo.Channels))
+			for _, channel := range modInfo.Channels {
+				channels = append(channels, map[string]interface{}{
+					"res": channel.Name, "version": channel.Version, "required": channel.Required,
+				})
+			}
+			object["forgeData"] = map[string]interface{}{
+				"channels":          channels,
+				"mods":              mods,
+				"fmlNetworkVersion": modInfo.NetworkVersion,
+				"truncated":         modInfo.Truncated,
+			}
+		}
+	}
+
+	return json.Marshal(object)
+}
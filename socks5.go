@@ -0,0 +1,389 @@
+package minequery
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	socks5Version = 0x05
+
+	socks5AuthMethodNone          = 0x00
+	socks5AuthMethodPassword      = 0x02
+	socks5AuthMethodNotAcceptable = 0xff
+	socks5PasswordAuthVersion     = 0x01
+
+	socks5CommandConnect      = 0x01
+	socks5CommandUDPAssociate = 0x03
+
+	socks5AddressTypeIPv4   = 0x01
+	socks5AddressTypeDomain = 0x03
+	socks5AddressTypeIPv6   = 0x04
+
+	socks5ReplySucceeded = 0x00
+
+	// socks5MaxUDPHeaderLength is the maximum length of UDP request header:
+	// RSV (2), FRAG (1), ATYP (1), domain length (1), domain (255), DST.PORT (2).
+	socks5MaxUDPHeaderLength = 262
+)
+
+// ErrSOCKS5 wraps errors returned by SOCKS5 proxy server or occurred during communication with it.
+var ErrSOCKS5 = errors.New("socks5 proxy error")
+
+// socks5ReplyMessages holds messages of SOCKS5 reply codes as defined by RFC 1928.
+var socks5ReplyMessages = map[byte]string{
+	0x01: "general SOCKS server failure",
+	0x02: "connection not allowed by ruleset",
+	0x03: "network unreachable",
+	0x04: "host unreachable",
+	0x05: "connection refused",
+	0x06: "TTL expired",
+	0x07: "command not supported",
+	0x08: "address type not supported",
+}
+
+// SOCKS5Dialer is a ContextDialer connecting to servers through SOCKS5 proxy (RFC 1928), with optional
+// username/password authentication (RFC 1929). Besides TCP connections, it supports UDP with UDP ASSOCIATE
+// command, so it can be used as Pinger UDPDialer for Query and Bedrock Edition pings too.
+//
+// Hostnames are sent to proxy as is and resolved by it, unless Pinger Resolver is set.
+type SOCKS5Dialer struct {
+	// Address is the address of proxy server as host:port.
+	Address string
+
+	// Username and Password are credentials for username/password authentication.
+	// If Username is empty, no authentication is used.
+	Username string
+	Password string
+
+	// Dialer is used to connect to proxy server and its UDP relay. By default (if nil), zero net.Dialer is used.
+	Dialer ContextDialer
+}
+
+// NewSOCKS5Dialer constructs new SOCKS5Dialer instance connecting through proxy at address,
+// authenticating with username and password if username is not empty.
+//
+//goland:noinspection GoUnusedExportedFunction
+func NewSOCKS5Dialer(address, username, password string) *SOCKS5Dialer {
+	return &SOCKS5Dialer{Address: address, Username: username, Password: password}
+}
+
+// DialContext connects to address on network (tcp, tcp4, tcp6, udp, udp4 or udp6) through proxy.
+// Connections on UDP networks send and receive datagrams through proxy UDP relay, and keep TCP connection
+// with proxy open (which UDP association lives as long as) until closed.
+func (d *SOCKS5Dialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	host, portStr, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid port %q", portStr)
+	}
+
+	switch network {
+	case "tcp", "tcp4", "tcp6":
+		return d.dialTCP(ctx, host, uint16(port))
+	case "udp", "udp4", "udp6":
+		return d.dialUDP(ctx, network, host, uint16(port))
+	default:
+		return nil, fmt.Errorf("%w: unsupported network %s", ErrSOCKS5, network)
+	}
+}
+
+func (d *SOCKS5Dialer) dialTCP(ctx context.Context, host string, port uint16) (net.Conn, error) {
+	conn, _, err := d.handshake(ctx, socks5CommandConnect, host, port)
+	if err != nil {
+		return nil, err
+	}
+	return conn, nil
+}
+
+func (d *SOCKS5Dialer) dialUDP(ctx context.Context, network string, host string, port uint16) (net.Conn, error) {
+	// Client address is not known before UDP socket is bound, so zero address of network family is sent
+	unspecified := "0.0.0.0"
+	if network == "udp6" {
+		unspecified = "::"
+	}
+	control, relay, err := d.handshake(ctx, socks5CommandUDPAssociate, unspecified, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	// Unspecified (or domain) relay address means that relay is at the same host as proxy server itself
+	relayAddr := relay.String()
+	if relay.IP == nil || relay.IP.IsUnspecified() {
+		proxyHost, _, err := net.SplitHostPort(control.RemoteAddr().String())
+		if err != nil {
+			_ = control.Close()
+			return nil, err
+		}
+		relayAddr = net.JoinHostPort(proxyHost, strconv.Itoa(relay.Port))
+	}
+
+	conn, err := d.dialer().DialContext(ctx, network, relayAddr)
+	if err != nil {
+		_ = control.Close()
+		return nil, err
+	}
+
+	return &socks5UDPConn{
+		Conn:    conn,
+		control: control,
+		header:  socks5UDPHeader(host, port),
+		remote:  socks5Addr{network: network, address: net.JoinHostPort(host, strconv.Itoa(int(port)))},
+	}, nil
+}
+
+// dialer returns SOCKS5Dialer Dialer, or zero net.Dialer if it is not set.
+func (d *SOCKS5Dialer) dialer() ContextDialer {
+	if d.Dialer == nil {
+		return &net.Dialer{}
+	}
+	return d.Dialer
+}
+
+// handshake connects to proxy server, authenticates and sends request with command and destination
+// address, returning connection with proxy server and bound address from its reply.
+func (d *SOCKS5Dialer) handshake(ctx context.Context, command byte, host string, port uint16) (net.Conn, *net.UDPAddr, error) {
+	conn, err := d.dialer().DialContext(ctx, "tcp", d.Address)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Limit handshake by context deadline and interrupt it once context is done
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	stop := watchContext(ctx, conn)
+	bound, err := d.negotiate(conn, command, host, port)
+	stop()
+	if err != nil {
+		_ = conn.Close()
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, nil, ctxErr
+		}
+		return nil, nil, err
+	}
+	if err = conn.SetDeadline(time.Time{}); err != nil {
+		_ = conn.Close()
+		return nil, nil, err
+	}
+	return conn, bound, nil
+}
+
+func (d *SOCKS5Dialer) negotiate(conn net.Conn, command byte, host string, port uint16) (*net.UDPAddr, error) {
+	// Replies are read unbuffered, since data sent by destination server follows them on TCP connections
+	reader := conn
+
+	// Send greeting with supported authentication methods
+	method := byte(socks5AuthMethodNone)
+	if d.Username != "" {
+		method = socks5AuthMethodPassword
+	}
+	if _, err := conn.Write([]byte{socks5Version, 1, method}); err != nil {
+		return nil, fmt.Errorf("could not write greeting: %w", err)
+	}
+
+	// Read method selected by server
+	selected := make([]byte, 2)
+	if _, err := io.ReadFull(reader, selected); err != nil {
+		return nil, fmt.Errorf("could not read method selection: %w", err)
+	}
+	if selected[0] != socks5Version {
+		return nil, fmt.Errorf("%w: unexpected version %d", ErrSOCKS5, selected[0])
+	}
+	if selected[1] == socks5AuthMethodNotAcceptable || selected[1] != method {
+		return nil, fmt.Errorf("%w: no acceptable authentication methods", ErrSOCKS5)
+	}
+
+	// Authenticate with username and password if requested
+	if method == socks5AuthMethodPassword {
+		if err := d.authenticate(conn, reader); err != nil {
+			return nil, err
+		}
+	}
+
+	// Send request
+	request := []byte{socks5Version, command, 0x00}
+	request, err := socks5AppendAddr(request, host, port)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = conn.Write(request); err != nil {
+		return nil, fmt.Errorf("could not write request: %w", err)
+	}
+
+	// Read reply
+	reply := make([]byte, 3)
+	if _, err = io.ReadFull(reader, reply); err != nil {
+		return nil, fmt.Errorf("could not read reply: %w", err)
+	}
+	if reply[0] != socks5Version {
+		return nil, fmt.Errorf("%w: unexpected version %d", ErrSOCKS5, reply[0])
+	}
+	if reply[1] != socks5ReplySucceeded {
+		message, ok := socks5ReplyMessages[reply[1]]
+		if !ok {
+			message = fmt.Sprintf("unknown reply code 0x%x", reply[1])
+		}
+		return nil, fmt.Errorf("%w: %s", ErrSOCKS5, message)
+	}
+	bound, err := socks5ReadAddr(reader)
+	if err != nil {
+		return nil, fmt.Errorf("could not read bound address: %w", err)
+	}
+	return bound, nil
+}
+
+func (d *SOCKS5Dialer) authenticate(conn net.Conn, reader io.Reader) error {
+	if len(d.Username) > 255 || len(d.Password) > 255 {
+		return fmt.Errorf("%w: username and password must not be longer than 255 bytes", ErrSOCKS5)
+	}
+
+	request := []byte{socks5PasswordAuthVersion, byte(len(d.Username))}
+	request = append(request, d.Username...)
+	request = append(request, byte(len(d.Password)))
+	request = append(request, d.Password...)
+	if _, err := conn.Write(request); err != nil {
+		return fmt.Errorf("could not write authentication request: %w", err)
+	}
+
+	response := make([]byte, 2)
+	if _, err := io.ReadFull(reader, response); err != nil {
+		return fmt.Errorf("could not read authentication response: %w", err)
+	}
+	if response[1] != 0x00 {
+		return fmt.Errorf("%w: authentication failed", ErrSOCKS5)
+	}
+	return nil
+}
+
+// socks5AppendAddr appends ATYP, DST.ADDR and DST.PORT fields of host and port to b.
+func socks5AppendAddr(b []byte, host string, port uint16) ([]byte, error) {
+	if ip := net.ParseIP(host); ip != nil {
+		if ip4 := ip.To4(); ip4 != nil {
+			b = append(append(b, socks5AddressTypeIPv4), ip4...)
+		} else {
+			b = append(append(b, socks5AddressTypeIPv6), ip.To16()...)
+		}
+	} else {
+		if len(host) > 255 {
+			return nil, fmt.Errorf("%w: hostname %q is too long", ErrSOCKS5, host)
+		}
+		b = append(append(b, socks5AddressTypeDomain, byte(len(host))), host...)
+	}
+	return append(b, byte(port>>8), byte(port)), nil
+}
+
+// socks5ReadAddr reads ATYP, BND.ADDR and BND.PORT fields. Domain addresses are returned with nil IP.
+func socks5ReadAddr(reader io.Reader) (*net.UDPAddr, error) {
+	addressType := make([]byte, 1)
+	if _, err := io.ReadFull(reader, addressType); err != nil {
+		return nil, err
+	}
+
+	var ip []byte
+	switch addressType[0] {
+	case socks5AddressTypeIPv4:
+		ip = make([]byte, net.IPv4len)
+	case socks5AddressTypeIPv6:
+		ip = make([]byte, net.IPv6len)
+	case socks5AddressTypeDomain:
+		length := make([]byte, 1)
+		if _, err := io.ReadFull(reader, length); err != nil {
+			return nil, err
+		}
+		if _, err := io.ReadFull(reader, make([]byte, length[0])); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("%w: unknown address type 0x%x", ErrSOCKS5, addressType[0])
+	}
+	if ip != nil {
+		if _, err := io.ReadFull(reader, ip); err != nil {
+			return nil, err
+		}
+	}
+
+	port := make([]byte, 2)
+	if _, err := io.ReadFull(reader, port); err != nil {
+		return nil, err
+	}
+	return &net.UDPAddr{IP: ip, Port: int(binary.BigEndian.Uint16(port))}, nil
+}
+
+// socks5UDPHeader builds UDP request header of datagrams sent to host and port.
+func socks5UDPHeader(host string, port uint16) []byte {
+	// Hostname length was already validated by UDP ASSOCIATE request
+	header, _ := socks5AppendAddr([]byte{0x00, 0x00, 0x00}, host, port)
+	return header
+}
+
+// socks5Addr is a net.Addr of destination of connection made through proxy.
+type socks5Addr struct {
+	network string
+	address string
+}
+
+func (a socks5Addr) Network() string { return a.network }
+func (a socks5Addr) String() string  { return a.address }
+
+// socks5UDPConn is a connection sending and receiving datagrams through SOCKS5 UDP relay.
+type socks5UDPConn struct {
+	net.Conn
+
+	control   net.Conn
+	header    []byte
+	remote    net.Addr
+	closeOnce sync.Once
+}
+
+// Read reads a single datagram from relay, stripping its header. Fragmented datagrams are dropped.
+func (c *socks5UDPConn) Read(b []byte) (int, error) {
+	buf := make([]byte, len(b)+socks5MaxUDPHeaderLength)
+	for {
+		n, err := c.Conn.Read(buf)
+		if err != nil {
+			return 0, err
+		}
+
+		// Parse header: RSV, FRAG, and address of datagram sender
+		if n < 4 || buf[2] != 0x00 {
+			continue
+		}
+		reader := bytes.NewReader(buf[3:n])
+		if _, err = socks5ReadAddr(reader); err != nil {
+			continue
+		}
+		return copy(b, buf[n-reader.Len():n]), nil
+	}
+}
+
+// Write sends b as a single datagram through relay.
+func (c *socks5UDPConn) Write(b []byte) (int, error) {
+	datagram := make([]byte, 0, len(c.header)+len(b))
+	datagram = append(append(datagram, c.header...), b...)
+	if _, err := c.Conn.Write(datagram); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// RemoteAddr returns address of destination datagrams are sent to.
+func (c *socks5UDPConn) RemoteAddr() net.Addr { return c.remote }
+
+// Close closes UDP socket and TCP connection with proxy, which terminates UDP association.
+func (c *socks5UDPConn) Close() error {
+	err := c.Conn.Close()
+	c.closeOnce.Do(func() { _ = c.control.Close() })
+	return err
+}
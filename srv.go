@@ -0,0 +1,51 @@
+package minequery
+
+import "context"
+
+// SRVRecord holds a single _minecraft._tcp SRV record of a server hostname.
+// Records are tried by priority (lowest first), and records of the same priority are load-balanced
+// by weight, as defined by RFC 2782.
+type SRVRecord struct {
+	// Target is the hostname server is reachable at, as it is in SRV record with only trailing dot removed.
+	// It is not canonicalized: if it is an alias (which RFC 2782 disallows, but some DNS providers allow),
+	// its CNAME records are only followed by the address lookup of connection.
+	Target string
+
+	// Port is the port server is reachable at.
+	Port int
+
+	Priority uint16
+	Weight   uint16
+}
+
+// LookupSRV looks up Minecraft SRV records of host, returning them in the order Ping* functions
+// try them; records of the same priority are shuffled by weight on each call. If host has no SRV records,
+// empty slice and nil error are returned.
+//
+//goland:noinspection GoUnusedExportedFunction
+func LookupSRV(host string) ([]SRVRecord, error) {
+	return defaultPinger.LookupSRV(host)
+}
+
+// LookupSRVContext looks up Minecraft SRV records of host, returning them in the order Ping* functions
+// try them, using the provided context for cancellation; records of the same priority are shuffled by
+// weight on each call. If host has no SRV records, empty slice and nil error are returned.
+//
+//goland:noinspection GoUnusedExportedFunction
+func LookupSRVContext(ctx context.Context, host string) ([]SRVRecord, error) {
+	return defaultPinger.LookupSRVContext(ctx, host)
+}
+
+// LookupSRV looks up Minecraft SRV records of host, returning them in the order Ping* functions
+// try them; records of the same priority are shuffled by weight on each call. If host has no SRV records,
+// empty slice and nil error are returned.
+func (p *Pinger) LookupSRV(host string) ([]SRVRecord, error) {
+	return p.LookupSRVContext(context.Background(), host)
+}
+
+// LookupSRVContext looks up Minecraft SRV records of host, returning them in the order Ping* functions
+// try them, using the provided context for cancellation; records of the same priority are shuffled by
+// weight on each call. If host has no SRV records, empty slice and nil error are returned.
+func (p *Pinger) LookupSRVContext(ctx context.Context, host string) ([]SRVRecord, error) {
+	return p.resolveSRV(ctx, host)
+}
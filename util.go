@@ -1,11 +1,13 @@
 package minequery
 
 import (
+	"context"
 	"errors"
 	"image"
 	"io"
 	"regexp"
 	"strings"
+	"sync"
 )
 
 var errStackEmpty = errors.New("stack is empty")
@@ -50,3 +52,47 @@ func naturalizeMOTD(s string) string {
 	s = strings.TrimSpace(s)
 	return s
 }
+
+// flightGroup deduplicates concurrent calls with the same key, so that only one of them is executed
+// at a time and the rest wait for and share its result.
+type flightGroup struct {
+	mu    sync.Mutex
+	calls map[string]*flightCall
+}
+
+type flightCall struct {
+	done chan struct{}
+	val  interface{}
+	err  error
+}
+
+// Do executes fn in a new goroutine unless there's a call with the same key in flight already, and waits
+// for the call to finish or ctx to be done. Since fn may be shared by several callers, it must not depend
+// on ctx of any of them.
+func (g *flightGroup) Do(ctx context.Context, key string, fn func() (interface{}, error)) (interface{}, error) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*flightCall)
+	}
+	call, ok := g.calls[key]
+	if !ok {
+		call = &flightCall{done: make(chan struct{})}
+		g.calls[key] = call
+		go func() {
+			call.val, call.err = fn()
+
+			g.mu.Lock()
+			delete(g.calls, key)
+			g.mu.Unlock()
+			close(call.done)
+		}()
+	}
+	g.mu.Unlock()
+
+	select {
+	case <-call.done:
+		return call.val, call.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
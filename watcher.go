@@ -0,0 +1,378 @@
+package minequery
+
+import (
+	"context"
+	"encoding/json"
+	"image"
+	"time"
+)
+
+// WatchEventType is a type of event emitted by Watcher.
+type WatchEventType int
+
+const (
+	// WatchEventUp is emitted when server starts responding to pings (or responds to the first one.)
+	WatchEventUp WatchEventType = iota
+
+	// WatchEventDown is emitted when server stops responding to pings (or fails to respond to the first one.)
+	WatchEventDown
+
+	// WatchEventMOTDChanged is emitted when server MOTD (or 1.7+ description) changes.
+	WatchEventMOTDChanged
+
+	// WatchEventVersionChanged is emitted when server version name or protocol version changes.
+	WatchEventVersionChanged
+
+	// WatchEventFaviconChanged is emitted when 1.7+ server favicon changes.
+	WatchEventFaviconChanged
+
+	// WatchEventPlayersAbove is emitted when online player count reaches one of Watcher thresholds from below.
+	WatchEventPlayersAbove
+
+	// WatchEventPlayersBelow is emitted when online player count drops below one of Watcher thresholds.
+	WatchEventPlayersBelow
+
+	// WatchEventPlayerJoined is emitted when a player appears in server player list.
+	WatchEventPlayerJoined
+
+	// WatchEventPlayerLeft is emitted when a player disappears from server player list.
+	WatchEventPlayerLeft
+)
+
+// String returns a human-readable name of event type.
+func (t WatchEventType) String() string {
+	switch t {
+	case WatchEventUp:
+		return "up"
+	case WatchEventDown:
+		return "down"
+	case WatchEventMOTDChanged:
+		return "MOTD changed"
+	case WatchEventVersionChanged:
+		return "version changed"
+	case WatchEventFaviconChanged:
+		return "favicon changed"
+	case WatchEventPlayersAbove:
+		return "players above threshold"
+	case WatchEventPlayersBelow:
+		return "players below threshold"
+	case WatchEventPlayerJoined:
+		return "player joined"
+	case WatchEventPlayerLeft:
+		return "player left"
+	default:
+		return "unknown"
+	}
+}
+
+// WatchEvent holds a single event emitted by Watcher.
+type WatchEvent struct {
+	// Type is the type of event.
+	Type WatchEventType
+
+	// Target is the target of Watcher emitting event.
+	Target Target
+
+	// Time is the time of poll that caused event.
+	Time time.Time
+
+	// Status is the status returned by server on poll that caused event; it is nil for WatchEventDown.
+	Status *Status
+
+	// Previous is the last status returned by server before this event (which may be received before
+	// server went down); it is nil if server has not responded before.
+	Previous *Status
+
+	// Err is the error of the last failed ping for WatchEventDown.
+	Err error
+
+	// Player is the nickname of player for WatchEventPlayerJoined and WatchEventPlayerLeft.
+	Player string
+
+	// Threshold is the threshold crossed for WatchEventPlayersAbove and WatchEventPlayersBelow.
+	Threshold int
+}
+
+const (
+	defaultWatchInterval = 30 * time.Second
+	defaultWatchDebounce = 2
+)
+
+// Watcher polls a server on interval with Ping (and optionally QueryFull) and emits events
+// when its state changes.
+//
+// Player joins and leaves are inferred from full query player list if Query is set, or from 1.7+
+// status player sample otherwise. Since servers only send a few random players in sample, sample is
+// only used when it lists all online players. If player list can't be obtained on poll (including
+// failed query), no joins or leaves are reported for it.
+type Watcher struct {
+	// Pinger is used to ping and query server.
+	Pinger *Pinger
+
+	// Target is the address of server being watched.
+	Target Target
+
+	// Interval is the time between polls.
+	// By default, it is 30 seconds.
+	Interval time.Duration
+
+	// Debounce is the number of consecutive polls server must respond (or fail to respond) in
+	// before it is considered up (or down), which suppresses events of flapping servers.
+	// First poll is never debounced. By default, it is 2.
+	Debounce int
+
+	// Thresholds are online player counts crossing which is reported with WatchEventPlayersAbove
+	// and WatchEventPlayersBelow events.
+	Thresholds []int
+
+	// Query defines if server is also queried with QueryFull on each poll to obtain full player list.
+	Query bool
+
+	// QueryPort is the port server is queried on if Query is set.
+	// By default (if zero), Target Port is used.
+	QueryPort int
+
+	// state of server observed by previous polls
+	known   bool
+	up      bool
+	streak  int
+	last    *Status
+	players map[string]struct{}
+}
+
+// NewWatcher constructs new Watcher instance with default parameters for the target at host and port.
+// If pinger is nil, the default Pinger is used.
+//
+//goland:noinspection GoUnusedExportedFunction
+func NewWatcher(pinger *Pinger, host string, port int) *Watcher {
+	if pinger == nil {
+		pinger = defaultPinger
+	}
+	return &Watcher{
+		Pinger:   pinger,
+		Target:   Target{Host: host, Port: port},
+		Interval: defaultWatchInterval,
+		Debounce: defaultWatchDebounce,
+	}
+}
+
+// Watch polls server until ctx is done and streams events over the returned channel, which is
+// closed once ctx is done. Events not received by the time ctx is done are dropped.
+func (w *Watcher) Watch(ctx context.Context) <-chan WatchEvent {
+	events := make(chan WatchEvent)
+	go func() {
+		defer close(events)
+		_ = w.Run(ctx, func(event WatchEvent) {
+			// Don't block on receiver that stopped reading once ctx is done
+			select {
+			case events <- event:
+			case <-ctx.Done():
+			}
+		})
+	}()
+	return events
+}
+
+// Run polls server until ctx is done, calling fn with each event, and returns ctx error.
+// The first poll is performed immediately. Watcher must not be used by more than one Run at a time.
+func (w *Watcher) Run(ctx context.Context, fn func(WatchEvent)) error {
+	interval := w.Interval
+	if interval <= 0 {
+		interval = defaultWatchInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		w.poll(ctx, fn)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func (w *Watcher) poll(ctx context.Context, fn func(WatchEvent)) {
+	pinger := w.Pinger
+	if pinger == nil {
+		pinger = defaultPinger
+	}
+
+	now := time.Now()
+	status, err := pinger.PingContext(ctx, w.Target.Host, w.Target.Port)
+	if ctx.Err() != nil {
+		// Cancelled poll says nothing about server state
+		return
+	}
+
+	var players map[string]struct{}
+	if err == nil {
+		players = w.playerList(ctx, pinger, status)
+		if ctx.Err() != nil {
+			return
+		}
+	}
+	emit := func(event WatchEvent) {
+		event.Target, event.Time = w.Target, now
+		fn(event)
+	}
+
+	// Report initial state right away
+	if !w.known {
+		w.known, w.up = true, err == nil
+		if err != nil {
+			emit(WatchEvent{Type: WatchEventDown, Err: err})
+			return
+		}
+		emit(WatchEvent{Type: WatchEventUp, Status: status})
+		w.last, w.players = status, players
+		return
+	}
+
+	// Debounce up/down state changes: state only flips after Debounce consecutive polls disagree with it
+	if up := err == nil; up != w.up {
+		w.streak++
+		debounce := w.Debounce
+		if debounce < 1 {
+			debounce = 1
+		}
+		if w.streak < debounce {
+			return
+		}
+		w.up, w.streak = up, 0
+
+		if !up {
+			emit(WatchEvent{Type: WatchEventDown, Previous: w.last, Err: err})
+			return
+		}
+		emit(WatchEvent{Type: WatchEventUp, Status: status, Previous: w.last})
+	} else {
+		w.streak = 0
+		if !up {
+			return
+		}
+	}
+
+	// Server is up, compare status with the last one
+	previous := w.last
+	if previous != nil {
+		for _, change := range watchStatusChanges(previous, status, w.Thresholds) {
+			emit(WatchEvent{Type: change.Type, Status: status, Previous: previous, Threshold: change.Threshold})
+		}
+	}
+	if players != nil && w.players != nil {
+		for name := range players {
+			if _, ok := w.players[name]; !ok {
+				emit(WatchEvent{Type: WatchEventPlayerJoined, Status: status, Previous: previous, Player: name})
+			}
+		}
+		for name := range w.players {
+			if _, ok := players[name]; !ok {
+				emit(WatchEvent{Type: WatchEventPlayerLeft, Status: status, Previous: previous, Player: name})
+			}
+		}
+	}
+	w.last, w.players = status, players
+}
+
+// playerList returns complete list of online players, or nil if it can't be obtained.
+func (w *Watcher) playerList(ctx context.Context, pinger *Pinger, status *Status) map[string]struct{} {
+	if w.Query {
+		// Failed query leaves player list unknown rather than falling back to (possibly different) sample
+		port := w.QueryPort
+		if port == 0 {
+			port = w.Target.Port
+		}
+		res, err := pinger.QueryFullContext(ctx, w.Target.Host, port)
+		if err != nil {
+			return nil
+		}
+		players := make(map[string]struct{}, len(res.SamplePlayers))
+		for _, name := range res.SamplePlayers {
+			players[name] = struct{}{}
+		}
+		return players
+	}
+
+	if status.Status17 != nil && len(status.Status17.SamplePlayers) == status.Status17.OnlinePlayers {
+		players := make(map[string]struct{}, len(status.Status17.SamplePlayers))
+		for _, player := range status.Status17.SamplePlayers {
+			players[player.Nickname] = struct{}{}
+		}
+		return players
+	}
+	return nil
+}
+
+// watchChange is a status change found by watchStatusChanges.
+type watchChange struct {
+	Type      WatchEventType
+	Threshold int
+}
+
+// watchStatusChanges compares statuses of two subsequent polls and returns changes between them.
+func watchStatusChanges(previous, current *Status, thresholds []int) []watchChange {
+	var changes []watchChange
+
+	if watchMOTD(previous) != watchMOTD(current) {
+		changes = append(changes, watchChange{Type: WatchEventMOTDChanged})
+	}
+	if previous.VersionName != current.VersionName || previous.ProtocolVersion != current.ProtocolVersion {
+		changes = append(changes, watchChange{Type: WatchEventVersionChanged})
+	}
+	if !equalImages(watchFavicon(previous), watchFavicon(current)) {
+		changes = append(changes, watchChange{Type: WatchEventFaviconChanged})
+	}
+
+	for _, threshold := range thresholds {
+		switch {
+		case previous.OnlinePlayers < threshold && current.OnlinePlayers >= threshold:
+			changes = append(changes, watchChange{Type: WatchEventPlayersAbove, Threshold: threshold})
+		case previous.OnlinePlayers >= threshold && current.OnlinePlayers < threshold:
+			changes = append(changes, watchChange{Type: WatchEventPlayersBelow, Threshold: threshold})
+		}
+	}
+
+	return changes
+}
+
+// watchMOTD returns a comparable representation of server MOTD, which includes formatting of 1.7+ description.
+func watchMOTD(status *Status) string {
+	if status.Status17 != nil && status.Status17.Description != nil {
+		if component := status.Status17.DescriptionComponent(); component != nil {
+			if b, err := json.Marshal(component.jsonValue()); err == nil {
+				return string(b)
+			}
+		}
+	}
+	return status.MOTD
+}
+
+func watchFavicon(status *Status) image.Image {
+	if status.Status17 == nil {
+		return nil
+	}
+	return status.Status17.Icon
+}
+
+// equalImages checks if two images have the same bounds and pixel colors.
+func equalImages(a, b image.Image) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	bounds := a.Bounds()
+	if bounds != b.Bounds() {
+		return false
+	}
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			r1, g1, b1, a1 := a.At(x, y).RGBA()
+			r2, g2, b2, a2 := b.At(x, y).RGBA()
+			if r1 != r2 || g1 != g2 || b1 != b2 || a1 != a2 {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,113 @@
+package minequery_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dreamscached/minequery/v2"
+	"github.com/dreamscached/minequery/v2/minequerytest"
+)
+
+var errServerDown = errors.New("server is down")
+
+// scriptedDialer connects each poll of Watcher to the next server of script (nil meaning server is down),
+// and cancels context once script is over.
+type scriptedDialer struct {
+	mu     sync.Mutex
+	script []*minequerytest.Server
+	cancel context.CancelFunc
+}
+
+func (d *scriptedDialer) DialContext(ctx context.Context, network, _ string) (net.Conn, error) {
+	d.mu.Lock()
+	if len(d.script) == 0 {
+		d.mu.Unlock()
+		d.cancel()
+		return nil, context.Canceled
+	}
+	server := d.script[0]
+	d.script = d.script[1:]
+	d.mu.Unlock()
+
+	if server == nil {
+		return nil, errServerDown
+	}
+	var dialer net.Dialer
+	return dialer.DialContext(ctx, network, server.Addr())
+}
+
+func TestWatcher(t *testing.T) {
+	newServer := func(players ...string) *minequerytest.Server {
+		status := &minequery.Status17{
+			VersionName:     "1.20.1",
+			ProtocolVersion: 763,
+			OnlinePlayers:   len(players),
+			MaxPlayers:      20,
+			Description:     &minequery.ChatComponent{Text: "A Minecraft Server"},
+		}
+		for _, player := range players {
+			status.SamplePlayers = append(status.SamplePlayers, minequery.PlayerEntry17{Nickname: player})
+		}
+		server, err := minequerytest.NewPing17Server(status, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return server
+	}
+	before, after := newServer("alice", "bob"), newServer("alice", "carol")
+	defer func() { _ = before.Close() }()
+	defer func() { _ = after.Close() }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	dialer := &scriptedDialer{
+		script: []*minequerytest.Server{
+			before, // initial state is reported right away
+			nil,    // single failed poll is debounced
+			before,
+			nil, nil, // server goes down
+			after, after, // server goes up with a different player list
+		},
+		cancel: cancel,
+	}
+
+	pinger := minequery.NewPinger(
+		minequery.WithContextDialer(dialer),
+		minequery.WithPreferSRVRecord(false),
+		minequery.WithTimeout(time.Second),
+	)
+	watcher := minequery.NewWatcher(pinger, "127.0.0.1", 25565)
+	watcher.Interval = time.Millisecond
+
+	var events []minequery.WatchEvent
+	for event := range watcher.Watch(ctx) {
+		events = append(events, event)
+	}
+
+	expected := []struct {
+		Type   minequery.WatchEventType
+		Player string
+	}{
+		{Type: minequery.WatchEventUp},
+		{Type: minequery.WatchEventDown},
+		{Type: minequery.WatchEventUp},
+		{Type: minequery.WatchEventPlayerJoined, Player: "carol"},
+		{Type: minequery.WatchEventPlayerLeft, Player: "bob"},
+	}
+	if len(events) != len(expected) {
+		t.Fatalf("expected %d events, got %d: %v", len(expected), len(events), events)
+	}
+	for i, event := range events {
+		if event.Type != expected[i].Type || event.Player != expected[i].Player {
+			t.Errorf("expected event #%d to be %s %q, got %s %q",
+				i, expected[i].Type, expected[i].Player, event.Type, event.Player)
+		}
+	}
+	if !errors.Is(events[1].Err, errServerDown) {
+		t.Errorf("expected down event error to be %v, got %v", errServerDown, events[1].Err)
+	}
+}